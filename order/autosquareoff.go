@@ -0,0 +1,328 @@
+package trade
+
+import (
+	"context"
+	"encoding/json"
+	config "equity-trading/pkg/config"
+	"equity-trading/pkg/db/squareoff"
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+/*
+auto square off cutoff of every segment in IST, overridden by
+config autoSquareOff.cutoff.<equity|derivative|currency|commodity>,
+a segment is not squared off on holidays of its exchange
+*/
+var squareOffCutoffs = []struct {
+	segment  string
+	exchange string
+	name     string
+	cutoff   string
+}{
+	{segment: EquitySegment, exchange: "NSE", name: "equity", cutoff: "15:20"},
+	{segment: DerivativeSegment, exchange: "NSE", name: "derivative", cutoff: "15:25"},
+	{segment: CurrencySegment, exchange: "NSE", name: "currency", cutoff: "16:45"},
+	{segment: CommoditySegment, exchange: "MCX", name: "commodity", cutoff: "23:25"},
+}
+
+// runs of the scheduler never overlap within an instance, across instances every run is claimed through DBLayer
+var autoSquareOffMu sync.Mutex
+
+/*
+StartAutoSquareOff checks every AutoSquareOffInterval for segments past
+their cutoff and squares off intraday positions of every user until ctx
+is done, runs are stored through DBLayer so a restart resumes pending
+segments instead of squaring off again & every instance of the service
+can run the scheduler
+*/
+func (s *trade) StartAutoSquareOff(ctx context.Context) {
+	ticker := time.NewTicker(AutoSquareOffInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.runAutoSquareOff(now)
+			}
+		}
+	}()
+}
+
+/*
+runs auto square off for every user & segment past cutoff on the trade
+date of now, a segment is run again for a user only if no earlier run
+of the day completed or is still running, at most AutoSquareOffMaxAttempts
+times
+*/
+func (s *trade) runAutoSquareOff(now time.Time) {
+	if !autoSquareOffMu.TryLock() {
+		logger.Log.Info("auto square off: previous run still in progress")
+		return
+	}
+	defer autoSquareOffMu.Unlock()
+
+	now = now.In(istLocation)
+	segments := dueSquareOffSegments(now)
+	if len(segments) == 0 {
+		return
+	}
+	tradeDate := now.Format(TradeDateLayout)
+
+	runs, err := s.dbObj.GetSquareOffRuns(tradeDate)
+	if err != nil {
+		logger.Log.Error("auto square off: failed to fetch runs of the day", zap.Error(err), zap.String("date", tradeDate))
+		return
+	}
+	completed := make(map[string]bool)
+	running := make(map[string]bool)
+	attempts := make(map[string]int)
+	for _, run := range runs {
+		key := run.UserID + ":" + run.Segment
+		if run.Attempt > attempts[key] {
+			attempts[key] = run.Attempt
+		}
+		switch run.Status {
+		case SquareOffCompleted:
+			completed[key] = true
+		case SquareOffRunning:
+			running[key] = now.Sub(run.StartedAt) < AutoSquareOffRunTimeout
+		}
+	}
+
+	users, err := s.dbObj.GetSquareOffUsers()
+	if err != nil {
+		logger.Log.Error("auto square off: failed to fetch users", zap.Error(err))
+		return
+	}
+	for _, userId := range users {
+		for _, segment := range segments {
+			key := userId + ":" + segment
+			if completed[key] || running[key] || attempts[key] >= AutoSquareOffMaxAttempts {
+				continue
+			}
+			s.autoSquareOff(userId, segment, tradeDate, attempts[key]+1, now)
+		}
+	}
+}
+
+/*
+cancels open intraday orders of the segment, exits its bracket & cover
+orders and squares off the intraday positions left, every order
+cancelled, exited or placed is recorded in the run, re-running is safe
+as both steps start from the current OrderBook & PositionBook at the live
+broker, the attempt is claimed in DBLayer first so only one instance
+acts on it
+*/
+func (s *trade) autoSquareOff(userId, segment, tradeDate string, attempt int, now time.Time) {
+	c := userContext(userId)
+	run := squareoff.Run{
+		UserID:    userId,
+		Segment:   segment,
+		TradeDate: tradeDate,
+		Attempt:   attempt,
+		Status:    SquareOffRunning,
+		StartedAt: now,
+	}
+	id, claimed, err := s.dbObj.ClaimSquareOffRun(run)
+	if err != nil {
+		logger.Log.Error("auto square off: failed to claim run", zap.Error(err), zap.String("userId", userId), zap.String("segment", segment))
+		return
+	}
+	if !claimed {
+		logger.Log.Info("auto square off: run claimed by another instance", zap.String("userId", userId), zap.String("segment", segment), zap.Int("attempt", attempt))
+		return
+	}
+	run.ID = id
+
+	actions := make([]AutoSquareOffAction, 0)
+	failed := false
+
+	// positions at the broker are squared off even while the user trades on paper
+	broker := s.liveBroker()
+	orders, er := broker.OrderBook(c)
+	if er != nil {
+		logger.Log.Error("auto square off: failed to fetch orderBook", zap.Error(er), zap.String("userId", userId))
+		actions = append(actions, AutoSquareOffAction{Type: SquareOffCancelAction, Message: er.Err.Message})
+		failed = true
+	}
+	exited := make(map[string]bool)
+	for _, order := range orders {
+		orderStatus, _ := ParseOrderStatus(order.Status)
+		if orderStatus.Section() != Open || !strings.EqualFold(order.Segment, segment) || !intradayProduct(order.Product) {
+			continue
+		}
+		// legs of a bracket or cover order share the order number & are exited together
+		if exited[order.OrderNo] {
+			continue
+		}
+		action := AutoSquareOffAction{Type: SquareOffCancelAction, OrderNo: order.OrderNo, Symbol: order.Symbol, TxnType: order.TxnType, Quantity: order.RemainingQuantity}
+		ack, er := closeIntradayOrder(c, broker, order)
+		action.Message = ack.Message
+		if er != nil {
+			logger.Log.Error("auto square off: failed to cancel order", zap.Error(er), zap.String("orderNo", order.OrderNo))
+			failed = true
+		} else {
+			action.Status = true
+			exited[order.OrderNo] = true
+		}
+		actions = append(actions, action)
+	}
+
+	// positions are squared off only once no intraday order of the segment is left open
+	if !failed {
+		results, er := s.squareOffMatchingPositions(c, broker, func(position OrderPositionBook) bool {
+			return strings.EqualFold(position.Segment, segment) && intradayProduct(position.Product)
+		})
+		if er != nil {
+			logger.Log.Error("auto square off: failed to fetch positionBook", zap.Error(er), zap.String("userId", userId))
+			actions = append(actions, AutoSquareOffAction{Type: SquareOffOrderAction, Message: er.Err.Message})
+			failed = true
+		}
+		for _, result := range results {
+			action := AutoSquareOffAction{
+				Type:     SquareOffOrderAction,
+				OrderNo:  strings.Join(result.OrderNos, ","),
+				Symbol:   result.Symbol,
+				TxnType:  result.TxnType,
+				Quantity: result.Quantity,
+				Status:   result.Status,
+				Message:  result.Message,
+			}
+			if !result.Status {
+				failed = true
+				if len(action.Message) == 0 && len(result.Errors) > 0 {
+					action.Message = result.Errors[0].Message
+				}
+			}
+			actions = append(actions, action)
+		}
+	}
+
+	run.Status = SquareOffCompleted
+	if failed {
+		run.Status = SquareOffFailed
+	}
+	if byteData, err := json.Marshal(actions); err == nil {
+		run.Actions = string(byteData)
+	}
+	run.CompletedAt = time.Now()
+	if _, err := s.dbObj.SaveSquareOffRun(run); err != nil {
+		logger.Log.Error("auto square off: failed to store run result", zap.Error(err), zap.Int64("id", run.ID))
+	}
+	logger.Log.Info("auto square off run", zap.String("userId", userId), zap.String("segment", segment), zap.String("status", run.Status), zap.Int("actions", len(actions)))
+}
+
+// products squared off at cutoff, bracket & cover orders are intraday as well
+func intradayProduct(product string) bool {
+	return strings.EqualFold(product, IntradayProductValue) || strings.EqualFold(product, BoProductValue) || strings.EqualFold(product, CoProductValue)
+}
+
+/*
+closes an open intraday order, bracket & cover orders are exited so
+their stoploss & target legs go along with the main leg, a plain
+cancel would leave them at the broker
+*/
+func closeIntradayOrder(c *gin.Context, broker Broker, order BrokerOrder) (OrderAck, *BrokerError) {
+	request := CancelOrderRequest{
+		OrderNo:     order.OrderNo,
+		SerialNo:    order.SerialNo,
+		GroupId:     order.GroupId,
+		LegNo:       order.LegNo,
+		AlgoOrderNo: order.AlgoOrderNo,
+		TxnType:     order.TxnType,
+		Exchange:    order.Exchange,
+		Segment:     order.Segment,
+		Product:     order.Product,
+	}
+	switch {
+	case strings.EqualFold(order.Product, BoProductValue):
+		return broker.ExitOrder(c, BracketOrderKind, request)
+	case strings.EqualFold(order.Product, CoProductValue):
+		return broker.ExitOrder(c, CoverOrderKind, request)
+	}
+	return broker.CancelOrder(c, request)
+}
+
+// segments whose cutoff of the day has passed at now, segments of exchanges closed on the day are skipped
+func dueSquareOffSegments(now time.Time) []string {
+	segments := make([]string, 0, len(squareOffCutoffs))
+	for _, cutoff := range squareOffCutoffs {
+		if !marketCalendar.IsTradingDay(cutoff.exchange, now) {
+			continue
+		}
+		value := cutoff.cutoff
+		if configured := config.GetConfig().GetString("autoSquareOff.cutoff." + cutoff.name); len(configured) != 0 {
+			value = configured
+		}
+		at, err := time.ParseInLocation("15:04", value, istLocation)
+		if err != nil {
+			logger.Log.Error("auto square off: invalid cutoff", zap.String("segment", cutoff.name), zap.String("cutoff", value))
+			continue
+		}
+		at = time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, istLocation)
+		if !now.Before(at) {
+			segments = append(segments, cutoff.segment)
+		}
+	}
+	return segments
+}
+
+/*
+returns auto square off runs of the user with every order cancelled
+or placed, for trade date in query param date or today
+*/
+func (s *trade) AutoSquareOffRuns(c *gin.Context) {
+	var response AutoSquareOffRunsResponse
+
+	tradeDate := strings.TrimSpace(c.Query(TradeDateParam))
+	if len(tradeDate) == 0 {
+		tradeDate = time.Now().In(istLocation).Format(TradeDateLayout)
+	} else if _, err := time.Parse(TradeDateLayout, tradeDate); err != nil {
+		logger.Log.Error("Invalid trade date received", zap.String("date", tradeDate))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(":date must be in YYYY-MM-DD format"))
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+
+	userId := c.GetString("userId")
+	runs, err := s.dbObj.GetUserSquareOffRuns(userId, tradeDate)
+	if err != nil {
+		logger.Log.Error("Failed to fetch auto square off runs", zap.Error(err), zap.String("date", tradeDate))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+
+	response.Data = make([]AutoSquareOffRun, 0, len(runs))
+	for _, run := range runs {
+		item := AutoSquareOffRun{
+			ID:          run.ID,
+			Segment:     run.Segment,
+			TradeDate:   run.TradeDate,
+			Attempt:     run.Attempt,
+			Status:      run.Status,
+			StartedAt:   run.StartedAt,
+			CompletedAt: run.CompletedAt,
+		}
+		if len(run.Actions) != 0 {
+			if err := json.Unmarshal([]byte(run.Actions), &item.Actions); err != nil {
+				logger.Log.Error("Failed to unmarshal auto square off actions", zap.Error(err), zap.Int64("id", run.ID))
+			}
+		}
+		response.Data = append(response.Data, item)
+	}
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,264 @@
+package trade
+
+import (
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+/*
+places a basket of normal orders in one request
+
+steps within api
+  - apply trading session & risk rules to every leg and exposure limits to the legs together,
+    nothing is sent if any leg is invalid
+  - place legs concurrently, at most MaxBasketParallelism at a time
+  - if CancelOnFailure and any leg failed, cancel the legs still open at the broker
+    & square off the quantity of legs already filled
+  - reply with result of every leg, 207 if only some legs were placed
+*/
+func (s *trade) PlaceBasketOrder(c *gin.Context) {
+	var (
+		request  BasketOrderRequest
+		response BasketOrderResponse
+	)
+	//  validating the request payload via gin framework
+	if err := bindOrderJSON(c, &request); err != nil {
+		logger.Log.Error("Invalid arguement received", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+	// blocking new orders while a kill switch is engaged for any leg
+	segments := make([]string, 0, len(request.Legs))
+	for _, leg := range request.Legs {
+		segments = append(segments, leg.Segment)
+	}
+	if status, details, blocked := s.killSwitchRejection(c.GetString("userId"), segments...); blocked {
+		response.Errors = append(response.Errors, details)
+		c.JSON(status, response)
+		c.Abort()
+		return
+	}
+	if len(request.Legs) > MaxBasketLegs {
+		logger.Log.Error("basket order has too many legs", zap.Int("legs", len(request.Legs)))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":basket cannot have more than %d legs", MaxBasketLegs)))
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+	// validating every leg before placing any of them, legs outside trading session are rejected or converted to AMO
+	fieldErrors := make([]FieldError, 0)
+	rejection := http.StatusBadRequest
+	rejectLeg := func(i int, err error) {
+		logger.Log.Error("validation Failed for basket leg,", zap.Int("leg", i+1), zap.Error(err))
+		details := e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":leg %d%s", i+1, err.Error()))
+		// data the rules or exposure limits could not fetch fails the basket with its own status, the leg is not at fault
+		if status := validationStatus(err); status != http.StatusBadRequest {
+			rejection, details = status, validationDetails(status, err)
+			setValidationRetryAfter(c, err)
+		}
+		response.Errors = append(response.Errors, details)
+		fieldErrors = append(fieldErrors, prefixFieldErrors(fmt.Sprintf("legs[%d]", i), fieldErrorsOf(err))...)
+	}
+	orders := make([]RiskOrder, len(request.Legs))
+	for i := range request.Legs {
+		err := applyTradingSession(&request.Legs[i])
+		if err == nil {
+			orders[i] = normalRiskOrder(request.Legs[i])
+			orders[i].PriceConfirmed = request.PriceConfirmed
+			err = riskEngine.Evaluate(orders[i], s)
+		}
+		if err != nil {
+			rejectLeg(i, err)
+		}
+	}
+	// exposure of the basket is the sum of its legs, checked once every leg passed risk rules
+	if len(response.Errors) == 0 {
+		for i, err := range s.checkCumulativeExposure(c, orders) {
+			if err != nil {
+				rejectLeg(i, err)
+			}
+		}
+	}
+	if len(response.Errors) > 0 {
+		response.FieldErrors = fieldErrors
+		c.JSON(rejection, response)
+		c.Abort()
+		return
+	}
+
+	results := s.placeBasketLegs(c, request.Legs)
+
+	status := basketStatus(results)
+	if status != http.StatusOK && request.CancelOnFailure {
+		s.rollbackBasketLegs(c, request.Legs, results)
+	}
+
+	response.Data = results
+	response.Status = status == http.StatusOK
+	if status >= http.StatusBadRequest {
+		for i := range results {
+			if results[i].failure != nil {
+				setRetryAfter(c, results[i].failure)
+			}
+		}
+	}
+	c.JSON(status, response)
+	if status >= http.StatusBadRequest {
+		c.Abort()
+	}
+}
+
+/*
+status of the basket by outcome of its legs, 200 if every leg was
+placed, 207 if some legs were placed & status of the worst broker
+failure if no leg was placed
+*/
+func basketStatus(results []BasketLegResult) int {
+	failures := make([]*BrokerError, 0, len(results))
+	for i := range results {
+		if !results[i].Status {
+			failures = append(failures, results[i].failure)
+		}
+	}
+	return partialStatus(len(results), failures)
+}
+
+// status of a request acting on total orders or positions of which failures did not go through, nil failures count as 400
+func partialStatus(total int, failures []*BrokerError) int {
+	switch len(failures) {
+	case 0:
+		return http.StatusOK
+	case total:
+		status := http.StatusBadRequest
+		for _, failure := range failures {
+			if failure != nil && brokerErrorStatus(failure) > status {
+				status = brokerErrorStatus(failure)
+			}
+		}
+		return status
+	}
+	return http.StatusMultiStatus
+}
+
+// places legs concurrently with bounded parallelism, results keep the order of legs
+func (s *trade) placeBasketLegs(c *gin.Context, legs []PlaceOrderRequest) []BasketLegResult {
+	results := make([]BasketLegResult, len(legs))
+	sem := make(chan struct{}, MaxBasketParallelism)
+	wg := new(sync.WaitGroup)
+
+	for i, leg := range legs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, leg PlaceOrderRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := BasketLegResult{Leg: i + 1}
+			ack, err := s.broker(c).PlaceOrder(c, leg)
+			result.Message = ack.Message
+			if err != nil {
+				result.Errors = append(result.Errors, err.Err)
+				result.failure = err
+			} else {
+				result.Status = true
+				result.Data = ack.Data
+				if len(ack.OrderNos) > 0 {
+					result.OrderNo = ack.OrderNos[0]
+				}
+			}
+			results[i] = result
+		}(i, leg)
+	}
+	wg.Wait()
+	return results
+}
+
+/*
+rolls back the placed legs, legs still open at the broker are cancelled
+& filled quantity of any leg is squared off with an opposing market
+order, legs failing to roll back are reported in the leg result
+*/
+func (s *trade) rollbackBasketLegs(c *gin.Context, legs []PlaceOrderRequest, results []BasketLegResult) {
+	book, err := s.broker(c).OrderBook(c)
+	if err != nil {
+		logger.Log.Error("basket rollback: failed to fetch orderBook", zap.Error(err))
+		for i := range results {
+			if results[i].Status {
+				results[i].Errors = append(results[i].Errors, err.Err)
+			}
+		}
+		return
+	}
+	orders := make(map[string]BrokerOrder, len(book))
+	for _, order := range book {
+		orders[order.OrderNo] = order
+	}
+
+	for i := range results {
+		if !results[i].Status {
+			continue
+		}
+		// a placed leg the order book does not show yet cannot be rolled back, the client has to check it
+		order, ok := orders[results[i].OrderNo]
+		if len(results[i].OrderNo) == 0 || !ok {
+			logger.Log.Error("basket rollback: placed leg not found in orderBook", zap.Int("leg", results[i].Leg), zap.String("orderNo", results[i].OrderNo))
+			results[i].Errors = append(results[i].Errors, e.ErrorInfo["NoDataFound"].GetErrorDetails(":placed leg not found in orderBook, not rolled back, please check the order book"))
+			continue
+		}
+		leg := legs[i]
+		orderStatus, _ := ParseOrderStatus(order.Status)
+		if orderStatus.Section() == Open {
+			_, er := s.broker(c).CancelOrder(c, CancelOrderRequest{
+				OrderNo:  order.OrderNo,
+				SerialNo: order.SerialNo,
+				GroupId:  order.GroupId,
+				TxnType:  leg.TxnType,
+				Exchange: leg.Exchange,
+				Segment:  leg.Segment,
+				Product:  leg.Product,
+			})
+			if er != nil {
+				logger.Log.Error("basket rollback: failed to cancel leg", zap.String("orderNo", order.OrderNo), zap.Error(er))
+				results[i].Errors = append(results[i].Errors, er.Err)
+				continue
+			}
+			results[i].Cancelled = true
+		}
+		if order.TradedQty <= 0 {
+			continue
+		}
+
+		// filled quantity is closed on the opposite side through the square off path
+		unwind := PlaceOrderRequest{
+			TxnType:       SELL,
+			Exchange:      leg.Exchange,
+			Segment:       leg.Segment,
+			Product:       leg.Product,
+			ExchangeToken: leg.ExchangeToken,
+			Quantity:      order.TradedQty,
+			Validity:      DayValidity,
+			OrderType:     MarketOrder,
+		}
+		if leg.TxnType == SELL {
+			unwind.TxnType = BUY
+		}
+		orderNos, message, er := s.placeSquareOffOrder(c, s.broker(c), unwind)
+		results[i].UnwindOrderNos = orderNos
+		if er != nil {
+			logger.Log.Error("basket rollback: failed to square off filled leg", zap.String("orderNo", order.OrderNo), zap.Int("tradedQty", order.TradedQty), zap.Error(er))
+			results[i].Message = message
+			results[i].Errors = append(results[i].Errors, er.Err)
+			continue
+		}
+		results[i].Unwound = true
+	}
+}
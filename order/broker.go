@@ -0,0 +1,184 @@
+package trade
+
+import (
+	config "equity-trading/pkg/config"
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// config key selecting the broker orders are routed to, rupeeseed when not set
+const BrokerConfig = "broker.name"
+
+/*
+Broker is the order management system orders of the user are routed
+to, requests & results are domain types of the service so handlers,
+risk checks & schedulers do not depend on the api of a vendor
+
+failures are returned as *BrokerError with the http status the call is
+replied with, BadRequest for orders rejected by the broker
+*/
+type Broker interface {
+	PlaceOrder(c *gin.Context, request PlaceOrderRequest) (OrderAck, *BrokerError)
+	PlaceBracketOrder(c *gin.Context, request PlaceBracketOrderRequest) (OrderAck, *BrokerError)
+	PlaceCoverOrder(c *gin.Context, request PlaceCoverOrderRequest) (OrderAck, *BrokerError)
+	// kind is NormalOrderKind, BracketOrderKind or CoverOrderKind
+	ModifyOrder(c *gin.Context, kind string, request ModifyOrderRequest) (OrderAck, *BrokerError)
+	CancelOrder(c *gin.Context, request CancelOrderRequest) (OrderAck, *BrokerError)
+	// exits bracket & cover orders, kind is BracketOrderKind or CoverOrderKind
+	ExitOrder(c *gin.Context, kind string, request CancelOrderRequest) (OrderAck, *BrokerError)
+	OrderBook(c *gin.Context) ([]BrokerOrder, *BrokerError)
+	OrderHistory(c *gin.Context, orderNo string) ([]BrokerOrderEvent, *BrokerError)
+	TradeBook(c *gin.Context) ([]BrokerTrade, *BrokerError)
+	PositionBook(c *gin.Context) ([]OrderPositionBook, *BrokerError)
+	ConvertPosition(c *gin.Context, request ConvertPositionRequest) (OrderAck, *BrokerError)
+}
+
+/*
+BrokerError is a failure of a broker, Err is returned to the client with
+Status as http status of the reply and RetryAfter, when set, is sent as
+Retry-After of the reply
+*/
+type BrokerError struct {
+	Err        e.Error
+	Status     int
+	RetryAfter time.Duration
+}
+
+func (b *BrokerError) Error() string {
+	return b.Err.Message
+}
+
+// failure of a broker replied with status
+func brokerError(status int, er e.Error) *BrokerError {
+	return &BrokerError{Err: er, Status: status}
+}
+
+/*
+OrderAck is the reply of the broker to an order entry, Data is
+returned to the client as is, message is set on failures as well
+*/
+type OrderAck struct {
+	OrderNos []string
+	Message  string
+	Data     interface{}
+}
+
+/*
+BrokerOrder is an order of OrderBook, bracket & cover orders have
+an order per leg under the same order number
+*/
+type BrokerOrder struct {
+	OrderBook
+	GroupId int
+	OptType string
+	// leg of a bracket or cover order & algo order number of a bracket order, required to exit them
+	LegNo       int
+	AlgoOrderNo string
+}
+
+// BrokerTrade is an exchange fill of TradeBook
+type BrokerTrade struct {
+	TradeBook
+	OptType string
+}
+
+// BrokerOrderEvent is a state change of an order
+type BrokerOrderEvent struct {
+	OrderNo           string
+	SerialNo          int
+	Status            string
+	Quantity          int
+	TradedQty         int
+	RemainingQuantity int
+	Price             float64
+	TriggerPrice      float64
+	ErrorCode         string
+	ReasonDescription string
+	LastUpdatedTime   string
+}
+
+// builds the broker for a request of the service
+type brokerFactory func(s *trade) Broker
+
+const RupeeseedBroker = "rupeeseed"
+
+var brokerFactories = map[string]brokerFactory{
+	RupeeseedBroker: func(s *trade) Broker { return &rupeeseedBroker{rest: s.restCaller} },
+}
+
+// registers a broker selectable through config broker.name, called from init
+func registerBroker(name string, factory brokerFactory) {
+	brokerFactories[name] = factory
+}
+
+/*
+broker the request of the user is routed to, paper trading users are
+routed to the paper broker, others to the broker configured in
+broker.name, an unknown name is logged and orders are routed to rupeeseed
+
+a trading mode that cannot be read from redis fails the call as a kill
+switch that cannot be read blocks the order, config killSwitch.failOpen
+routes the user to the configured broker instead
+*/
+func (s *trade) broker(c *gin.Context) Broker {
+	if userId := c.GetString("userId"); len(userId) != 0 {
+		paper, err := s.paperTrading(userId)
+		if err != nil {
+			if !config.GetConfig().GetBool(KillSwitchFailOpenConfig) {
+				logger.Log.Error("trading mode unavailable, call refused", zap.Error(err), zap.String("userId", userId))
+				return unavailableBroker{}
+			}
+			logger.Log.Warn("trading mode unavailable, routed to configured broker", zap.Error(err), zap.String("userId", userId))
+		}
+		if paper {
+			return brokerFactories[PaperBroker](s)
+		}
+	}
+	return s.liveBroker()
+}
+
+// broker configured in broker.name that live orders of every user are sent to
+func (s *trade) liveBroker() Broker {
+	name := config.GetConfig().GetString(BrokerConfig)
+	if len(name) == 0 {
+		name = RupeeseedBroker
+	}
+	factory, ok := brokerFactories[name]
+	if !ok {
+		logger.Log.Error("unknown broker configured, using rupeeseed", zap.String("broker", name))
+		factory = brokerFactories[RupeeseedBroker]
+	}
+	return factory(s)
+}
+
+// http status of a broker failure, failures without a status are internal errors
+func brokerErrorStatus(er *BrokerError) int {
+	if er.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return er.Status
+}
+
+// details of an error pkg/errors has no code for, details of fallback are sent under name
+func namedErrorDetails(name, fallback, msg string) e.Error {
+	if info, ok := e.ErrorInfo[name]; ok {
+		return info.GetErrorDetails(msg)
+	}
+	info := e.ErrorInfo[fallback]
+	info.ErrName = name
+	return info.GetErrorDetails(msg)
+}
+
+// sets Retry-After of the reply in seconds when the broker asks for the call to be sent later
+func setRetryAfter(c *gin.Context, er *BrokerError) {
+	if er.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(er.RetryAfter.Seconds()))))
+	}
+}
@@ -0,0 +1,286 @@
+package trade
+
+import (
+	config "equity-trading/pkg/config"
+	"equity-trading/pkg/logger"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// exchange timings are in IST irrespective of the server time zone
+var istLocation = time.FixedZone("IST", 5*60*60+30*60)
+
+// MarketPhase is the state of trading session of an exchange segment
+type MarketPhase string
+
+const (
+	PreOpenPhase     MarketPhase = "PRE_OPEN"
+	OpenPhase        MarketPhase = "OPEN"
+	AfterMarketPhase MarketPhase = "AFTER_MARKET"
+	ClosedPhase      MarketPhase = "CLOSED"
+)
+
+/*
+OffMktOrderTimeFlag values of AMO(after market order), the time
+at which the broker sends the order to exchange on the next session
+
+	1 | pre-open session, only segments with a pre-open session
+	2 | market open
+	3 | 15 minutes after market open
+*/
+const (
+	AMOPreOpen    = 1
+	AMOMarketOpen = 2
+	AMOPostOpen   = 3
+
+	// config key enabling conversion of orders outside session to AMO
+	AutoAMOConfig = "calendar.autoAMO"
+)
+
+/*
+TradingSession is the daily schedule of an exchange segment in IST,
+AMO window starts after close & ends on the next day before pre-open
+or open, pre-open is empty for segments without it
+*/
+type TradingSession struct {
+	PreOpenStart string
+	PreOpenEnd   string
+	Open         string
+	Close        string
+	AMOStart     string
+	AMOEnd       string
+}
+
+func (t TradingSession) hasPreOpen() bool {
+	return len(t.PreOpenStart) != 0
+}
+
+/*
+sessions by segment, an exchange specific session is keyed as
+<exchange>:<segment> & takes precedence over the segment session
+*/
+var defaultTradingSessions = map[string]TradingSession{
+	EquitySegment:     {PreOpenStart: "09:00", PreOpenEnd: "09:08", Open: "09:15", Close: "15:30", AMOStart: "15:45", AMOEnd: "08:57"},
+	DerivativeSegment: {Open: "09:15", Close: "15:30", AMOStart: "15:45", AMOEnd: "09:10"},
+	CurrencySegment:   {Open: "09:00", Close: "17:00", AMOStart: "17:15", AMOEnd: "08:55"},
+	CommoditySegment:  {Open: "09:00", Close: "23:30", AMOStart: "23:45", AMOEnd: "08:55"},
+}
+
+var ErrUnknownSession = errors.New("no trading session for exchange segment")
+
+/*
+MarketCalendar knows trading sessions & holidays of every exchange,
+holidays are read from config calendar.holidays.<exchange> as
+YYYY-MM-DD dates, saturday & sunday are always closed
+*/
+type MarketCalendar struct {
+	sessions map[string]TradingSession
+	now      func() time.Time
+}
+
+func NewMarketCalendar(sessions map[string]TradingSession) *MarketCalendar {
+	return &MarketCalendar{sessions: sessions, now: time.Now}
+}
+
+// calendar used by placement handlers & risk rules
+var marketCalendar = NewMarketCalendar(defaultTradingSessions)
+
+// Now is the current time in IST
+func (m *MarketCalendar) Now() time.Time {
+	return m.now().In(istLocation)
+}
+
+// Session of the exchange segment
+func (m *MarketCalendar) Session(exchange, segment string) (TradingSession, error) {
+	if session, ok := m.sessions[strings.ToUpper(exchange)+":"+segment]; ok {
+		return session, nil
+	}
+	if session, ok := m.sessions[segment]; ok {
+		return session, nil
+	}
+	return TradingSession{}, fmt.Errorf("%w: %s %s", ErrUnknownSession, exchange, segment)
+}
+
+// IsTradingDay reports if exchange trades on the date of t
+func (m *MarketCalendar) IsTradingDay(exchange string, t time.Time) bool {
+	t = t.In(istLocation)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	date := t.Format(TradeDateLayout)
+	for _, holiday := range config.GetConfig().GetStringSlice("calendar.holidays." + strings.ToUpper(exchange)) {
+		if strings.TrimSpace(holiday) == date {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Phase of the exchange segment at t, AMO window stays open through
+holidays & weekends till the AMO end of the next trading day
+*/
+func (m *MarketCalendar) Phase(exchange, segment string, t time.Time) (MarketPhase, error) {
+	session, err := m.Session(exchange, segment)
+	if err != nil {
+		return ClosedPhase, err
+	}
+	t = t.In(istLocation)
+	clock := t.Format("15:04")
+
+	if !m.IsTradingDay(exchange, t) {
+		return AfterMarketPhase, nil
+	}
+	switch {
+	case session.hasPreOpen() && clock >= session.PreOpenStart && clock < session.PreOpenEnd:
+		return PreOpenPhase, nil
+	case clock >= session.Open && clock < session.Close:
+		return OpenPhase, nil
+	case clock >= session.AMOStart || clock < session.AMOEnd:
+		return AfterMarketPhase, nil
+	}
+	return ClosedPhase, nil
+}
+
+// NextOpen is the start of the next regular session of the exchange segment after t
+func (m *MarketCalendar) NextOpen(exchange, segment string, t time.Time) (time.Time, error) {
+	session, err := m.Session(exchange, segment)
+	if err != nil {
+		return time.Time{}, err
+	}
+	open, err := time.ParseInLocation("15:04", session.Open, istLocation)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t = t.In(istLocation)
+	day := time.Date(t.Year(), t.Month(), t.Day(), open.Hour(), open.Minute(), 0, 0, istLocation)
+	// a year of closed days is not expected, the bound keeps a bad config from looping forever
+	for i := 0; i < 366; i++ {
+		if day.After(t) && m.IsTradingDay(exchange, day) {
+			return day, nil
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}, fmt.Errorf("no trading day in a year for %s %s", exchange, segment)
+}
+
+/*
+fields of an order decided by the trading session, the flags point into
+the request so an order in the AMO window can be converted to AMO
+*/
+type sessionOrder struct {
+	exchange            string
+	segment             string
+	orderType           string
+	offMktFlag          *bool
+	offMktOrderTimeFlag *int
+}
+
+// applies trading session to a normal order, a basket leg or a square off order
+func applyTradingSession(request *PlaceOrderRequest) error {
+	return applySession(sessionOrder{
+		exchange:            request.Exchange,
+		segment:             request.Segment,
+		orderType:           request.OrderType,
+		offMktFlag:          &request.OffMktFlag,
+		offMktOrderTimeFlag: &request.OffMktOrderTimeFlag,
+	})
+}
+
+func applyBracketTradingSession(request *PlaceBracketOrderRequest) error {
+	return applySession(sessionOrder{
+		exchange:            request.Exchange,
+		segment:             request.Segment,
+		orderType:           request.OrderType,
+		offMktFlag:          &request.OffMktFlag,
+		offMktOrderTimeFlag: &request.OffMktOrderTimeFlag,
+	})
+}
+
+func applyCoverTradingSession(request *PlaceCoverOrderRequest) error {
+	return applySession(sessionOrder{
+		exchange:            request.Exchange,
+		segment:             request.Segment,
+		orderType:           request.OrderType,
+		offMktFlag:          &request.OffMktFlag,
+		offMktOrderTimeFlag: &request.OffMktOrderTimeFlag,
+	})
+}
+
+/*
+applies trading session of the exchange segment to an order, orders
+during the session are left as is, orders in the AMO window are
+converted to AMO at market open when calendar.autoAMO is set, every
+other order is rejected with the next session time, AMO orders are
+validated by offMarketOrder rule of the risk engine
+*/
+func applySession(order sessionOrder) error {
+	if *order.offMktFlag {
+		return nil
+	}
+	now := marketCalendar.Now()
+	phase, err := marketCalendar.Phase(order.exchange, order.segment, now)
+	if err != nil {
+		logger.Log.Error("trading session not found", zap.Error(err))
+		return fmt.Errorf(":no trading session for exchange %s segment %s", order.exchange, order.segment)
+	}
+
+	switch phase {
+	case OpenPhase:
+		return nil
+	case PreOpenPhase:
+		if order.orderType == SL || order.orderType == SLM {
+			return fieldError(OrderTypeField, "notAllowed", "trigger orders are not accepted in pre-open session", "phase", string(PreOpenPhase))
+		}
+		return nil
+	case AfterMarketPhase:
+		if config.GetConfig().GetBool(AutoAMOConfig) {
+			logger.Log.Info("converting order outside trading session to AMO", zap.String("exchange", order.exchange), zap.String("segment", order.segment))
+			*order.offMktFlag = true
+			*order.offMktOrderTimeFlag = AMOMarketOpen
+			return nil
+		}
+	}
+
+	next, err := marketCalendar.NextOpen(order.exchange, order.segment, now)
+	if err != nil {
+		return errors.New(":market is closed")
+	}
+	return fmt.Errorf(":market is closed, next session opens at %s, place as AMO with OffMktFlag", next.Format("02 Jan 15:04"))
+}
+
+/*
+AMO is accepted only in the AMO window & OffMktOrderTimeFlag must be one of
+AMOPreOpen, AMOMarketOpen or AMOPostOpen, AMOPreOpen only for segments
+with a pre-open session
+*/
+func checkOffMarketOrder(order RiskOrder, _ MarketData) error {
+	if !order.OffMktFlag {
+		return nil
+	}
+	switch order.OffMktOrderTimeFlag {
+	case AMOPreOpen:
+		session, err := marketCalendar.Session(order.Exchange, order.Segment)
+		if err == nil && !session.hasPreOpen() {
+			return fieldError(OffMktOrderTimeFlagField, "notAllowed", "OffMktOrderTimeFlag 1 is allowed only for segments with pre-open session")
+		}
+	case AMOMarketOpen, AMOPostOpen:
+	default:
+		return fieldError(OffMktOrderTimeFlagField, "oneof", "OffMktOrderTimeFlag possible allowed values are 1|2|3 in AMO", "allowed", "1|2|3")
+	}
+	if order.Deferred {
+		return nil
+	}
+	phase, err := marketCalendar.Phase(order.Exchange, order.Segment, marketCalendar.Now())
+	if err != nil {
+		return fmt.Errorf(":no trading session for exchange %s segment %s", order.Exchange, order.Segment)
+	}
+	if phase != AfterMarketPhase {
+		return errors.New(":AMO can be placed only after market hours")
+	}
+	return nil
+}
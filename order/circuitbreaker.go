@@ -0,0 +1,174 @@
+package trade
+
+import (
+	"equity-trading/pkg/logger"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	CircuitClosed   = "CLOSED"
+	CircuitOpen     = "OPEN"
+	CircuitHalfOpen = "HALF_OPEN"
+
+	// consecutive failures of an endpoint opening its circuit
+	CircuitFailureThreshold = 5
+	// time an open circuit fails fast before a probe is let through
+	CircuitOpenDuration = 30 * time.Second
+)
+
+/*
+circuitBreaker tracks health of a vendor endpoint, calls fail fast while
+the circuit is open, once CircuitOpenDuration passes a single probe is
+let through in half open state, success of the probe closes the circuit
+& failure opens it again
+*/
+type circuitBreaker struct {
+	mu       sync.Mutex
+	endpoint string
+	state    string
+	failures int
+	openedAt time.Time
+	// a probe of the half open circuit is in flight
+	probing bool
+}
+
+// breakers of vendor endpoints keyed by api
+var circuitBreakers = struct {
+	sync.Mutex
+	breakers map[string]*circuitBreaker
+}{breakers: make(map[string]*circuitBreaker)}
+
+func circuitBreakerFor(endpoint string) *circuitBreaker {
+	circuitBreakers.Lock()
+	defer circuitBreakers.Unlock()
+	breaker, ok := circuitBreakers.breakers[endpoint]
+	if !ok {
+		breaker = &circuitBreaker{endpoint: endpoint, state: CircuitClosed}
+		circuitBreakers.breakers[endpoint] = breaker
+	}
+	return breaker
+}
+
+// whether a call to the endpoint may be sent, false while the circuit is open
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < CircuitOpenDuration {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		logger.Log.Warn("circuit half open, probing endpoint", zap.String("endpoint", b.endpoint))
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// time until the circuit lets a probe through, at least a second while a probe is in flight
+func (b *circuitBreaker) retryAfter(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if wait := CircuitOpenDuration - now.Sub(b.openedAt); wait > time.Second {
+		return wait
+	}
+	return time.Second
+}
+
+// records outcome of a call let through by allow
+func (b *circuitBreaker) record(success bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if success {
+		if b.state != CircuitClosed {
+			logger.Log.Warn("circuit closed, endpoint recovered", zap.String("endpoint", b.endpoint))
+		}
+		b.state = CircuitClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= CircuitFailureThreshold {
+		if b.state != CircuitOpen {
+			logger.Log.Error("circuit opened, failing fast", zap.String("endpoint", b.endpoint), zap.Int("failures", b.failures))
+		}
+		b.state = CircuitOpen
+		b.openedAt = now
+		b.probing = false
+	}
+}
+
+func (b *circuitBreaker) status() CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := CircuitBreakerStatus{
+		Endpoint: b.endpoint,
+		State:    b.state,
+		Failures: b.failures,
+		Probing:  b.probing,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+/*
+returns state of the circuit of every vendor endpoint called by this
+instance, route is expected to be behind admin authorization
+*/
+func (s *trade) CircuitBreakers(c *gin.Context) {
+	var response CircuitBreakersResponse
+	circuitBreakers.Lock()
+	breakers := make([]*circuitBreaker, 0, len(circuitBreakers.breakers))
+	for _, breaker := range circuitBreakers.breakers {
+		breakers = append(breakers, breaker)
+	}
+	circuitBreakers.Unlock()
+
+	response.Data = make([]CircuitBreakerStatus, 0, len(breakers))
+	for _, breaker := range breakers {
+		response.Data = append(response.Data, breaker.status())
+	}
+	sort.Slice(response.Data, func(i, j int) bool { return response.Data[i].Endpoint < response.Data[j].Endpoint })
+
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+/*
+retryPolicy of a vendor call, the zero value sends the call once,
+backoff is doubled after every attempt
+*/
+type retryPolicy struct {
+	attempts int
+	backoff  time.Duration
+}
+
+// read calls are safe to send again, order entries are never retried as the first may have reached the exchange
+var readRetryPolicy = retryPolicy{attempts: 3, backoff: 50 * time.Millisecond}
+
+// worst case time of a call sent as per the policy, every attempt running into timeout
+func (p retryPolicy) worstCase(timeout time.Duration) time.Duration {
+	total, backoff := timeout, p.backoff
+	for attempt := 1; attempt < p.attempts; attempt++ {
+		total += backoff + timeout
+		backoff *= 2
+	}
+	return total
+}
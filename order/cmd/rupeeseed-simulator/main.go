@@ -0,0 +1,23 @@
+/*
+rupeeseed-simulator serves the rupeeseed apis used by the trade service
+from memory, point rupeeseed.endpoint of the service to it for local
+development
+
+	rupeeseed-simulator -addr :8090
+*/
+package main
+
+import (
+	"e/order/simulator"
+	"flag"
+	"log"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "address the simulator listens on")
+	flag.Parse()
+
+	sim := simulator.New()
+	log.Printf("rupeeseed simulator listening on %s", *addr)
+	log.Fatal(sim.ListenAndServe(*addr))
+}
@@ -0,0 +1,194 @@
+package trade
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+/*
+values of order fields accepted by rupeeseed keyed by binding tag, tags
+are prefixed with order_ as they are only known to orderValidator
+*/
+var orderEnumTags = map[string][]string{
+	"order_txntype":   {BUY, SELL},
+	"order_exchange":  {"NSE", "BSE", "MCX", "NCDEX"},
+	"order_segment":   {EquitySegment, DerivativeSegment, CurrencySegment, CommoditySegment},
+	"order_product":   {DeliveryProductValue, IntradayProductValue, "M", BoProductValue, CoProductValue},
+	"order_validity":  {DAY, IOC},
+	"order_ordertype": {LMT, MKT, SL, SLM},
+}
+
+/*
+request structs sharing these fields are checked at struct level as
+they are declared with plain string fields, empty values are left to
+the required tag of the field or are optional as filters of square off,
+structs embedding or nesting a registered request are checked with it
+*/
+var orderEnumFields = []struct {
+	name string
+	tag  string
+}{
+	{name: "TxnType", tag: "order_txntype"},
+	{name: "Exchange", tag: "order_exchange"},
+	{name: "Segment", tag: "order_segment"},
+	{name: "Product", tag: "order_product"},
+	{name: "Validity", tag: "order_validity"},
+	{name: "OrderType", tag: "order_ordertype"},
+}
+
+/*
+orderValidator checks requests bound by handlers of this package, it is
+kept apart from the validator of gin binding so field names & tags of
+orders do not leak into other services sharing gin
+*/
+var orderValidator = newOrderValidator()
+
+// orderBinding decodes json like binding.JSON & validates with orderValidator
+var orderBinding binding.BindingBody = orderJSONBinding{}
+
+func newOrderValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	// field errors are named by the json key of the field
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		if field.Anonymous {
+			return embeddedFieldName
+		}
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || len(name) == 0 {
+			return field.Name
+		}
+		return name
+	})
+	for tag, values := range orderEnumTags {
+		values := values
+		err := v.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			return isEnumValue(values, fl.Field().String())
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+	v.RegisterStructValidation(validateOrderEnums, PlaceOrderRequest{}, ModifyOrderRequest{}, PlaceBracketOrderRequest{}, PlaceCoverOrderRequest{},
+		ConvertPositionRequest{})
+	v.RegisterStructValidation(validateSquareOffFilters, SquareOffRequest{})
+	return v
+}
+
+func isEnumValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func validateOrderEnums(sl validator.StructLevel) {
+	checkOrderEnums(sl, func(value string) string { return value })
+}
+
+// filters of square off match positions ignoring case
+func validateSquareOffFilters(sl validator.StructLevel) {
+	checkOrderEnums(sl, strings.ToUpper)
+}
+
+func checkOrderEnums(sl validator.StructLevel, normalize func(string) string) {
+	current := sl.Current()
+	for _, enumField := range orderEnumFields {
+		name, tag := enumField.name, enumField.tag
+		field, ok := current.Type().FieldByName(name)
+		if !ok || field.Type.Kind() != reflect.String {
+			continue
+		}
+		value := current.FieldByIndex(field.Index).String()
+		if len(value) == 0 || isEnumValue(orderEnumTags[tag], normalize(value)) {
+			continue
+		}
+		jsonName := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if len(jsonName) == 0 {
+			jsonName = name
+		}
+		sl.ReportError(value, jsonName, name, tag, "")
+	}
+}
+
+type orderJSONBinding struct{}
+
+func (orderJSONBinding) Name() string {
+	return "json"
+}
+
+func (b orderJSONBinding) Bind(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return errors.New("invalid request")
+	}
+	return b.decode(req.Body, obj)
+}
+
+func (b orderJSONBinding) BindBody(body []byte, obj interface{}) error {
+	return b.decode(bytes.NewReader(body), obj)
+}
+
+// decoder honours the same settings as binding.JSON
+func (orderJSONBinding) decode(r io.Reader, obj interface{}) error {
+	decoder := json.NewDecoder(r)
+	if binding.EnableDecoderUseNumber {
+		decoder.UseNumber()
+	}
+	if binding.EnableDecoderDisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	return orderValidator.Struct(obj)
+}
+
+// binds the json body of an order request, aborts with 400 on failure like c.BindJSON
+func bindOrderJSON(c *gin.Context, obj interface{}) error {
+	return c.MustBindWith(obj, orderBinding)
+}
+
+/*
+message of a binding failure naming the invalid fields, empty for
+malformed json so the generic BadRequest message is kept
+*/
+func bindingErrorMessage(err error) string {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return ""
+	}
+	messages := make([]string, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		messages = append(messages, fieldErrorMessage(fe))
+	}
+	return ":" + strings.Join(messages, ", ")
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	if values, ok := orderEnumTags[fe.Tag()]; ok {
+		return fmt.Sprintf("%s must be one of %s", fe.Field(), strings.Join(values, "|"))
+	}
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fe.Field())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", fe.Field(), strings.ReplaceAll(fe.Param(), " ", "|"))
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", fe.Field(), fe.Param())
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", fe.Field(), fe.Param())
+	}
+	return fmt.Sprintf("%s is invalid", fe.Field())
+}
@@ -0,0 +1,195 @@
+package trade
+
+import (
+	config "equity-trading/pkg/config"
+	"equity-trading/pkg/logger"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+/*
+concentration limits enforced on top of the margin check of the broker,
+zero disables a limit
+
+	exposure.<segment>.maxNetQty        | net quantity per symbol
+	exposure.<segment>.maxGrossExposure | value of positions & open orders of the segment
+	exposure.maxOpenOrders              | open orders per user across segments
+*/
+type ExposureLimits struct {
+	MaxNetQty        int
+	MaxGrossExposure float64
+	MaxOpenOrders    int
+}
+
+func (l ExposureLimits) disabled() bool {
+	return l.MaxNetQty <= 0 && l.MaxGrossExposure <= 0.0 && l.MaxOpenOrders <= 0
+}
+
+func exposureLimits(segment string) ExposureLimits {
+	cfg := config.GetConfig()
+	return ExposureLimits{
+		MaxNetQty:        cfg.GetInt("exposure." + segment + ".maxNetQty"),
+		MaxGrossExposure: cfg.GetFloat64("exposure." + segment + ".maxGrossExposure"),
+		MaxOpenOrders:    cfg.GetInt("exposure.maxOpenOrders"),
+	}
+}
+
+/*
+checks the position a new order would produce against exposure limits
+of its segment, PositionBook & OrderBook are read from the broker only
+when a limit is configured
+*/
+func (s *trade) checkExposureLimits(c *gin.Context, order RiskOrder) error {
+	return s.checkCumulativeExposure(c, []RiskOrder{order})[0]
+}
+
+/*
+checks orders of one request i.e legs of a basket in sequence against
+exposure limits, every accepted order is counted as an open order when
+the next one is checked so the request as a whole stays within limits,
+PositionBook & OrderBook are read once
+
+	output:
+		rejection of every order, nil for accepted orders, *BrokerError
+		if the books could not be read
+*/
+func (s *trade) checkCumulativeExposure(c *gin.Context, orders []RiskOrder) []error {
+	var (
+		errs      = make([]error, len(orders))
+		positions []OrderPositionBook
+		open      []BrokerOrder
+		loadErr   *BrokerError
+		loaded    bool
+	)
+	for i, order := range orders {
+		limits := exposureLimits(order.Segment)
+		if limits.disabled() {
+			continue
+		}
+		if !loaded {
+			loaded = true
+			positions, open, loadErr = s.exposureBooks(c)
+		}
+		if loadErr != nil {
+			errs[i] = loadErr
+			continue
+		}
+		price := 0.0
+		if limits.MaxGrossExposure > 0.0 {
+			var err error
+			if price, err = s.previewPrice(order); err != nil {
+				errs[i] = err
+				continue
+			}
+		}
+		if errs[i] = evaluateExposure(order, price, limits, positions, open); errs[i] == nil {
+			open = append(open, pendingExposureOrder(order, price))
+		}
+	}
+	return errs
+}
+
+// books of the broker the limits are checked against, a failure is returned as the broker reported it
+func (s *trade) exposureBooks(c *gin.Context) ([]OrderPositionBook, []BrokerOrder, *BrokerError) {
+	positions, er := s.broker(c).PositionBook(c)
+	if er != nil {
+		logger.Log.Error("exposure: failed to fetch positionBook", zap.Error(er))
+		return nil, nil, er
+	}
+	orders, er := s.broker(c).OrderBook(c)
+	if er != nil {
+		logger.Log.Error("exposure: failed to fetch orderBook", zap.Error(er))
+		return nil, nil, er
+	}
+	return positions, orders, nil
+}
+
+// order accepted earlier in the request as an open order of OrderBook
+func pendingExposureOrder(order RiskOrder, price float64) BrokerOrder {
+	if price <= 0.0 {
+		price = order.Price
+	}
+	var pending BrokerOrder
+	pending.Status = Pending
+	pending.Exchange = order.Exchange
+	pending.Segment = order.Segment
+	pending.SecurityID = strconv.Itoa(order.ExchangeToken)
+	pending.TxnType = order.TxnType
+	pending.RemainingQuantity = order.Quantity
+	pending.Price = price
+	return pending
+}
+
+/*
+position of the symbol after the order is the net quantity of PositionBook
+with every open order on the side of the order assumed traded, orders
+reducing the position are checked only against the open orders limit
+*/
+func evaluateExposure(order RiskOrder, price float64, limits ExposureLimits, positions []OrderPositionBook, orders []BrokerOrder) error {
+	token := strconv.Itoa(order.ExchangeToken)
+	sameSymbol := func(exchange, securityID string) bool {
+		return strings.EqualFold(exchange, order.Exchange) && securityID == token
+	}
+
+	var (
+		netQty, openBuyQty, openSellQty, openOrders int
+		grossExposure                               float64
+	)
+	lastTradedPrice := make(map[string]float64)
+	for _, p := range positions {
+		lastTradedPrice[p.Exchange+":"+p.SecurityID] = p.LastTradedPrice
+		if sameSymbol(p.Exchange, p.SecurityID) {
+			netQty += p.NetQty
+		}
+		if strings.EqualFold(p.Segment, order.Segment) {
+			grossExposure += math.Abs(float64(p.NetQty)) * p.LastTradedPrice
+		}
+	}
+	for _, o := range orders {
+		orderStatus, _ := ParseOrderStatus(o.Status)
+		if orderStatus.Section() != Open {
+			continue
+		}
+		openOrders++
+		if sameSymbol(o.Exchange, o.SecurityID) {
+			if o.TxnType == BUY {
+				openBuyQty += o.RemainingQuantity
+			} else {
+				openSellQty += o.RemainingQuantity
+			}
+		}
+		if strings.EqualFold(o.Segment, order.Segment) {
+			openPrice := o.Price
+			if openPrice <= 0.0 {
+				openPrice = lastTradedPrice[o.Exchange+":"+o.SecurityID]
+			}
+			grossExposure += float64(o.RemainingQuantity) * openPrice
+		}
+	}
+
+	if limits.MaxOpenOrders > 0 && openOrders >= limits.MaxOpenOrders {
+		return &RiskRejection{Rule: "maxOpenOrders", Reason: fmt.Sprintf(":%d open orders, limit is %d", openOrders, limits.MaxOpenOrders)}
+	}
+
+	before, after := netQty+openBuyQty, netQty+openBuyQty+order.Quantity
+	if order.TxnType == SELL {
+		before, after = netQty-openSellQty, netQty-openSellQty-order.Quantity
+	}
+	if math.Abs(float64(after)) <= math.Abs(float64(before)) {
+		return nil
+	}
+	if limits.MaxNetQty > 0 && math.Abs(float64(after)) > float64(limits.MaxNetQty) {
+		return &RiskRejection{Rule: "maxNetQty", Reason: fmt.Sprintf(":net quantity of the symbol would be %d, limit is %d", after, limits.MaxNetQty)}
+	}
+	if limits.MaxGrossExposure > 0.0 {
+		if exposure := grossExposure + float64(order.Quantity)*price; exposure > limits.MaxGrossExposure {
+			return &RiskRejection{Rule: "maxGrossExposure", Reason: fmt.Sprintf(":gross exposure of the segment would be %.2f, limit is %.2f", roundAmount(exposure), limits.MaxGrossExposure)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package trade
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// json keys of order fields named by validation & risk rules
+const (
+	QuantityField            = "quantity"
+	PriceField               = "price"
+	TriggerPriceField        = "trigger_price"
+	ValidityField            = "validity"
+	OrderTypeField           = "order_type"
+	ExchangeTokenField       = "exchange_token"
+	ProfitValueField         = "profit_value"
+	StoplossValueField       = "stoploss_value"
+	OffMktOrderTimeFlagField = "off_mkt_order_time_flag"
+)
+
+// name given to embedded request structs in validation namespaces, their fields are flattened in json
+const embeddedFieldName = "~"
+
+/*
+FieldError is a machine readable validation failure, field is the json
+path of the offending input (empty when the order as a whole is
+rejected), code is the validation tag or rule name and params carry
+the values the message is built from so clients can localize it
+*/
+type FieldError struct {
+	Field   string            `json:"field"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// Error keeps the ":message" form appended to BadRequest details
+func (f *FieldError) Error() string {
+	return ":" + f.Message
+}
+
+// params are given as key, value pairs
+func fieldError(field, code, message string, params ...string) *FieldError {
+	fe := &FieldError{Field: field, Code: code, Message: message}
+	if len(params) > 1 {
+		fe.Params = make(map[string]string, len(params)/2)
+		for i := 0; i+1 < len(params); i += 2 {
+			fe.Params[params[i]] = params[i+1]
+		}
+	}
+	return fe
+}
+
+/*
+field errors of a binding, validation or risk engine failure, a rule
+rejecting the order without naming a field is reported with the rule
+name as code, nil for other errors
+*/
+func fieldErrorsOf(err error) []FieldError {
+	var (
+		fieldErrors validator.ValidationErrors
+		fe          *FieldError
+		rejection   *RiskRejection
+	)
+	switch {
+	case errors.As(err, &fieldErrors):
+		list := make([]FieldError, 0, len(fieldErrors))
+		for _, ve := range fieldErrors {
+			list = append(list, bindingFieldError(ve))
+		}
+		return list
+	case errors.As(err, &fe):
+		return []FieldError{*fe}
+	case errors.As(err, &rejection):
+		return []FieldError{{Code: rejection.Rule, Message: strings.TrimPrefix(rejection.Reason, ":")}}
+	}
+	return nil
+}
+
+func bindingFieldError(ve validator.FieldError) FieldError {
+	fe := FieldError{Field: ve.Field(), Code: ve.Tag(), Message: fieldErrorMessage(ve)}
+	// namespace starts with the request struct name, legs[1].txn_type is kept for nested fields
+	if namespace := ve.Namespace(); strings.Contains(namespace, ".") {
+		fe.Field = strings.ReplaceAll(namespace[strings.Index(namespace, ".")+1:], embeddedFieldName+".", "")
+	}
+	if values, ok := orderEnumTags[ve.Tag()]; ok {
+		fe.Params = map[string]string{"allowed": strings.Join(values, "|")}
+	} else if len(ve.Param()) != 0 {
+		fe.Params = map[string]string{"param": ve.Param()}
+	}
+	return fe
+}
+
+// field errors of a basket leg or other nested order are reported under prefix
+func prefixFieldErrors(prefix string, list []FieldError) []FieldError {
+	for i := range list {
+		if len(list[i].Field) == 0 {
+			list[i].Field = prefix
+			continue
+		}
+		list[i].Field = prefix + "." + list[i].Field
+	}
+	return list
+}
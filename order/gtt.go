@@ -0,0 +1,497 @@
+package trade
+
+import (
+	"context"
+	"encoding/json"
+	config "equity-trading/pkg/config"
+	"equity-trading/pkg/db/gtt"
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+/*
+places GTT(good till triggered) order, the order payload is stored
+through DBLayer and placed by the GTT evaluator once the last traded
+price crosses the trigger price from the side it was on at creation
+*/
+func (s *trade) PlaceGTTOrder(c *gin.Context) {
+	var (
+		request  GTTOrderRequest
+		response GTTOrderResponse
+	)
+	//  validating the request payload via gin framework
+	if err := bindOrderJSON(c, &request); err != nil {
+		logger.Log.Error("Invalid arguement received", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+	// blocking new orders while a kill switch is engaged
+	if status, details, blocked := s.killSwitchRejection(c.GetString("userId"), request.Order.Segment); blocked {
+		response.Errors = append(response.Errors, details)
+		c.JSON(status, response)
+		c.Abort()
+		return
+	}
+	// validating the order which will be placed on trigger
+	if err := s.gttOrderValidation(request.Order); err != nil {
+		logger.Log.Error("gttOrderValidation Failed,", zap.Error(err))
+		status := validationStatus(err)
+		response.Errors = append(response.Errors, validationDetails(status, err))
+		response.FieldErrors = prefixFieldErrors("order", fieldErrorsOf(err))
+		c.JSON(status, response)
+		c.Abort()
+		return
+	}
+
+	condition, ltp, err := s.gttCondition(request.Order, request.TriggerPrice)
+	if err != nil {
+		logger.Log.Error("GTT trigger condition failed", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(err.Error()))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+
+	now := time.Now()
+	order := GTTOrder{
+		TriggerPrice: request.TriggerPrice,
+		LastPrice:    ltp,
+		Condition:    condition,
+		Order:        request.Order,
+		Status:       GTTActive,
+		CreatedAt:    now,
+		ExpiresAt:    now.AddDate(0, 0, gttExpiryDays()),
+	}
+	record, err := toGTTRecord(c.GetString("userId"), order)
+	if err != nil {
+		logger.Log.Error("Failed to marshal GTT order", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+	order.ID, err = s.dbObj.CreateGTTOrder(record)
+	if err != nil {
+		logger.Log.Error("Failed to store GTT order", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+
+	response.Data = order
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+// GTTOrders Returns GTT orders of the user in every status
+func (s *trade) GTTOrders(c *gin.Context) {
+	var (
+		response GTTOrdersResponse
+	)
+
+	records, err := s.dbObj.GetGTTOrders(c.GetString("userId"))
+	if err != nil {
+		logger.Log.Error("Failed to fetch GTT orders", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+
+	orders := make([]GTTOrder, 0, len(records))
+	for _, record := range records {
+		order, err := fromGTTRecord(record)
+		if err != nil {
+			logger.Log.Error("Failed to unmarshal GTT order", zap.Error(err), zap.Int64("id", record.ID))
+			continue
+		}
+		orders = append(orders, order)
+	}
+	if len(orders) == 0 {
+		response.Errors = append(response.Errors, e.ErrorInfo["NoDataFound"].GetErrorDetails("GTT order not found."))
+		c.JSON(http.StatusNotFound, response)
+		c.Abort()
+		return
+	}
+
+	response.Data = orders
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+// handles modification of trigger price & order payload of an active GTT order
+func (s *trade) ModifyGTTOrder(c *gin.Context) {
+	var (
+		request  ModifyGTTOrderRequest
+		response GTTOrderResponse
+	)
+	//  validating the request payload via gin framework
+	if err := bindOrderJSON(c, &request); err != nil {
+		logger.Log.Error("Invalid arguement received", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+	if err := s.gttOrderValidation(request.Order); err != nil {
+		logger.Log.Error("gttOrderValidation Failed,", zap.Error(err))
+		status := validationStatus(err)
+		response.Errors = append(response.Errors, validationDetails(status, err))
+		response.FieldErrors = prefixFieldErrors("order", fieldErrorsOf(err))
+		c.JSON(status, response)
+		c.Abort()
+		return
+	}
+
+	userId := c.GetString("userId")
+	order, found, err := s.findGTTOrder(userId, request.ID)
+	if err != nil {
+		logger.Log.Error("Failed to fetch GTT orders", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+	if !found {
+		response.Errors = append(response.Errors, e.ErrorInfo["NoDataFound"].GetErrorDetails("GTT order not found."))
+		c.JSON(http.StatusNotFound, response)
+		c.Abort()
+		return
+	}
+	if order.Status != GTTActive {
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":GTT order is %s, only active GTT can be modified", order.Status)))
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+
+	condition, ltp, err := s.gttCondition(request.Order, request.TriggerPrice)
+	if err != nil {
+		logger.Log.Error("GTT trigger condition failed", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(err.Error()))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+	order.TriggerPrice = request.TriggerPrice
+	order.LastPrice = ltp
+	order.Condition = condition
+	order.Order = request.Order
+
+	// updated only while still active so a GTT claimed by the evaluator is never rewritten as active
+	updated := false
+	record, err := toGTTRecord(userId, order)
+	if err == nil {
+		updated, err = s.dbObj.UpdateGTTOrderIfStatus(record, GTTActive)
+	}
+	if err != nil {
+		logger.Log.Error("Failed to update GTT order", zap.Error(err), zap.Int64("id", order.ID))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+	if !updated {
+		logger.Log.Info("GTT order changed status during modification", zap.Int64("id", order.ID))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(":GTT order is no longer active, only active GTT can be modified"))
+		c.JSON(http.StatusConflict, response)
+		c.Abort()
+		return
+	}
+
+	response.Data = order
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+// deletes active GTT order of the user, triggered GTT keeps the placed order as is
+func (s *trade) DeleteGTTOrder(c *gin.Context) {
+	var (
+		response Response
+	)
+
+	id, err := strconv.ParseInt(c.Query(GTTIdParam), 10, 64)
+	if err != nil || id <= 0 {
+		logger.Log.Error("Invalid GTT id received", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(":gttId is required"))
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+
+	// deleted only while still active so a GTT claimed by the evaluator keeps its record
+	userId := c.GetString("userId")
+	deleted, err := s.dbObj.DeleteGTTOrderIfStatus(id, userId, GTTActive)
+	if err != nil {
+		logger.Log.Error("Failed to delete GTT order", zap.Error(err), zap.Int64("id", id))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+	if !deleted {
+		order, found, err := s.findGTTOrder(userId, id)
+		if err != nil {
+			logger.Log.Error("Failed to fetch GTT orders", zap.Error(err))
+			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+			c.JSON(http.StatusInternalServerError, response)
+			c.Abort()
+			return
+		}
+		if !found {
+			response.Errors = append(response.Errors, e.ErrorInfo["NoDataFound"].GetErrorDetails("GTT order not found."))
+			c.JSON(http.StatusNotFound, response)
+			c.Abort()
+			return
+		}
+		logger.Log.Info("GTT order no longer active, not deleted", zap.Int64("id", id), zap.String("status", order.Status))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":GTT order is %s, only active GTT can be deleted", order.Status)))
+		c.JSON(http.StatusConflict, response)
+		c.Abort()
+		return
+	}
+
+	response.Status = true
+	response.Message = "GTT order deleted successfully"
+	c.JSON(http.StatusOK, response)
+}
+
+/*
+StartGTTEvaluator checks active GTT orders every GTTEvaluationInterval
+until ctx is done, GTT orders are read from DBLayer on every run so
+they survive restarts of the service
+*/
+func (s *trade) StartGTTEvaluator(ctx context.Context) {
+	ticker := time.NewTicker(GTTEvaluationInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.evaluateGTTOrders(now)
+			}
+		}
+	}()
+}
+
+/*
+expires GTT orders past ExpiresAt and places the order of every
+GTT whose trigger price is crossed by the last traded price, a GTT is
+moved out of ACTIVE with a conditional update so only one evaluator
+instance acts on it & a concurrent modification is never overwritten
+*/
+func (s *trade) evaluateGTTOrders(now time.Time) {
+	records, err := s.dbObj.GetActiveGTTOrders()
+	if err != nil {
+		logger.Log.Error("GTT evaluator: failed to fetch active GTT orders", zap.Error(err))
+		return
+	}
+
+	for _, record := range records {
+		if !now.Before(record.ExpiresAt) {
+			record.Status = GTTExpired
+			record.UpdatedAt = now
+			if _, err := s.dbObj.UpdateGTTOrderIfStatus(record, GTTActive); err != nil {
+				logger.Log.Error("GTT evaluator: failed to expire GTT order", zap.Error(err), zap.Int64("id", record.ID))
+			}
+			continue
+		}
+
+		tick, err := s.dbObj.GetSymbolTickData(record.ExchangeToken, record.Exchange)
+		if err != nil {
+			logger.Log.Error("GTT evaluator: failed to fetch tick data", zap.Error(err), zap.Int64("id", record.ID))
+			continue
+		}
+		if !gttTriggered(record.Condition, record.TriggerPrice, tick.LastTradedPrice) {
+			continue
+		}
+
+		order, err := fromGTTRecord(record)
+		if err != nil {
+			logger.Log.Error("GTT evaluator: failed to unmarshal GTT order", zap.Error(err), zap.Int64("id", record.ID))
+			continue
+		}
+		if _, _, blocked := s.killSwitchRejection(record.UserID, record.Segment); blocked {
+			logger.Log.Warn("GTT evaluator: trigger held by kill switch", zap.Int64("id", record.ID))
+			continue
+		}
+		// claiming the GTT before placing, a failed or lost claim never places the order
+		record.Status = GTTTriggered
+		record.UpdatedAt = now
+		claimed, err := s.dbObj.UpdateGTTOrderIfStatus(record, GTTActive)
+		if err != nil {
+			logger.Log.Error("GTT evaluator: failed to mark GTT order triggered", zap.Error(err), zap.Int64("id", record.ID))
+			continue
+		}
+		if !claimed {
+			logger.Log.Info("GTT evaluator: GTT order no longer active, claimed or modified elsewhere", zap.Int64("id", record.ID))
+			continue
+		}
+
+		logger.Log.Info("GTT triggered", zap.Int64("id", record.ID), zap.Float64("trigger", record.TriggerPrice), zap.Float64("ltp", tick.LastTradedPrice))
+		s.placeTriggeredGTT(record, order.Order)
+	}
+}
+
+/*
+places the order of a claimed GTT through the placement path of
+PlaceOrder, trading session, risk rules, exposure limits & slicing
+apply on trigger as for any other order, result is stored on the GTT
+*/
+func (s *trade) placeTriggeredGTT(record gtt.GTTOrder, request PlaceOrderRequest) {
+	c := userContext(record.UserID)
+	// no user is present to confirm a price far from last traded price on trigger
+	placement, _, err := s.prepareNormalOrder(c, request, false)
+	if err != nil {
+		record.Status = GTTFailed
+		record.Message = strings.TrimPrefix(err.Error(), ":")
+	} else {
+		ack, sliced, er := s.sendNormalOrder(c, placement)
+		record.Message = ack.Message
+		// children of a sliced order are found by the parent id
+		if sliced != nil {
+			record.OrderNo = sliced.ParentId
+		} else if len(ack.OrderNos) > 0 {
+			record.OrderNo = ack.OrderNos[0]
+		}
+		if er != nil {
+			record.Status = GTTFailed
+			if len(record.Message) == 0 {
+				record.Message = er.Err.Message
+			}
+		}
+	}
+	if err := s.dbObj.UpdateGTTOrder(record); err != nil {
+		logger.Log.Error("GTT evaluator: failed to store GTT order result", zap.Error(err), zap.Int64("id", record.ID))
+	}
+}
+
+/*
+validating the order placed on trigger, price band & last traded
+price checks are skipped at creation as the order is placed on a
+later price, they run on trigger through prepareNormalOrder
+*/
+func (s *trade) gttOrderValidation(request PlaceOrderRequest) error {
+	order := normalRiskOrder(request)
+	order.Deferred = true
+	return riskEngine.Evaluate(order, s)
+}
+
+/*
+trigger condition of GTT from the last traded price at creation,
+ABOVE triggers when ltp rises to trigger price, BELOW when it falls to it
+*/
+func (s *trade) gttCondition(order PlaceOrderRequest, triggerPrice float64) (string, float64, error) {
+	tick, err := s.dbObj.GetSymbolTickData(fmt.Sprintf("%d", order.ExchangeToken), order.Exchange)
+	if err != nil {
+		return "", 0, fmt.Errorf(":unable to fetch last traded price, %w", err)
+	}
+	ltp := tick.LastTradedPrice
+	if ltp <= 0.0 {
+		return "", 0, errors.New(":last traded price not available for the instrument")
+	}
+	if triggerPrice == ltp {
+		return "", ltp, fieldError(TriggerPriceField, "ne", "Trigger Price cannot be same as last traded price", "lastTradedPrice", fmt.Sprintf("%.2f", ltp))
+	}
+	if triggerPrice > ltp {
+		return GTTConditionAbove, ltp, nil
+	}
+	return GTTConditionBelow, ltp, nil
+}
+
+func gttTriggered(condition string, triggerPrice, ltp float64) bool {
+	if ltp <= 0.0 {
+		return false
+	}
+	if condition == GTTConditionAbove {
+		return ltp >= triggerPrice
+	}
+	return ltp <= triggerPrice
+}
+
+func (s *trade) findGTTOrder(userId string, id int64) (GTTOrder, bool, error) {
+	records, err := s.dbObj.GetGTTOrders(userId)
+	if err != nil {
+		return GTTOrder{}, false, err
+	}
+	for _, record := range records {
+		if record.ID == id {
+			order, err := fromGTTRecord(record)
+			return order, err == nil, err
+		}
+	}
+	return GTTOrder{}, false, nil
+}
+
+func gttExpiryDays() int {
+	days := config.GetConfig().GetInt("gtt.expiryDays")
+	if days <= 0 {
+		return DefaultGTTExpiryDays
+	}
+	return days
+}
+
+func toGTTRecord(userId string, order GTTOrder) (gtt.GTTOrder, error) {
+	payload, err := json.Marshal(order.Order)
+	if err != nil {
+		return gtt.GTTOrder{}, err
+	}
+	return gtt.GTTOrder{
+		ID:            order.ID,
+		UserID:        userId,
+		Exchange:      order.Order.Exchange,
+		Segment:       order.Order.Segment,
+		ExchangeToken: fmt.Sprintf("%d", order.Order.ExchangeToken),
+		TriggerPrice:  order.TriggerPrice,
+		LastPrice:     order.LastPrice,
+		Condition:     order.Condition,
+		OrderPayload:  string(payload),
+		Status:        order.Status,
+		OrderNo:       order.OrderNo,
+		Message:       order.Message,
+		CreatedAt:     order.CreatedAt,
+		UpdatedAt:     time.Now(),
+		ExpiresAt:     order.ExpiresAt,
+	}, nil
+}
+
+func fromGTTRecord(record gtt.GTTOrder) (GTTOrder, error) {
+	order := GTTOrder{
+		ID:           record.ID,
+		TriggerPrice: record.TriggerPrice,
+		LastPrice:    record.LastPrice,
+		Condition:    record.Condition,
+		Status:       record.Status,
+		OrderNo:      record.OrderNo,
+		Message:      record.Message,
+		CreatedAt:    record.CreatedAt,
+		ExpiresAt:    record.ExpiresAt,
+	}
+	err := json.Unmarshal([]byte(record.OrderPayload), &order.Order)
+	return order, err
+}
+
+// gin context carrying the user for placing orders outside of a request
+func userContext(userId string) *gin.Context {
+	c := &gin.Context{}
+	c.Set("userId", userId)
+	return c
+}
@@ -0,0 +1,225 @@
+package trade
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	IdempotencyKeyPrefix = "trade:idempotency:"
+	// outcome of a submission is remembered for a day
+	IdempotencyKeyTTL = 24 * time.Hour
+	/*
+		a reservation lapses if the instance dies before the outcome is
+		remembered, it is kept for this margin over the worst case time
+		the broker takes for the orders of the request
+	*/
+	IdempotencyInFlightTTL = time.Minute
+)
+
+var (
+	ErrIdempotencyKeyInFlight = errors.New(":request with the same Idempotency-Key is still in progress")
+	ErrIdempotencyKeyReused   = errors.New(":Idempotency-Key already used with a different request")
+)
+
+// reserved Idempotency-Key & fingerprint of the request holding it, the zero value when client sent no key
+type idempotencyReservation struct {
+	key         string
+	fingerprint string
+}
+
+// IdempotentRecord is the remembered outcome of a placement request
+type IdempotentRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	InFlight    bool   `json:"in_flight"`
+	Status      int    `json:"status,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+/*
+reserves the Idempotency-Key of the request right before it is sent to
+the broker, the key is kept in redis so a retry reaching any instance
+finds it, failures before the broker is called are not remembered
+
+	input:
+		context, api of the placement, request as submitted by the client,
+		count of orders the request sends to the broker
+	output:
+		reservation to complete once the response is written, zero if client sent no key
+		record of the original submission if this is a retry
+		error if the key is in progress, was used with a different request or redis failed
+*/
+func (s *trade) reserveIdempotencyKey(c *gin.Context, api string, request interface{}, orders int) (idempotencyReservation, *IdempotentRecord, error) {
+	clientKey := c.GetHeader(IdempotencyKeyHeader)
+	if len(clientKey) == 0 {
+		return idempotencyReservation{}, nil, nil
+	}
+	fingerprint, err := idempotencyFingerprint(request)
+	if err != nil {
+		return idempotencyReservation{}, nil, err
+	}
+	key := idempotencyKey(c, api, clientKey)
+
+	byteData, err := json.Marshal(IdempotentRecord{Fingerprint: fingerprint, InFlight: true})
+	if err != nil {
+		return idempotencyReservation{}, nil, err
+	}
+	reserved, err := s.redisCaller.SetNX(key, string(byteData), idempotencyInFlightTTL(orders))
+	if err != nil {
+		logger.Log.Error("idempotency: failed to reserve key in redis", zap.Error(err), zap.String("key", key))
+		return idempotencyReservation{}, nil, err
+	}
+	if reserved {
+		return idempotencyReservation{key: key, fingerprint: fingerprint}, nil, nil
+	}
+	rec, err := s.idempotentRecord(key)
+	if err != nil {
+		return idempotencyReservation{}, nil, err
+	}
+	if rec == nil {
+		// reservation lapsed between SETNX & GET, the original is treated as still in progress
+		return idempotencyReservation{}, nil, ErrIdempotencyKeyInFlight
+	}
+	rec, err = matchIdempotentRecord(rec, fingerprint)
+	return idempotencyReservation{}, rec, err
+}
+
+/*
+reservation outlives the broker calls of the request, orders are sent
+one after another & each may take up to ApiTimeout, order entries are
+sent once as per the zero retry policy
+*/
+func idempotencyInFlightTTL(orders int) time.Duration {
+	if orders < 1 {
+		orders = 1
+	}
+	return IdempotencyInFlightTTL + time.Duration(orders)*retryPolicy{}.worstCase(time.Duration(ApiTimeout)*time.Millisecond)
+}
+
+/*
+looks up the Idempotency-Key of the request before any check runs so a
+retry gets the original response even when the checks would now reject
+it i.e after market close or once a kill switch is engaged, the key is
+reserved later by reserveIdempotencyKey
+
+	output:
+		record of the original submission if this is a retry of a completed one
+		error if the key is in progress, was used with a different request or redis failed
+*/
+func (s *trade) idempotentReplay(c *gin.Context, api string, request interface{}) (*IdempotentRecord, error) {
+	clientKey := c.GetHeader(IdempotencyKeyHeader)
+	if len(clientKey) == 0 {
+		return nil, nil
+	}
+	fingerprint, err := idempotencyFingerprint(request)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := s.idempotentRecord(idempotencyKey(c, api, clientKey))
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	return matchIdempotentRecord(rec, fingerprint)
+}
+
+// record of the original submission if the retry matches it & it completed
+func matchIdempotentRecord(rec *IdempotentRecord, fingerprint string) (*IdempotentRecord, error) {
+	if rec.Fingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if rec.InFlight {
+		return nil, ErrIdempotencyKeyInFlight
+	}
+	return rec, nil
+}
+
+/*
+remembers the response written for the reserved key, every outcome
+after the broker is called including vendor failures is kept as the
+order may exist at the broker, the outcome is stored even when the
+reservation lapsed meanwhile unless a different request took the key
+*/
+func (s *trade) completeIdempotencyKey(c *gin.Context, reservation idempotencyReservation, response interface{}) {
+	if len(reservation.key) == 0 {
+		return
+	}
+	rec, err := s.idempotentRecord(reservation.key)
+	if err != nil {
+		return
+	}
+	if rec == nil {
+		logger.Log.Warn("idempotency: reservation lapsed before completion, storing outcome", zap.String("key", reservation.key))
+	} else if rec.Fingerprint != reservation.fingerprint {
+		logger.Log.Error("idempotency: key taken by a different request after reservation lapsed, outcome not stored", zap.String("key", reservation.key))
+		return
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	byteData, err := json.Marshal(IdempotentRecord{Fingerprint: reservation.fingerprint, Status: c.Writer.Status(), Body: body})
+	if err != nil {
+		return
+	}
+	if err := s.redisCaller.Set(reservation.key, string(byteData), IdempotencyKeyTTL); err != nil {
+		logger.Log.Error("idempotency: failed to store outcome in redis", zap.Error(err), zap.String("key", reservation.key))
+	}
+}
+
+// record stored under the key, nil if the key is unknown or expired
+func (s *trade) idempotentRecord(key string) (*IdempotentRecord, error) {
+	value, err := s.redisCaller.Get(key)
+	if err != nil {
+		logger.Log.Error("idempotency: failed to read redis", zap.Error(err), zap.String("key", key))
+		return nil, err
+	}
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var rec IdempotentRecord
+	if err := json.Unmarshal([]byte(value), &rec); err != nil {
+		logger.Log.Error("idempotency: failed to unmarshal", zap.Error(err), zap.String("key", key))
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// keys are scoped per user & placement api
+func idempotencyKey(c *gin.Context, api, clientKey string) string {
+	return IdempotencyKeyPrefix + c.GetString("userId") + ":" + api + ":" + clientKey
+}
+
+func idempotencyFingerprint(request interface{}) (string, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// status & error details of a rejected Idempotency-Key, redis failures are not the client's fault
+func idempotencyFailure(err error) (int, e.Error) {
+	if errors.Is(err, ErrIdempotencyKeyInFlight) || errors.Is(err, ErrIdempotencyKeyReused) {
+		return http.StatusConflict, e.ErrorInfo["BadRequest"].GetErrorDetails(err.Error())
+	}
+	return http.StatusInternalServerError, e.ErrorInfo["InternalServerError"].GetErrorDetails("")
+}
+
+// writes the original response of a retried submission
+func replayIdempotentResponse(c *gin.Context, rec *IdempotentRecord) {
+	c.Data(rec.Status, gin.MIMEJSON+"; charset=utf-8", rec.Body)
+	if rec.Status != http.StatusOK {
+		c.Abort()
+	}
+}
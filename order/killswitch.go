@@ -0,0 +1,165 @@
+package trade
+
+import (
+	"encoding/json"
+	config "equity-trading/pkg/config"
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+/*
+engages or releases a kill switch, the switch is stored in redis so
+every instance sees it on the next order & it survives restarts,
+route is expected to be behind admin authorization
+*/
+func (s *trade) SetKillSwitch(c *gin.Context) {
+	var (
+		request  KillSwitchRequest
+		response KillSwitchResponse
+	)
+	//  validating the request payload via gin framework
+	if err := bindOrderJSON(c, &request); err != nil {
+		logger.Log.Error("Invalid arguement received for kill switch", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+	request.Value = strings.ToUpper(strings.TrimSpace(request.Value))
+	if request.Scope != KillSwitchGlobal && len(request.Value) == 0 {
+		logger.Log.Error("kill switch value missing", zap.String("scope", request.Scope))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(":Value is required for segment & user kill switch"))
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+	if request.Scope == KillSwitchGlobal {
+		request.Value = ""
+	}
+
+	ks := KillSwitch{
+		Scope:     request.Scope,
+		Value:     request.Value,
+		Enabled:   *request.Enabled,
+		Reason:    request.Reason,
+		UpdatedBy: c.GetString("userId"),
+		UpdatedAt: time.Now(),
+	}
+	byteData, err := json.Marshal(ks)
+	if err != nil {
+		logger.Log.Error("Failed to marshal kill switch", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+	if err := s.redisCaller.Set(killSwitchKey(ks.Scope, ks.Value), string(byteData), 0); err != nil {
+		logger.Log.Error("Failed to store kill switch", zap.Error(err), zap.String("scope", ks.Scope), zap.String("value", ks.Value))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+	logger.Log.Warn("kill switch updated", zap.String("scope", ks.Scope), zap.String("value", ks.Value),
+		zap.Bool("enabled", ks.Enabled), zap.String("reason", ks.Reason), zap.String("by", ks.UpdatedBy))
+
+	response.Data = ks
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+/*
+status & error details of new orders of the user in any of the segments
+while a kill switch blocks them, blocked is false when the order may
+proceed
+
+kill switches that cannot be read from redis block the order with 503
+so an outage never turns the switch off, config killSwitch.failOpen
+lets orders through instead
+*/
+func (s *trade) killSwitchRejection(userId string, segments ...string) (int, e.Error, bool) {
+	ks, err := s.engagedKillSwitch(userId, segments...)
+	if err != nil {
+		if config.GetConfig().GetBool(KillSwitchFailOpenConfig) {
+			logger.Log.Warn("kill switch unavailable, order let through as configured", zap.Error(err), zap.String("userId", userId))
+			return http.StatusOK, e.Error{}, false
+		}
+		logger.Log.Error("kill switch unavailable, order blocked", zap.Error(err), zap.String("userId", userId))
+		return http.StatusServiceUnavailable, orderBlockedError(":unable to verify kill switch, try again"), true
+	}
+	if ks == nil {
+		return http.StatusOK, e.Error{}, false
+	}
+	logger.Log.Warn("order blocked by kill switch", zap.String("scope", ks.Scope), zap.String("value", ks.Value))
+	return http.StatusForbidden, orderBlockedError(ks.message()), true
+}
+
+/*
+returns the first engaged kill switch blocking new orders of the user
+in any of the segments, checked in order global, segment & user, error
+if any of them cannot be read from redis
+*/
+func (s *trade) engagedKillSwitch(userId string, segments ...string) (*KillSwitch, error) {
+	keys := []string{killSwitchKey(KillSwitchGlobal, "")}
+	seen := make(map[string]bool)
+	for _, segment := range segments {
+		if !seen[segment] {
+			seen[segment] = true
+			keys = append(keys, killSwitchKey(KillSwitchSegment, segment))
+		}
+	}
+	keys = append(keys, killSwitchKey(KillSwitchUser, userId))
+
+	for _, key := range keys {
+		value, err := s.redisCaller.Get(key)
+		if err != nil {
+			logger.Log.Error("kill switch: failed to read redis", zap.Error(err), zap.String("key", key))
+			return nil, err
+		}
+		if len(value) == 0 {
+			continue
+		}
+		var ks KillSwitch
+		if err := json.Unmarshal([]byte(value), &ks); err != nil {
+			logger.Log.Error("kill switch: failed to unmarshal", zap.Error(err), zap.String("key", key))
+			return nil, err
+		}
+		if ks.Enabled {
+			return &ks, nil
+		}
+	}
+	return nil, nil
+}
+
+// segment & user id are matched case insensitively
+func killSwitchKey(scope, value string) string {
+	if scope == KillSwitchGlobal {
+		return KillSwitchKeyPrefix + strings.ToLower(scope)
+	}
+	return KillSwitchKeyPrefix + strings.ToLower(scope) + ":" + strings.ToUpper(value)
+}
+
+// pkg/errors has no code for a blocked order, BadRequest details are sent under OrderBlockedError
+func orderBlockedError(msg string) e.Error {
+	return namedErrorDetails(OrderBlockedError, "BadRequest", msg)
+}
+
+// reason of the blocked order in error details format
+func (k *KillSwitch) message() string {
+	msg := ":new orders are blocked by risk"
+	if k.Scope != KillSwitchGlobal {
+		msg += fmt.Sprintf(" for %s %s", strings.ToLower(k.Scope), k.Value)
+	}
+	if len(k.Reason) != 0 {
+		msg += ", " + k.Reason
+	}
+	return msg
+}
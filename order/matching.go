@@ -0,0 +1,462 @@
+package trade
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// price orders are matched at for instruments without SetPrice
+const MatchingDefaultPrice = 100.0
+
+const matchingTimeLayout = "2006-01-02 15:04:05"
+
+var ErrMatchingOrderNotOpen = errors.New("ORDER NOT FOUND OR NOT OPEN")
+
+/*
+MatchingBook is the OrderBook & PositionBook of a client filled against
+last traded prices, it is shared by paper trading & the rupeeseed
+simulator so both fill orders the same way, a book is not safe for
+concurrent use
+
+matching model
+  - MKT orders trade at the last traded price of the instrument
+  - LMT orders trade when the last traded price is at or better than the limit, IOC orders not traded are cancelled
+  - SL & SLM orders wait for the last traded price to cross the trigger price, SL is then matched as LMT & SLM as MKT
+  - bracket orders add target & stoploss legs once the main leg trades, the leg trading first cancels the other
+  - cover orders add a stoploss leg once the main leg trades
+  - exiting a bracket or cover order trades its stoploss leg at market
+*/
+type MatchingBook struct {
+	Orders    []*MatchedOrder                   `json:"orders"`
+	Positions map[string]*RupeeSeedPositionBook `json:"positions"`
+	Prices    map[string]float64                `json:"prices"`
+}
+
+// MatchedOrder is an order row of MatchingBook with what is needed to match it
+type MatchedOrder struct {
+	RupeeseedOrderBook
+	Triggered bool `json:"triggered"`
+	// offsets of target & stoploss legs of a bracket order, trigger of the stoploss leg of a cover order
+	ProfitValue   float64 `json:"profit_value"`
+	StoplossValue float64 `json:"stoploss_value"`
+	CoverTrigger  float64 `json:"cover_trigger"`
+	// serial number of the other bracket leg, cancelled when this leg trades
+	SiblingSerialNo int `json:"sibling_serial_no"`
+}
+
+// MatchingOrder is an order entered in MatchingBook
+type MatchingOrder struct {
+	TxnType       string
+	Exchange      string
+	Segment       string
+	Product       string
+	SecurityID    string
+	Quantity      int
+	Price         float64
+	TriggerPrice  float64
+	OrderType     string
+	Validity      string
+	ProfitValue   float64
+	StoplossValue float64
+	CoverTrigger  float64
+}
+
+func NewMatchingBook() *MatchingBook {
+	return &MatchingBook{
+		Orders:    make([]*MatchedOrder, 0),
+		Positions: make(map[string]*RupeeSeedPositionBook),
+		Prices:    make(map[string]float64),
+	}
+}
+
+/*
+SetPrice sets the last traded price of an instrument, pending orders of
+the instrument are matched again and positions are marked to the price
+*/
+func (b *MatchingBook) SetPrice(exchange, securityID string, price float64) {
+	key := exchange + ":" + securityID
+	b.Prices[key] = price
+	for _, position := range b.Positions {
+		if position.Exchange+":"+position.SecurityID == key {
+			position.LastTradedPrice = price
+		}
+	}
+	for _, order := range b.Orders {
+		if order.Exchange+":"+order.SecurityID == key {
+			b.match(order)
+		}
+	}
+}
+
+// OrderRows returns OrderBook rows in the order placed
+func (b *MatchingBook) OrderRows() []RupeeseedOrderBook {
+	orders := make([]RupeeseedOrderBook, 0, len(b.Orders))
+	for _, order := range b.Orders {
+		orders = append(orders, order.RupeeseedOrderBook)
+	}
+	return orders
+}
+
+// PositionRows returns PositionBook rows ordered by instrument & product
+func (b *MatchingBook) PositionRows() []RupeeSeedPositionBook {
+	keys := make([]string, 0, len(b.Positions))
+	for key := range b.Positions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	positions := make([]RupeeSeedPositionBook, 0, len(keys))
+	for _, key := range keys {
+		positions = append(positions, *b.Positions[key])
+	}
+	return positions
+}
+
+type matchingInstrument struct {
+	exchange, securityID string
+}
+
+// instruments of open orders & open positions
+func (b *MatchingBook) instruments() []matchingInstrument {
+	seen := make(map[matchingInstrument]bool)
+	instruments := make([]matchingInstrument, 0)
+	add := func(instrument matchingInstrument) {
+		if !seen[instrument] {
+			seen[instrument] = true
+			instruments = append(instruments, instrument)
+		}
+	}
+	for _, order := range b.Orders {
+		if orderStatus, _ := ParseOrderStatus(order.Status); orderStatus.Section() == Open {
+			add(matchingInstrument{order.Exchange, order.SecurityID})
+		}
+	}
+	for _, position := range b.PositionRows() {
+		if position.NetQty != 0 {
+			add(matchingInstrument{position.Exchange, position.SecurityID})
+		}
+	}
+	return instruments
+}
+
+// Enter adds an order under orderNo and matches it, order numbers are given by the caller
+func (b *MatchingBook) Enter(orderNo string, entry MatchingOrder) (RupeeseedOrderBook, error) {
+	if entry.Quantity <= 0 {
+		return RupeeseedOrderBook{}, fmt.Errorf("INVALID QUANTITY")
+	}
+	if entry.TxnType != BUY && entry.TxnType != SELL {
+		return RupeeseedOrderBook{}, fmt.Errorf("INVALID TRANSACTION TYPE")
+	}
+	if entry.Price < 0.0 || entry.TriggerPrice < 0.0 || entry.ProfitValue < 0.0 || entry.StoplossValue < 0.0 || entry.CoverTrigger < 0.0 {
+		return RupeeseedOrderBook{}, fmt.Errorf("INVALID PRICE")
+	}
+	order := &MatchedOrder{ProfitValue: entry.ProfitValue, StoplossValue: entry.StoplossValue, CoverTrigger: entry.CoverTrigger}
+	order.OrderNo = orderNo
+	order.SerialNo = 1
+	order.GroupId = 1
+	order.TxnType = entry.TxnType
+	order.Exchange = entry.Exchange
+	order.Segment = entry.Segment
+	order.Product = entry.Product
+	order.SecurityID = entry.SecurityID
+	order.Symbol = entry.SecurityID
+	order.DisplayName = entry.SecurityID
+	order.Quantity = entry.Quantity
+	order.RemainingQuantity = entry.Quantity
+	order.Price = entry.Price
+	order.TriggerPrice = entry.TriggerPrice
+	order.OrderType = entry.OrderType
+	order.Validity = entry.Validity
+	order.Status = Pending
+	order.OrderDateTime = time.Now().In(istLocation).Format(matchingTimeLayout)
+	order.LastUpdatedTime = order.OrderDateTime
+	b.Orders = append(b.Orders, order)
+	b.match(order)
+	return order.RupeeseedOrderBook, nil
+}
+
+// Modify changes an open order, empty order type & validity are left unchanged
+func (b *MatchingBook) Modify(orderNo string, serialNo, qty int, price, triggerPrice float64, orderType, validity string) (RupeeseedOrderBook, error) {
+	order := b.openOrder(orderNo, serialNo)
+	if order == nil {
+		return RupeeseedOrderBook{}, ErrMatchingOrderNotOpen
+	}
+	if qty <= 0 {
+		return RupeeseedOrderBook{}, fmt.Errorf("INVALID QUANTITY")
+	}
+	if qty <= order.TradedQty {
+		return RupeeseedOrderBook{}, fmt.Errorf("QUANTITY NOT MORE THAN TRADED QUANTITY")
+	}
+	order.Quantity = qty
+	order.RemainingQuantity = qty - order.TradedQty
+	order.Price = price
+	order.TriggerPrice = triggerPrice
+	if len(orderType) != 0 {
+		order.OrderType = orderType
+	}
+	if len(validity) != 0 {
+		order.Validity = validity
+	}
+	order.Status = Modified
+	order.LastUpdatedTime = time.Now().In(istLocation).Format(matchingTimeLayout)
+	b.match(order)
+	return order.RupeeseedOrderBook, nil
+}
+
+// Cancel cancels an open order, serial number selects the leg of bracket & cover orders
+func (b *MatchingBook) Cancel(orderNo string, serialNo int) (RupeeseedOrderBook, error) {
+	order := b.openOrder(orderNo, serialNo)
+	if order == nil {
+		return RupeeseedOrderBook{}, ErrMatchingOrderNotOpen
+	}
+	b.cancel(order)
+	return order.RupeeseedOrderBook, nil
+}
+
+/*
+Exit exits a bracket or cover order, an open main leg is cancelled,
+otherwise the stoploss leg is traded at market cancelling the target
+*/
+func (b *MatchingBook) Exit(orderNo string) (RupeeseedOrderBook, error) {
+	var exited *MatchedOrder
+	for _, order := range b.Orders {
+		if order.OrderNo != orderNo {
+			continue
+		}
+		if orderStatus, _ := ParseOrderStatus(order.Status); orderStatus.Section() != Open {
+			continue
+		}
+		if order.SerialNo == 1 {
+			b.cancel(order)
+			return order.RupeeseedOrderBook, nil
+		}
+		if order.OrderType == SLM {
+			exited = order
+		}
+	}
+	if exited == nil {
+		return RupeeseedOrderBook{}, ErrMatchingOrderNotOpen
+	}
+	exited.OrderType = MKT
+	b.match(exited)
+	return exited.RupeeseedOrderBook, nil
+}
+
+/*
+Convert moves quantity of an open position to another product at the
+average price of the position, fails when the position is not open on
+the side requested or quantity is more than the open quantity
+*/
+func (b *MatchingBook) Convert(exchange, securityID, txnType, productFrom, productTo string, quantity int) error {
+	from := b.Positions[matchingPositionKey(exchange, securityID, productFrom)]
+	if from == nil || quantity <= 0 || productFrom == productTo ||
+		(txnType == BUY && from.NetQty < quantity) || (txnType == SELL && -from.NetQty < quantity) {
+		return fmt.Errorf("NO OPEN POSITION AVAILABLE TO CONVERT")
+	}
+	to := b.position(from.Exchange, from.Segment, from.SecurityID, productTo)
+	qty := float64(quantity)
+	if txnType == BUY {
+		value := qty * from.BuyAvg
+		from.TotBuyQty, from.TotBuyVal = from.TotBuyQty-quantity, from.TotBuyVal-value
+		to.TotBuyQty, to.TotBuyVal = to.TotBuyQty+quantity, to.TotBuyVal+value
+	} else {
+		value := qty * from.SellAvg
+		from.TotSellQty, from.TotSellVal = from.TotSellQty-quantity, from.TotSellVal-value
+		to.TotSellQty, to.TotSellVal = to.TotSellQty+quantity, to.TotSellVal+value
+	}
+	updateMatchingPosition(from)
+	updateMatchingPosition(to)
+	return nil
+}
+
+// open order, serial number selects the leg of bracket & cover orders
+func (b *MatchingBook) openOrder(orderNo string, serialNo int) *MatchedOrder {
+	for _, order := range b.Orders {
+		if order.OrderNo != orderNo || (serialNo > 0 && order.SerialNo != serialNo) {
+			continue
+		}
+		orderStatus, _ := ParseOrderStatus(order.Status)
+		if orderStatus.Section() == Open {
+			return order
+		}
+	}
+	return nil
+}
+
+// leg of the order with the serial number, nil for serial number 0
+func (b *MatchingBook) leg(orderNo string, serialNo int) *MatchedOrder {
+	if serialNo == 0 {
+		return nil
+	}
+	for _, order := range b.Orders {
+		if order.OrderNo == orderNo && order.SerialNo == serialNo {
+			return order
+		}
+	}
+	return nil
+}
+
+func (b *MatchingBook) lastTradedPrice(exchange, securityID string) float64 {
+	if price, ok := b.Prices[exchange+":"+securityID]; ok {
+		return price
+	}
+	return MatchingDefaultPrice
+}
+
+// trades order at the last traded price if the matching model allows it
+func (b *MatchingBook) match(order *MatchedOrder) {
+	orderStatus, _ := ParseOrderStatus(order.Status)
+	if orderStatus.Section() != Open {
+		return
+	}
+	ltp := b.lastTradedPrice(order.Exchange, order.SecurityID)
+	buy := order.TxnType == BUY
+
+	if (order.OrderType == SL || order.OrderType == SLM) && !order.Triggered {
+		if (buy && ltp < order.TriggerPrice) || (!buy && ltp > order.TriggerPrice) {
+			return
+		}
+		order.Triggered = true
+	}
+	marketable := order.OrderType == MKT || order.OrderType == SLM ||
+		(buy && ltp <= order.Price) || (!buy && ltp >= order.Price)
+	if !marketable {
+		if order.Validity == IOC {
+			b.cancel(order)
+		}
+		return
+	}
+
+	qty := order.RemainingQuantity
+	order.TradedQty += qty
+	order.RemainingQuantity = 0
+	order.TradedPrice = ltp
+	order.AvgTradedPrice = ltp
+	order.Status = Traded
+	order.LastUpdatedTime = time.Now().In(istLocation).Format(matchingTimeLayout)
+	b.fill(order, qty, ltp)
+
+	if sibling := b.leg(order.OrderNo, order.SiblingSerialNo); sibling != nil {
+		b.cancel(sibling)
+	}
+	b.addLegs(order, ltp)
+}
+
+func (b *MatchingBook) cancel(order *MatchedOrder) {
+	order.Status = Cancelled
+	order.LastUpdatedTime = time.Now().In(istLocation).Format(matchingTimeLayout)
+	sibling := b.leg(order.OrderNo, order.SiblingSerialNo)
+	order.SiblingSerialNo = 0
+	if sibling == nil {
+		return
+	}
+	sibling.SiblingSerialNo = 0
+	if orderStatus, _ := ParseOrderStatus(sibling.Status); orderStatus.Section() == Open {
+		b.cancel(sibling)
+	}
+}
+
+// target & stoploss legs of a traded bracket main leg, stoploss leg of a traded cover main leg
+func (b *MatchingBook) addLegs(main *MatchedOrder, price float64) {
+	if main.ProfitValue <= 0.0 && main.CoverTrigger <= 0.0 {
+		return
+	}
+	exitTxn, sign := SELL, 1.0
+	if main.TxnType == SELL {
+		exitTxn, sign = BUY, -1.0
+	}
+	leg := func(serialNo int, orderType string, limit, trigger float64) *MatchedOrder {
+		order := &MatchedOrder{RupeeseedOrderBook: main.RupeeseedOrderBook}
+		order.SerialNo = serialNo
+		order.TxnType = exitTxn
+		order.OrderType = orderType
+		order.Price = math.Round(limit*100) / 100
+		order.TriggerPrice = math.Round(trigger*100) / 100
+		order.TradedQty = 0
+		order.TradedPrice = 0.0
+		order.AvgTradedPrice = 0.0
+		order.RemainingQuantity = main.Quantity
+		order.Status = Pending
+		b.Orders = append(b.Orders, order)
+		return order
+	}
+
+	var legs []*MatchedOrder
+	if main.ProfitValue > 0.0 {
+		target := leg(2, LMT, price+sign*main.ProfitValue, 0.0)
+		stoploss := leg(3, SLM, 0.0, price-sign*main.StoplossValue)
+		target.SiblingSerialNo, stoploss.SiblingSerialNo = stoploss.SerialNo, target.SerialNo
+		legs = append(legs, target, stoploss)
+	} else {
+		legs = append(legs, leg(2, SLM, 0.0, main.CoverTrigger))
+	}
+	main.ProfitValue, main.CoverTrigger = 0.0, 0.0
+	for _, order := range legs {
+		b.match(order)
+	}
+}
+
+func matchingPositionKey(exchange, securityID, product string) string {
+	return exchange + ":" + securityID + ":" + product
+}
+
+func (b *MatchingBook) position(exchange, segment, securityID, product string) *RupeeSeedPositionBook {
+	key := matchingPositionKey(exchange, securityID, product)
+	position, ok := b.Positions[key]
+	if !ok {
+		position = &RupeeSeedPositionBook{
+			Symbol:          securityID,
+			DisplayName:     securityID,
+			Exchange:        exchange,
+			Segment:         segment,
+			SecurityID:      securityID,
+			Product:         product,
+			LotSize:         1,
+			LastTradedPrice: b.lastTradedPrice(exchange, securityID),
+		}
+		b.Positions[key] = position
+	}
+	return position
+}
+
+// books a trade of the order in PositionBook
+func (b *MatchingBook) fill(order *MatchedOrder, qty int, price float64) {
+	position := b.position(order.Exchange, order.Segment, order.SecurityID, order.Product)
+	value := float64(qty) * price
+	if order.TxnType == BUY {
+		position.TotBuyQty += qty
+		position.TotBuyVal += value
+	} else {
+		position.TotSellQty += qty
+		position.TotSellVal += value
+	}
+	updateMatchingPosition(position)
+}
+
+// derived values of a position from its buy & sell totals
+func updateMatchingPosition(position *RupeeSeedPositionBook) {
+	position.BuyAvg, position.SellAvg = 0.0, 0.0
+	if position.TotBuyQty > 0 {
+		position.BuyAvg = position.TotBuyVal / float64(position.TotBuyQty)
+	}
+	if position.TotSellQty > 0 {
+		position.SellAvg = position.TotSellVal / float64(position.TotSellQty)
+	}
+	position.NetQty = position.TotBuyQty - position.TotSellQty
+	position.GrossQty = position.TotBuyQty + position.TotSellQty
+	position.GrossVal = position.TotBuyVal + position.TotSellVal
+	position.NetVal = position.TotSellVal - position.TotBuyVal
+	position.NetAvg = 0.0
+	if position.NetQty > 0 {
+		position.NetAvg = position.BuyAvg
+	} else if position.NetQty < 0 {
+		position.NetAvg = position.SellAvg
+	}
+	closedQty := position.TotBuyQty
+	if position.TotSellQty < closedQty {
+		closedQty = position.TotSellQty
+	}
+	position.RealisedProfit = math.Round(float64(closedQty)*(position.SellAvg-position.BuyAvg)*100) / 100
+}
@@ -0,0 +1,632 @@
+package trade
+
+import (
+	"encoding/json"
+	e "equity-trading/pkg/errors"
+	"time"
+)
+
+const (
+	CancelOrderApi = "/CancelOrder"
+	BoExitOrderApi = "/BoOrderExit"
+	CoExitOrderApi = "/CoOrderExit"
+
+	OrderCancellationSuccess = "Order cancelled successfully"
+	OrderExitSuccess         = "Order exited successfully"
+
+	BoProductValue = "B"
+)
+
+/*
+CancelOrderRequest identifies a resting order to cancel or exit.
+LegNo is required for bracket/cover exits, AlgoOrderNo only for bracket exits
+*/
+type CancelOrderRequest struct {
+	OrderNo     string `json:"order_no" binding:"required"`
+	SerialNo    int    `json:"serial_no"`
+	GroupId     int    `json:"group_id"`
+	LegNo       int    `json:"leg_no"`
+	AlgoOrderNo string `json:"algo_order_no"`
+	TxnType     string `json:"txn_type" binding:"required,order_txntype"`
+	Exchange    string `json:"exchange" binding:"required,order_exchange"`
+	Segment     string `json:"segment" binding:"required,order_segment"`
+	Product     string `json:"product" binding:"required,order_product"`
+}
+
+// request body for rupeeseed CancelOrder api
+type RupeeseedCancelOrderRequest struct {
+	EntityId string `json:"entity_id"`
+	Source   string `json:"source"`
+	Data     struct {
+		ClientId string `json:"client_id"`
+		UserId   string `json:"user_id"`
+		OrderNo  string `json:"order_no"`
+		SerialNo string `json:"serial_no"`
+		GroupId  string `json:"group_id"`
+		TxnType  string `json:"txn_type"`
+		Exchange string `json:"exchange"`
+		Segment  string `json:"segment"`
+		Product  string `json:"product"`
+	} `json:"data"`
+}
+
+// request body for rupeeseed BoOrderExit api
+type RupeeseedBracketExitRequest struct {
+	EntityID string `json:"entity_id"`
+	Source   string `json:"source"`
+	Data     struct {
+		ClientID    string `json:"client_id"`
+		OrderNo     string `json:"order_no"`
+		SerialNo    string `json:"serial_no"`
+		GroupId     string `json:"group_id"`
+		LegNo       string `json:"leg_no"`
+		AlgoOrderNo string `json:"algo_order_no"`
+		TxnType     string `json:"txn_type"`
+		Exchange    string `json:"exchange"`
+		Segment     string `json:"segment"`
+		Product     string `json:"product"`
+	} `json:"data"`
+}
+
+// request body for rupeeseed CoOrderExit api
+type RupeeseedCoverExitRequest struct {
+	EntityID string `json:"entity_id"`
+	Source   string `json:"source"`
+	Data     struct {
+		ClientID string `json:"client_id"`
+		OrderNo  string `json:"order_no"`
+		SerialNo string `json:"serial_no"`
+		GroupId  string `json:"group_id"`
+		LegNo    string `json:"leg_no"`
+		TxnType  string `json:"txn_type"`
+		Exchange string `json:"exchange"`
+		Segment  string `json:"segment"`
+		Product  string `json:"product"`
+	} `json:"data"`
+}
+
+const TradeBookApi = "/TradeBook"
+
+// request body for rupeeseed TradeBook api
+type RupeeseedTradeBookRequest struct {
+	EntityId string `json:"entity_id"`
+	Source   string `json:"source"`
+	Data     struct {
+		ClientId string `json:"client_id"`
+		UserId   string `json:"user_id"`
+	} `json:"data"`
+}
+
+// response of rupeeseed TradeBook api, one entry per exchange fill
+type RupeeseedTradeBookResponse struct {
+	Status    string               `json:"status"`
+	Message   string               `json:"message"`
+	ErrorCode string               `json:"error_code"`
+	Data      []RupeeseedTradeBook `json:"data"`
+}
+
+type RupeeseedTradeBook struct {
+	OrderNo       string  `json:"order_no"`
+	ExchOrderNo   string  `json:"exch_order_no"`
+	ExchTradeID   string  `json:"exch_trade_id"`
+	TradedQty     int     `json:"traded_qty"`
+	TradedPrice   float64 `json:"traded_price"`
+	TradeDateTime string  `json:"trade_date_time"`
+	Symbol        string  `json:"symbol"`
+	DisplayName   string  `json:"display_name"`
+	Exchange      string  `json:"exchange"`
+	Segment       string  `json:"segment"`
+	SecurityID    string  `json:"security_id"`
+	TxnType       string  `json:"txn_type"`
+	Product       string  `json:"product"`
+	OptType       string  `json:"opt_type"`
+}
+
+type TradeBook struct {
+	OrderNo      string  `json:"order_no"`
+	ExchOrderNo  string  `json:"exch_order_no"`
+	ExchTradeID  string  `json:"exch_trade_id"`
+	FillQty      int     `json:"fill_qty"`
+	FillPrice    float64 `json:"fill_price"`
+	TradeTime    string  `json:"trade_time"`
+	Symbol       string  `json:"symbol"`
+	DisplayName  string  `json:"display_name"`
+	Exchange     string  `json:"exchange"`
+	Segment      string  `json:"segment"`
+	SecurityID   string  `json:"security_id"`
+	TxnType      string  `json:"txn_type"`
+	Product      string  `json:"product"`
+	StreamSymbol string  `json:"stream_symbol"`
+}
+
+type TradeBookResponse struct {
+	Status bool        `json:"status"`
+	Data   []TradeBook `json:"data"`
+	Errors []e.Error   `json:"errors"`
+}
+
+const (
+	OrderHistoryApi = "/OrderHistory"
+
+	OrderNoParam = "orderNo"
+)
+
+// request body for rupeeseed OrderHistory api
+type RupeeseedOrderHistoryRequest struct {
+	EntityId string `json:"entity_id"`
+	Source   string `json:"source"`
+	Data     struct {
+		ClientId string `json:"client_id"`
+		UserId   string `json:"user_id"`
+		OrderNo  string `json:"order_no"`
+	} `json:"data"`
+}
+
+// response of rupeeseed OrderHistory api, one entry per state change of the order
+type RupeeseedOrderHistoryResponse struct {
+	Status    string                  `json:"status"`
+	Message   string                  `json:"message"`
+	ErrorCode string                  `json:"error_code"`
+	Data      []RupeeseedOrderHistory `json:"data"`
+}
+
+type RupeeseedOrderHistory struct {
+	OrderNo           string  `json:"order_no"`
+	SerialNo          int     `json:"serial_no"`
+	Status            string  `json:"status"`
+	Quantity          int     `json:"quantity"`
+	TradedQty         int     `json:"traded_qty"`
+	RemainingQuantity int     `json:"remaining_quantity"`
+	Price             float64 `json:"price"`
+	TriggerPrice      float64 `json:"trigger_price"`
+	ErrorCode         string  `json:"error_code"`
+	ReasonDescription string  `json:"reason_description"`
+	LastUpdatedTime   string  `json:"last_updated_time"`
+}
+
+/*
+OrderTimelineStep is one state of an order, quantity changes are
+relative to the previous step of the same order
+*/
+type OrderTimelineStep struct {
+	Status            string  `json:"status"`
+	RiseStatus        string  `json:"rise_status"`
+	Time              string  `json:"time"`
+	Quantity          int     `json:"quantity"`
+	QuantityChange    int     `json:"quantity_change"`
+	TradedQty         int     `json:"traded_qty"`
+	TradedQtyChange   int     `json:"traded_qty_change"`
+	RemainingQuantity int     `json:"remaining_quantity"`
+	Price             float64 `json:"price"`
+	TriggerPrice      float64 `json:"trigger_price"`
+	ErrorCode         string  `json:"error_code,omitempty"`
+	Reason            string  `json:"reason,omitempty"`
+}
+
+type OrderTimeline struct {
+	OrderNo string              `json:"order_no"`
+	Steps   []OrderTimelineStep `json:"steps"`
+}
+
+type OrderHistoryResponse struct {
+	Status bool          `json:"status"`
+	Data   OrderTimeline `json:"data"`
+	Errors []e.Error     `json:"errors"`
+}
+
+const (
+	MaxBasketLegs        = 20
+	MaxBasketParallelism = 5
+)
+
+/*
+PriceConfirmation is sent with an order whose limit or trigger price is
+far from last traded price, price_confirmed acknowledges the fat finger
+warning and is part of the idempotency fingerprint of the request
+*/
+type PriceConfirmation struct {
+	PriceConfirmed bool `json:"price_confirmed"`
+}
+
+// body of PlaceOrder & of a NORMAL preview
+type ConfirmedPlaceOrderRequest struct {
+	PlaceOrderRequest
+	PriceConfirmation
+}
+
+// body of PlaceBracketOrder & of a BRACKET preview
+type ConfirmedBracketOrderRequest struct {
+	PlaceBracketOrderRequest
+	PriceConfirmation
+}
+
+// body of PlaceCoverOrder & of a COVER preview
+type ConfirmedCoverOrderRequest struct {
+	PlaceCoverOrderRequest
+	PriceConfirmation
+}
+
+// body of ModifyOrder, BracketOrderModify & CoverOrderModify
+type ConfirmedModifyOrderRequest struct {
+	ModifyOrderRequest
+	PriceConfirmation
+}
+
+/*
+BasketOrderRequest places every leg as a normal order,
+CancelOnFailure rolls back the placed legs if any leg fails, open legs
+are cancelled & filled quantity is squared off, price_confirmed applies
+to every leg
+*/
+type BasketOrderRequest struct {
+	Legs            []PlaceOrderRequest `json:"legs" binding:"required,min=1,dive"`
+	CancelOnFailure bool                `json:"cancel_on_failure"`
+	PriceConfirmation
+}
+
+type BasketLegResult struct {
+	Leg       int         `json:"leg"`
+	Status    bool        `json:"status"`
+	OrderNo   string      `json:"order_no,omitempty"`
+	Message   string      `json:"message,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Cancelled bool        `json:"cancelled"`
+	// filled quantity of the leg squared off by rollback
+	Unwound        bool      `json:"unwound"`
+	UnwindOrderNos []string  `json:"unwind_order_nos,omitempty"`
+	Errors         []e.Error `json:"errors,omitempty"`
+	// broker failure placing the leg
+	failure *BrokerError
+}
+
+type BasketOrderResponse struct {
+	Status      bool              `json:"status"`
+	Data        []BasketLegResult `json:"data"`
+	Errors      []e.Error         `json:"errors"`
+	FieldErrors []FieldError      `json:"field_errors,omitempty"`
+}
+
+const (
+	GTTActive    = "ACTIVE"
+	GTTTriggered = "TRIGGERED"
+	GTTFailed    = "FAILED"
+	GTTExpired   = "EXPIRED"
+
+	GTTConditionAbove = "ABOVE"
+	GTTConditionBelow = "BELOW"
+
+	GTTIdParam            = "gttId"
+	DefaultGTTExpiryDays  = 365
+	GTTEvaluationInterval = 2 * time.Second
+)
+
+// GTTOrderRequest places Order once last traded price crosses TriggerPrice
+type GTTOrderRequest struct {
+	TriggerPrice float64           `json:"trigger_price" binding:"required,gt=0"`
+	Order        PlaceOrderRequest `json:"order" binding:"required"`
+}
+
+type ModifyGTTOrderRequest struct {
+	ID           int64             `json:"id" binding:"required"`
+	TriggerPrice float64           `json:"trigger_price" binding:"required,gt=0"`
+	Order        PlaceOrderRequest `json:"order" binding:"required"`
+}
+
+type GTTOrder struct {
+	ID           int64             `json:"id"`
+	TriggerPrice float64           `json:"trigger_price"`
+	LastPrice    float64           `json:"last_price"`
+	Condition    string            `json:"condition"`
+	Order        PlaceOrderRequest `json:"order"`
+	Status       string            `json:"status"`
+	OrderNo      string            `json:"order_no,omitempty"`
+	Message      string            `json:"message,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	ExpiresAt    time.Time         `json:"expires_at"`
+}
+
+type GTTOrderResponse struct {
+	Status      bool         `json:"status"`
+	Data        GTTOrder     `json:"data"`
+	Errors      []e.Error    `json:"errors"`
+	FieldErrors []FieldError `json:"field_errors,omitempty"`
+}
+
+type GTTOrdersResponse struct {
+	Status bool       `json:"status"`
+	Data   []GTTOrder `json:"data"`
+	Errors []e.Error  `json:"errors"`
+}
+
+const EquitySegment = "E"
+
+const (
+	SlicedOrderKeyPrefix = "trade:sliced:"
+	// children stay linked to the parent id for a settlement week
+	SlicedOrderTTL = 7 * 24 * time.Hour
+	ParentIdParam  = "parentId"
+)
+
+/*
+SlicedOrder links child orders of an order sliced by exchange freeze
+quantity, it is stored in redis under the parent id of the user
+*/
+type SlicedOrder struct {
+	ParentId    string             `json:"parent_id"`
+	Quantity    int                `json:"quantity"`
+	FreezeQty   int                `json:"freeze_qty"`
+	ChildOrders []ChildOrderResult `json:"child_orders"`
+	PlacedAt    time.Time          `json:"placed_at"`
+}
+
+type SlicedOrderResponse struct {
+	Status bool        `json:"status"`
+	Data   SlicedOrder `json:"data"`
+	Errors []e.Error   `json:"errors"`
+}
+
+type ChildOrderResult struct {
+	OrderNo  string `json:"order_no,omitempty"`
+	Quantity int    `json:"quantity"`
+	Status   bool   `json:"status"`
+	Message  string `json:"message,omitempty"`
+}
+
+const (
+	MarketOrder = "MKT"
+	DayValidity = "DAY"
+)
+
+// SquareOffRequest filters positions to square off, empty filter matches every position
+type SquareOffRequest struct {
+	Segment string `json:"segment"`
+	Product string `json:"product"`
+	Symbol  string `json:"symbol"`
+}
+
+type SquareOffResult struct {
+	Symbol     string   `json:"symbol"`
+	Exchange   string   `json:"exchange"`
+	Segment    string   `json:"segment"`
+	Product    string   `json:"product"`
+	SecurityID string   `json:"security_id"`
+	NetQty     int      `json:"net_qty"`
+	TxnType    string   `json:"txn_type"`
+	Quantity   int      `json:"quantity"`
+	Status     bool     `json:"status"`
+	OrderNos   []string `json:"order_nos,omitempty"`
+	// open orders of the instrument & product cancelled before squaring off
+	CancelledOrderNos []string  `json:"cancelled_order_nos,omitempty"`
+	Message           string    `json:"message,omitempty"`
+	Errors            []e.Error `json:"errors,omitempty"`
+	// broker failure squaring off the position
+	failure *BrokerError
+}
+
+type SquareOffResponse struct {
+	Status      bool              `json:"status"`
+	Data        []SquareOffResult `json:"data"`
+	Errors      []e.Error         `json:"errors"`
+	FieldErrors []FieldError      `json:"field_errors,omitempty"`
+}
+
+const (
+	DerivativeSegment = "D"
+	CurrencySegment   = "C"
+	CommoditySegment  = "M"
+
+	IntradayProductValue = "I"
+
+	SquareOffRunning   = "RUNNING"
+	SquareOffCompleted = "COMPLETED"
+	SquareOffFailed    = "FAILED"
+
+	SquareOffCancelAction = "CANCEL"
+	SquareOffOrderAction  = "SQUARE_OFF"
+
+	AutoSquareOffInterval    = 30 * time.Second
+	AutoSquareOffMaxAttempts = 3
+	// a run left RUNNING longer than this is taken as lost with its instance
+	AutoSquareOffRunTimeout = 5 * time.Minute
+	TradeDateParam          = "date"
+	TradeDateLayout         = "2006-01-02"
+)
+
+// AutoSquareOffAction is one order cancelled or placed by an auto square off run
+type AutoSquareOffAction struct {
+	Type     string `json:"type"`
+	OrderNo  string `json:"order_no,omitempty"`
+	Symbol   string `json:"symbol,omitempty"`
+	TxnType  string `json:"txn_type,omitempty"`
+	Quantity int    `json:"quantity,omitempty"`
+	Status   bool   `json:"status"`
+	Message  string `json:"message,omitempty"`
+}
+
+type AutoSquareOffRun struct {
+	ID          int64                 `json:"id"`
+	Segment     string                `json:"segment"`
+	TradeDate   string                `json:"trade_date"`
+	Attempt     int                   `json:"attempt"`
+	Status      string                `json:"status"`
+	Actions     []AutoSquareOffAction `json:"actions"`
+	StartedAt   time.Time             `json:"started_at"`
+	CompletedAt time.Time             `json:"completed_at"`
+}
+
+type AutoSquareOffRunsResponse struct {
+	Status bool               `json:"status"`
+	Data   []AutoSquareOffRun `json:"data"`
+	Errors []e.Error          `json:"errors"`
+}
+
+const (
+	DeliveryProductValue = "C"
+
+	DefaultIntradayMarginPercent   = 20.0
+	DefaultDerivativeMarginPercent = 15.0
+	DefaultBracketMinMarginPercent = 10.0
+	DefaultCoverMinMarginPercent   = 10.0
+)
+
+/*
+PreviewOrderRequest is a dry run of an order, Order holds the body of
+PlaceOrder, PlaceBracketOrder or PlaceCoverOrder as per Kind including
+price_confirmed
+*/
+type PreviewOrderRequest struct {
+	Kind  string          `json:"kind" binding:"required,oneof=NORMAL BRACKET COVER"`
+	Order json.RawMessage `json:"order" binding:"required"`
+}
+
+type OrderCharges struct {
+	Brokerage       float64 `json:"brokerage"`
+	STT             float64 `json:"stt"`
+	ExchangeCharges float64 `json:"exchange_charges"`
+	SebiFees        float64 `json:"sebi_fees"`
+	StampDuty       float64 `json:"stamp_duty"`
+	GST             float64 `json:"gst"`
+	Total           float64 `json:"total"`
+}
+
+// PreviewPosition is the net position of the instrument before & after the order
+type PreviewPosition struct {
+	Exchange     string `json:"exchange"`
+	SecurityID   string `json:"security_id"`
+	Product      string `json:"product"`
+	CurrentQty   int    `json:"current_qty"`
+	ResultingQty int    `json:"resulting_qty"`
+}
+
+/*
+OrderPreview is the outcome of an order without placing it, Margins holds
+required margin of every product the order can be placed with
+*/
+type OrderPreview struct {
+	Valid          bool               `json:"valid"`
+	Rule           string             `json:"rule,omitempty"`
+	Reason         string             `json:"reason,omitempty"`
+	FieldErrors    []FieldError       `json:"field_errors,omitempty"`
+	Price          float64            `json:"price"`
+	OrderValue     float64            `json:"order_value"`
+	RequiredMargin float64            `json:"required_margin"`
+	Margins        map[string]float64 `json:"margins"`
+	Charges        OrderCharges       `json:"charges"`
+	Position       *PreviewPosition   `json:"position,omitempty"`
+}
+
+type PreviewOrderResponse struct {
+	Status      bool         `json:"status"`
+	Data        OrderPreview `json:"data"`
+	Errors      []e.Error    `json:"errors"`
+	FieldErrors []FieldError `json:"field_errors,omitempty"`
+}
+
+const (
+	KillSwitchGlobal  = "GLOBAL"
+	KillSwitchSegment = "SEGMENT"
+	KillSwitchUser    = "USER"
+
+	KillSwitchKeyPrefix = "trade:killswitch:"
+	// config letting orders through when kill switches cannot be read from redis
+	KillSwitchFailOpenConfig = "killSwitch.failOpen"
+	// error name of orders blocked by a kill switch
+	OrderBlockedError = "OrderBlocked"
+)
+
+/*
+KillSwitchRequest engages or releases the kill switch of the whole
+platform, a segment or a user, Value is segment or user id as per Scope
+*/
+type KillSwitchRequest struct {
+	Scope   string `json:"scope" binding:"required,oneof=GLOBAL SEGMENT USER"`
+	Value   string `json:"value"`
+	Enabled *bool  `json:"enabled" binding:"required"`
+	Reason  string `json:"reason"`
+}
+
+type KillSwitch struct {
+	Scope     string    `json:"scope"`
+	Value     string    `json:"value,omitempty"`
+	Enabled   bool      `json:"enabled"`
+	Reason    string    `json:"reason,omitempty"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type KillSwitchResponse struct {
+	Status      bool         `json:"status"`
+	Data        KillSwitch   `json:"data"`
+	Errors      []e.Error    `json:"errors"`
+	FieldErrors []FieldError `json:"field_errors,omitempty"`
+}
+
+const PaperTradingKeyPrefix = "trade:paper:"
+
+// PaperTradingRequest switches paper trading of the user on or off
+type PaperTradingRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+type PaperTrading struct {
+	UserID    string    `json:"user_id"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+type PaperTradingResponse struct {
+	Status      bool         `json:"status"`
+	Data        PaperTrading `json:"data"`
+	Errors      []e.Error    `json:"errors"`
+	FieldErrors []FieldError `json:"field_errors,omitempty"`
+}
+
+// VendorAnomaly is a count of vendor replies keyed by kind:api:code
+type VendorAnomaly struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+type VendorAnomaliesResponse struct {
+	Status bool            `json:"status"`
+	Data   []VendorAnomaly `json:"data"`
+	Errors []e.Error       `json:"errors"`
+}
+
+type CircuitBreakerStatus struct {
+	Endpoint string     `json:"endpoint"`
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	Probing  bool       `json:"probing"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+type CircuitBreakersResponse struct {
+	Status bool                   `json:"status"`
+	Data   []CircuitBreakerStatus `json:"data"`
+	Errors []e.Error              `json:"errors"`
+}
+
+/*
+envelopes declared outside this package with the field errors of a
+rejected request, field_errors is left out when there are none
+*/
+type PlaceOrderEnvelope struct {
+	PlaceOrderResponse
+	FieldErrors []FieldError `json:"field_errors,omitempty"`
+}
+
+type BracketOrderEnvelope struct {
+	PlaceBracketOrderResponse
+	FieldErrors []FieldError `json:"field_errors,omitempty"`
+}
+
+type ResponseEnvelope struct {
+	Response
+	FieldErrors []FieldError `json:"field_errors,omitempty"`
+}
+
+type ConvertPositionEnvelope struct {
+	ConvertPositionResponse
+	FieldErrors []FieldError `json:"field_errors,omitempty"`
+}
@@ -0,0 +1,114 @@
+package trade
+
+import (
+	"equity-trading/pkg/logger"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+/*
+current state of the order being modified from OrderBook, bracket &
+cover orders have a row per leg under the same order number so an
+open leg is preferred over executed ones
+*/
+func (s *trade) currentOrder(c *gin.Context, orderNo string) (BrokerOrder, error) {
+	book, er := s.broker(c).OrderBook(c)
+	if er != nil {
+		logger.Log.Error("modify: failed to fetch orderBook", zap.Error(er), zap.String("orderNo", orderNo))
+		return BrokerOrder{}, errors.New(":unable to fetch the order to validate modification, try again")
+	}
+	var (
+		current BrokerOrder
+		found   bool
+	)
+	for _, order := range book {
+		if order.OrderNo != orderNo {
+			continue
+		}
+		orderStatus, _ := ParseOrderStatus(order.Status)
+		if !found || orderStatus.Section() == Open {
+			current, found = order, true
+		}
+		if orderStatus.Section() == Open {
+			break
+		}
+	}
+	if !found {
+		return current, fieldError("order_no", "notFound", "order "+orderNo+" not found in order book")
+	}
+	return current, nil
+}
+
+/*
+modification is rejected for orders in a terminal status, quantity
+not above traded quantity as nothing would be left pending & changes to fields fixed at placement, empty
+values of the request are left as placed
+*/
+func checkOrderModification(current BrokerOrder, request ModifyOrderRequest) error {
+	orderStatus, _ := ParseOrderStatus(current.Status)
+	if orderStatus.IsTerminal() {
+		return fieldError("order_no", "notModifiable", fmt.Sprintf("order is %s, only open orders can be modified", current.Status), "status", current.Status)
+	}
+	if request.Qty > 0 && request.Qty <= current.TradedQty {
+		tradedQty := strconv.Itoa(current.TradedQty)
+		return fieldError("qty", "minTradedQty", "Quantity must be more than traded quantity "+tradedQty, "tradedQty", tradedQty)
+	}
+
+	type immutableField struct {
+		field, name, requested, placed string
+	}
+	immutable := []immutableField{
+		{field: "txn_type", name: "TxnType", requested: request.TxnType, placed: current.TxnType},
+		{field: "exchange", name: "Exchange", requested: request.Exchange, placed: current.Exchange},
+		{field: "segment", name: "Segment", requested: request.Segment, placed: current.Segment},
+		{field: "product", name: "Product", requested: request.Product, placed: current.Product},
+	}
+	if request.ExchangeToken > 0 {
+		immutable = append(immutable, immutableField{field: ExchangeTokenField, name: "ExchangeToken", requested: strconv.Itoa(request.ExchangeToken), placed: current.SecurityID})
+	}
+	for _, f := range immutable {
+		if len(f.requested) != 0 && len(f.placed) != 0 && !strings.EqualFold(f.requested, f.placed) {
+			return fieldError(f.field, "immutable", f.name+" cannot be modified, order is placed with "+f.placed, "current", f.placed)
+		}
+	}
+	return nil
+}
+
+/*
+order as it will be after the modification, values left empty in the
+request are taken from the order in OrderBook so risk rules see the
+trigger & limit price of the order even when only one of them changes
+*/
+func mergeOrderModification(current BrokerOrder, request ModifyOrderRequest) ModifyOrderRequest {
+	merged := request
+	fill := func(value *string, placed string) {
+		if len(*value) == 0 {
+			*value = placed
+		}
+	}
+	fill(&merged.TxnType, current.TxnType)
+	fill(&merged.Exchange, current.Exchange)
+	fill(&merged.Segment, current.Segment)
+	fill(&merged.Product, current.Product)
+	fill(&merged.OrderType, current.OrderType)
+	fill(&merged.Validity, current.Validity)
+	if merged.ExchangeToken == 0 {
+		merged.ExchangeToken, _ = strconv.Atoi(current.SecurityID)
+	}
+	if merged.Qty == 0 {
+		merged.Qty = current.Quantity
+	}
+	// prices of the placed order are kept only where the resulting order type uses them
+	if merged.Price == 0.0 && (merged.OrderType == LMT || merged.OrderType == SL) {
+		merged.Price = current.Price
+	}
+	if merged.TriggerPrice == 0.0 && (merged.OrderType == SL || merged.OrderType == SLM) {
+		merged.TriggerPrice = current.TriggerPrice
+	}
+	return merged
+}
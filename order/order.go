@@ -1,142 +1,275 @@
 package trade
 
 import (
-	"encoding/json"
+	db "equity-trading/pkg/db"
 	e "equity-trading/pkg/errors"
 	"equity-trading/pkg/logger"
+	"equity-trading/pkg/utils"
 	"errors"
-	"fmt"
 	"net/http"
+	"sort"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+type trade struct {
+	dbObj       db.DBLayer
+	restCaller  utils.RestCaller
+	redisCaller utils.RedisInterface
+}
+
 /*
 places normal order for product delivery, intraday with
 segment equity, derivative, currency, commodity
 with order type market, limit & validity ioc, intraday
 */
-type trade struct{}
 func (s *trade) PlaceOrder(c *gin.Context) {
 	var (
-		request  PlaceOrderRequest
-		response PlaceOrderResponse
+		request  ConfirmedPlaceOrderRequest
+		response PlaceOrderEnvelope
 	)
 	//  validating the request payload via gin framework
-	if err := c.BindJSON(&request); err != nil {
+	if err := bindOrderJSON(c, &request); err != nil {
 		logger.Log.Error("Invalid arguement received", zap.Error(err))
-		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(""))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
 		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
 	}
-	// validating the request payload for trigger order
-	if err := normalOrderValidation(request); err != nil {
-		logger.Log.Error("normalOrderValidation Failed,", zap.Error(err))
-		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(err.Error()))
-		c.JSON(http.StatusBadRequest, response)
-		c.Abort()
+	var placement normalPlacement
+	reservation, admitted := s.admitOrderEntry(c, orderEntry{
+		api:     OrderApi,
+		request: request,
+		segment: request.Segment,
+		prepare: func() (status int, err error) {
+			placement, status, err = s.prepareNormalOrder(c, request.PlaceOrderRequest, request.PriceConfirmed)
+			return status, err
+		},
+		orders: func() int { return placement.orders() },
+	}, &response)
+	if !admitted {
 		return
 	}
+	defer s.completeIdempotencyKey(c, reservation, &response)
 
-	st := time.Now()
-	//creating rupeeseed api url for normal order
-	uri := rupeeseedObj.EndPoint + OrderApi
-	//creating request body for calling rupeeseed api
-	requestBody := getRupeeseedOrderRequestBody(c, request)
-	//call rupeeseed normalOrder api
-	body, status, err := s.restCaller.InvokeHttp(http.MethodPost, uri, requestBody, rupeeseedHeaders, ApiTimeout) //calling the requestseed api with payload
-	logger.Log.Info("api details", zap.Any("lateny", time.Since(st)), zap.Any("status", status), zap.Error(err), zap.Any("data", string(body)))
-	if err != nil {
-		// Rupeeseed api error handling
-		logger.Log.Error("OrderEntry: rupeeseed api failure", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorApiFailure"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
+	ack, sliced, er := s.sendNormalOrder(c, placement)
+	if sliced != nil {
+		response.Data = sliced
+	}
+	if er != nil {
+		logger.Log.Error("order placement failed at broker", zap.String("msg", ack.Message), zap.Error(er))
+		response.Errors = append(response.Errors, er.Err)
+		// children placed before the failure stay live at the broker
+		if sliced != nil && sliced.placed() > 0 {
+			c.JSON(http.StatusMultiStatus, response)
+			return
+		}
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
 		c.Abort()
 		return
-	} else if status != http.StatusOK {
-		// Rupeeseed api unable to place order
-		logger.Log.Error("OrderEntry: api failure, failed to place order", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorConnectionFailure"].GetErrorDetails(""))
+	}
+	if sliced == nil {
+		response.Data = ack.Data
+	}
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+/*
+orderEntry is an order entry admitted by admitOrderEntry, request is as
+submitted by the client & fingerprints the Idempotency-Key
+*/
+type orderEntry struct {
+	api     string
+	request interface{}
+	segment string
+	// checks of the kind of order i.e trading session, risk rules & exposure limits, http status & error if the order cannot be placed
+	prepare func() (int, error)
+	// count of orders sent to the broker once prepared, a single order when not set
+	orders func() int
+}
+
+// reply of an order entry, rejections of admitOrderEntry are written to it
+type orderEntryReply interface {
+	reject(details e.Error, fieldErrors []FieldError)
+}
+
+func (r *PlaceOrderEnvelope) reject(details e.Error, fieldErrors []FieldError) {
+	r.Errors = append(r.Errors, details)
+	r.FieldErrors = fieldErrors
+}
+
+func (r *BracketOrderEnvelope) reject(details e.Error, fieldErrors []FieldError) {
+	r.Errors = append(r.Errors, details)
+	r.FieldErrors = fieldErrors
+}
+
+/*
+admission shared by PlaceOrder, PlaceBracketOrder & PlaceCoverOrder, in order
+  - a retried submission with the same Idempotency-Key gets the original
+    response before the order is checked again
+  - new orders are blocked while a kill switch is engaged
+  - the order is checked by prepare of the entry
+  - the Idempotency-Key is reserved right before the broker call, a
+    concurrent retry may have completed meanwhile
+
+rejections & replays are written to the reply & false is returned when
+the order is not to be sent, the caller completes the returned key once
+the response is written
+*/
+func (s *trade) admitOrderEntry(c *gin.Context, entry orderEntry, response orderEntryReply) (idempotencyReservation, bool) {
+	reject := func(status int, details e.Error, fieldErrors []FieldError) (idempotencyReservation, bool) {
+		response.reject(details, fieldErrors)
 		c.JSON(status, response)
 		c.Abort()
-		return
+		return idempotencyReservation{}, false
+	}
+
+	if original, err := s.idempotentReplay(c, entry.api, entry.request); err != nil {
+		logger.Log.Error("Idempotency-Key rejected", zap.Error(err))
+		status, details := idempotencyFailure(err)
+		return reject(status, details, nil)
+	} else if original != nil {
+		logger.Log.Info("replaying response of duplicate submission", zap.String("api:", entry.api))
+		replayIdempotentResponse(c, original)
+		return idempotencyReservation{}, false
+	}
+	if status, details, blocked := s.killSwitchRejection(c.GetString("userId"), entry.segment); blocked {
+		return reject(status, details, nil)
+	}
+	if status, err := entry.prepare(); err != nil {
+		setValidationRetryAfter(c, err)
+		return reject(status, validationDetails(status, err), fieldErrorsOf(err))
 	}
 
-	// struct for parsing the rupeeseed api response
-	var obj RupeeseedNormalOrderResponse
-	// unmarshal rupeeseed response
-	err = json.Unmarshal(body, &obj)
-	// error occured while unmarshal the response
+	orders := 1
+	if entry.orders != nil {
+		orders = entry.orders()
+	}
+	reservation, original, err := s.reserveIdempotencyKey(c, entry.api, entry.request, orders)
 	if err != nil {
-		logger.Log.Error("Failed to unmarshal rupeeseed output", zap.Error(err), zap.Any("recevied", string(body)))
-		response.Errors = append(response.Errors, e.ErrorInfo["JsonUnmarshalError"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
-		c.Abort()
-		return
+		logger.Log.Error("Idempotency-Key rejected", zap.Error(err))
+		status, details := idempotencyFailure(err)
+		return reject(status, details, nil)
 	}
-	// handling the failure of order placement at rupeeseed
-	if obj.Status != Success {
-		logger.Log.Error("order placement failed at rupeeseed", zap.String("msg", obj.Message), zap.String("errorCode", obj.ErrCode))
-		if e.RupeeseedErrors[obj.ErrCode] == http.StatusBadRequest {
-			response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message))) // Once error code recevies , need to handle errors
-			c.JSON(http.StatusBadRequest, response)
-		} else if e.RupeeseedErrors[obj.ErrCode] == http.StatusInternalServerError {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message)))
-			c.JSON(http.StatusInternalServerError, response)
-		} else {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
-			c.JSON(http.StatusInternalServerError, response)
-		}
-		c.Abort()
-		return
+	if original != nil {
+		logger.Log.Info("replaying response of duplicate submission", zap.String("api:", entry.api))
+		replayIdempotentResponse(c, original)
+		return idempotencyReservation{}, false
 	}
-	response.Data = obj.Data
-	response.Status = true
-	c.JSON(http.StatusOK, response)
+	return reservation, true
+}
+
+// normal order checked by prepareNormalOrder & ready to be sent to the broker
+type normalPlacement struct {
+	request   PlaceOrderRequest
+	freezeQty int
+	lotSize   int
+}
+
+// orders above freeze quantity are sliced into child orders
+func (p normalPlacement) sliced() bool {
+	return p.freezeQty > 0 && p.request.Quantity > p.freezeQty
+}
+
+// count of orders sendNormalOrder sends to the broker
+func (p normalPlacement) orders() int {
+	if !p.sliced() {
+		return 1
+	}
+	return len(sliceQuantity(p.request.Quantity, p.freezeQty, p.lotSize))
 }
 
 /*
-validating business constraints for placing
-normal order
+checks shared by every path placing a normal order i.e PlaceOrder &
+triggered GTT orders, the order is moved into the trading session,
+checked against risk rules & exposure limits and freeze quantity of
+derivatives is fetched for slicing
+
+	input:
+		context carrying the user
+		PlaceOrderRequest as submitted & if user confirmed a price far from last traded price
+	output:
+		normalPlacement to send through sendNormalOrder
+		http status & error if the order cannot be placed
 */
-func normalOrderValidation(request PlaceOrderRequest) error {
-	//limit trigger order validation
-
-	if request.OrderType == LMT && request.Price <= 0.0 {
-		return errors.New(":Price cannot be zero with limit order")
-	} else if request.OrderType == SL {
-		if request.Price <= 0.0 {
-			return errors.New(":Price cannot be zero with trigger limit order")
-		}
-		if request.TriggerPrice <= 0.0 {
-			return errors.New(":Trigger Price cannot be zero with limit order")
-		}
-		if request.Validity == IOC {
-			return errors.New(":Validity cannot be IOC with Trigger order") // NOTE this is SL/SLM orderType with Rupeeseed API
-		}
-		if request.TxnType == BUY && request.TriggerPrice > request.Price {
-			return errors.New(":Trigger Price cannot be greater than limit buy price")
-		}
-		if request.TxnType == SELL && request.TriggerPrice < request.Price {
-			return errors.New(":Trigger Price cannot be less than limit buy price")
-		}
-	} else if request.OrderType == SLM {
-		if request.TriggerPrice <= 0.0 {
-			return errors.New(":Trigger Price cannot be zero with limit order")
-		} else if request.Validity == IOC {
-			return errors.New(":Validity cannot be IOC with Trigger order") // NOTE this is SL/SLM orderType with Rupeeseed API
+func (s *trade) prepareNormalOrder(c *gin.Context, request PlaceOrderRequest, priceConfirmed bool) (normalPlacement, int, error) {
+	// orders outside trading session are rejected or converted to AMO
+	if err := applyTradingSession(&request); err != nil {
+		logger.Log.Error("order outside trading session", zap.Error(err))
+		return normalPlacement{}, http.StatusBadRequest, err
+	}
+	// validating the request payload for trigger order
+	if err := s.normalOrderValidation(c, request, priceConfirmed); err != nil {
+		logger.Log.Error("normalOrderValidation Failed,", zap.Error(err))
+		return normalPlacement{}, validationStatus(err), err
+	}
+
+	placement := normalPlacement{request: request}
+	// slicing derivative orders above exchange freeze quantity into child orders
+	if request.Segment != EquitySegment {
+		var err error
+		placement.freezeQty, placement.lotSize, err = s.freezeLimits(request)
+		if errors.Is(err, ErrScripNotFound) {
+			logger.Log.Error("instrument not found in scrip master", zap.Int("exchangeToken", request.ExchangeToken))
+			return normalPlacement{}, http.StatusBadRequest, errors.New(":ExchangeToken not found in scrip master")
+		} else if err != nil {
+			logger.Log.Error("failed to fetch freeze quantity from scrip master", zap.Error(err))
+			return normalPlacement{}, http.StatusInternalServerError, err
 		}
 	}
+	return placement, http.StatusOK, nil
+}
+
+/*
+sends a normal order prepared by prepareNormalOrder to the broker,
+orders above freeze quantity are placed as child orders under a parent
+id and returned as SlicedOrder
+*/
+func (s *trade) sendNormalOrder(c *gin.Context, placement normalPlacement) (OrderAck, *SlicedOrder, *BrokerError) {
+	request := placement.request
+	if placement.sliced() {
+		sliced, er := s.placeSlicedOrder(c, request, placement.freezeQty, placement.lotSize)
+		return OrderAck{}, &sliced, er
+	}
+	ack, er := s.broker(c).PlaceOrder(c, request)
+	return ack, nil, er
+}
 
-	if request.OffMktFlag && request.OffMktOrderTimeFlag <= 0 {
-		return errors.New(":OffMktOrderTimeFlag possible allowed values are 1|2|3 in AMO")
+/*
+validating business constraints for placing
+normal order through risk engine & exposure limits
+*/
+func (s *trade) normalOrderValidation(c *gin.Context, request PlaceOrderRequest, priceConfirmed bool) error {
+	order := normalRiskOrder(request)
+	order.PriceConfirmed = priceConfirmed
+	if err := riskEngine.Evaluate(order, s); err != nil {
+		return err
 	}
+	return s.checkExposureLimits(c, order)
+}
 
-	return nil
+func normalRiskOrder(request PlaceOrderRequest) RiskOrder {
+	return RiskOrder{
+		Action:              RiskPlace,
+		Kind:                NormalOrderKind,
+		TxnType:             request.TxnType,
+		Exchange:            request.Exchange,
+		Segment:             request.Segment,
+		Product:             request.Product,
+		OrderType:           request.OrderType,
+		Validity:            request.Validity,
+		ExchangeToken:       request.ExchangeToken,
+		Quantity:            request.Quantity,
+		Price:               request.Price,
+		TriggerPrice:        request.TriggerPrice,
+		OffMktFlag:          request.OffMktFlag,
+		OffMktOrderTimeFlag: request.OffMktOrderTimeFlag,
+	}
 }
 
 /*
@@ -146,307 +279,231 @@ with order type market, limit & validity ioc, intraday
 */
 func (s *trade) ModifyOrder(c *gin.Context) {
 	var (
-		request  ModifyOrderRequest
-		response Response
+		request  ConfirmedModifyOrderRequest
+		response ResponseEnvelope
 	)
 	//  validating the request payload via gin framework
-	if err := c.BindJSON(&request); err != nil {
+	if err := bindOrderJSON(c, &request); err != nil {
 		logger.Log.Error("Invalid arguement received", zap.Error(err))
-		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(""))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
 		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
 	}
 
-	//creating rupeeseed api url for normal order
-	uri := rupeeseedObj.EndPoint + ModifyOrderApi
-	//creating request body for calling rupeeseed api
-	requestBody := parseVendorRequestBody(c, request)
-	//call rupeeseed modifyNormalOrder api
-	body, status, err := s.restCaller.InvokeHttp(http.MethodPost, uri, requestBody, rupeeseedHeaders, ApiTimeout)
-	if err != nil {
-		// Rupeeseed api error handling
-		logger.Log.Error("OrderModify: rupeseed api failure", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorApiFailure"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
-		c.Abort()
-		return
-	} else if status != http.StatusOK {
-		// Rupeeseed api unable to modify order
-		logger.Log.Error("OrderEntry: api failure, failed to modify order", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorConnectionFailure"].GetErrorDetails(""))
+	// validating the modification against risk rules
+	if err := s.modifyOrderValidation(c, NormalOrderKind, request); err != nil {
+		logger.Log.Error("modifyOrderValidation Failed,", zap.String("kind", NormalOrderKind), zap.Error(err))
+		status := validationStatus(err)
+		response.Errors = append(response.Errors, validationDetails(status, err))
+		response.FieldErrors = fieldErrorsOf(err)
 		c.JSON(status, response)
 		c.Abort()
 		return
 	}
 
-	// struct for parsing the rupeeseed api response
-	var obj RupeeseedNormalOrderResponse
-	// unmarshal rupeeseed response
-	err = json.Unmarshal(body, &obj)
-	// error occured while unmarshal the response
-	if err != nil {
-		logger.Log.Error("Failed to marshal the error", zap.Error(err), zap.Any("recevied", string(body)))
-		response.Errors = append(response.Errors, e.ErrorInfo["JsonUnmarshalError"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
-		c.Abort()
-		return
-	}
-	// handling the failure of order modification at rupeeseed
-	if obj.Status != Success {
-		logger.Log.Error("order modify api failure at rupeeseed", zap.String("msg", obj.Message), zap.String("errorCode", obj.ErrCode))
-		if e.RupeeseedErrors[obj.ErrCode] == http.StatusBadRequest {
-			response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message))) // Once error code recevies , need to handle errors
-			c.JSON(http.StatusBadRequest, response)
-		} else if e.RupeeseedErrors[obj.ErrCode] == http.StatusInternalServerError {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message)))
-			c.JSON(http.StatusInternalServerError, response)
-		} else {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
-			c.JSON(http.StatusInternalServerError, response)
-		}
+	ack, er := s.broker(c).ModifyOrder(c, NormalOrderKind, request.ModifyOrderRequest)
+	if er != nil {
+		logger.Log.Error("order modification failed at broker", zap.String("msg", ack.Message), zap.Error(er))
+		response.Errors = append(response.Errors, er.Err)
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
 		c.Abort()
 		return
 	}
-	response.Data = obj.Data
+	response.Data = ack.Data
 	response.Status = true
 	response.Message = OrderModificationSuccess
 	c.JSON(http.StatusOK, response)
 }
 
 /*
-creating request body for calling rupeeseed api
-for normal order through func PlaceOrder
+OrderBook Returns details of orders placed by the user with
+orders status - Pending, Partially Executed, Executed, Rejected, Cancelled
+orders section - Open(pending, Partially Executed), Executed( Executed, Rejected, Cancelled)
 */
-func getRupeeseedOrderRequestBody(c *gin.Context, req PlaceOrderRequest) RupeeseedNormalOrderRequest {
-	temp := RupeeseedNormalOrderRequest{}
-	//userId := c.GetString("userId")
-	userId := "TEST2" // only for testing
-	temp.EntityId = userId
-	temp.Source = Source
-	temp.Data.ClientId = userId
-	temp.Data.UserId = userId
-	temp.Data.TxnType = req.TxnType
-	temp.Data.Exchange = req.Exchange
-	temp.Data.Segment = req.Segment
-	temp.Data.Product = req.Product
-	temp.Data.ExchangeToken = fmt.Sprintf("%d", req.ExchangeToken)
-	temp.Data.Qty = fmt.Sprintf("%d", req.Quantity)
-	temp.Data.Price = fmt.Sprintf("%f", req.Price)
-	temp.Data.Valdity = req.Validity
-	temp.Data.OrderType = req.OrderType
-	if req.DisclosedQty > 0 {
-		temp.Data.DisclosedQty = fmt.Sprintf("%d", req.Quantity)
-	}
-	if req.TriggerPrice > 0.0 {
-		temp.Data.TriggerPrice = fmt.Sprintf("%f", req.TriggerPrice)
-	}
-	temp.Data.OffMktFlag = "false"
-	if req.OffMktFlag {
-		temp.Data.OffMktFlag = "true"
-		if req.OffMktOrderTimeFlag > 0 {
-			temp.Data.EncashFlag = req.OffMktOrderTimeFlag
-		}
-	}
-
-	return temp
-}
+func (s *trade) OrderBook(c *gin.Context) {
+	var (
+		response OrderBookResponse
+	)
 
-/*
-function prepares a request in Ruppeeseed API format from custom input request
+	orders, er := s.broker(c).OrderBook(c)
+	if er != nil {
+		response.Errors = append(response.Errors, er.Err)
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
+		c.Abort()
+		return
+	}
+	//creating list of struct OrderBook
+	orderBookList := make([]OrderBook, 0)
+	for _, order := range orders {
+		if !filterOrder(c, order) { //process only filtered order
+			continue
+		}
 
-input
+		orderBook := order.OrderBook
+		orderBook.StreamSymbol = order.SecurityID + "_" + order.Exchange
 
-	*gin.Context - for fetching client
-	ConvertPositionRequest - cutom request to use to create Ruppeeseed API request
+		// normalising broker status through the order state machine
+		orderStatus, err := ParseOrderStatus(order.Status)
+		if err != nil {
+			logger.Log.Warn("OrderBook: unknown order status", zap.Error(err), zap.String("orderNo", order.OrderNo))
+		}
+		orderBook.RiseStatus = orderStatus.RiseStatus()
+		orderBook.Section = orderStatus.Section()
 
-output
+		orderBookList = append(orderBookList, orderBook)
+	}
+	// if OrderBook is empty, no order placed
+	if len(orderBookList) == 0 {
+		logger.Log.Error("OrderBook api failure: order not found in OrderBook")
+		response.Errors = append(response.Errors, e.ErrorInfo["NoDataFound"].GetErrorDetails("order not found in OrderBook."))
+		c.JSON(http.StatusNotFound, response)
+		c.Abort()
+		return
+	}
 
-	RuppeeseedConvertPositionRequest - Ruppeeseed API request
-*/
-func getRupeeseedConvertPositionRequestBody(c *gin.Context, req ConvertPositionRequest) RuppeeseedConvertPositionRequest {
-	temp := RuppeeseedConvertPositionRequest{}
-	//userId := c.GetString("userId")
-	userId := "TEST2" // only for testing
-	temp.EntityID = userId
-	temp.Source = Source
-	temp.Data.ClientID = userId
-	temp.Data.UserID = userId
-	temp.Data.Exchange = req.Exchange
-	temp.Data.SecurityID = req.ExchangeToken
-	temp.Data.Segment = req.Segment
-	temp.Data.Quantity = req.Quantity
-	temp.Data.MktType = RuppeeSeedMarketType
-	temp.Data.UserType = UserTypeClient
-	temp.Data.TxnType = req.PositionType
-	temp.Data.ProductFrom = req.PositionFrom
-	temp.Data.ProductTo = req.PositionTo
-
-	return temp
+	response.Data = orderBookList
+	response.Status = true
+	c.JSON(http.StatusOK, response)
 }
 
 /*
-OrderBook Returns details of orders placed by the user with
-orders status - Pending, Partially Executed, Executed, Rejected, Cancelled
-orders section - Open(pending, Partially Executed), Executed( Executed, Rejected, Cancelled)
+OrderHistory Returns the lifecycle of a single order i.e
+Transit → Pending → Modified → Part-traded → Traded with
+timestamp, quantity changes & rejection reason of every step
 */
-func (s *trade) OrderBook(c *gin.Context) {
+func (s *trade) OrderHistory(c *gin.Context) {
 	var (
-		response OrderBookResponse
+		response OrderHistoryResponse
 	)
 
-	st := time.Now()
-	//creating rupeeseed api url for OrderBook
-	uri := rupeeseedObj.EndPoint + OrderBookApi
-	//creating request body for calling rupeeseed OrderBook api
-	requestBody := getOrderBookRupeeseedRequestBody(c)
-	//call rupeeseed OrderBook api
-	body, status, err := s.restCaller.InvokeHttp(http.MethodPost, uri, requestBody, rupeeseedHeaders, ApiTimeout)
-	logger.Log.Info("api details", zap.Any("lateny", time.Since(st)), zap.Any("status", status), zap.Error(err), zap.Any("data", string(body)))
-	if err != nil {
-		// Rupeeseed api error handling
-		logger.Log.Error("OrderBook: rupeeseed api failure", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorApiFailure"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
+	orderNo := strings.TrimSpace(c.Query(OrderNoParam))
+	if len(orderNo) == 0 {
+		logger.Log.Error("OrderHistory: order number not received")
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(":orderNo is required"))
+		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
-	} else if status != http.StatusOK {
-		// Rupeeseed api unable to retreive orderBook i.e list of orders placed
-		logger.Log.Error("OrderBook: rupeeseed api failure, failed to retreive orderBook", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorConnectionFailure"].GetErrorDetails(""))
-		c.JSON(status, response)
+	}
+
+	events, er := s.broker(c).OrderHistory(c, orderNo)
+	if er != nil {
+		response.Errors = append(response.Errors, er.Err)
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
 		c.Abort()
 		return
 	}
-	// struct for parsing the rupeeseed api response
-	var obj RupeeseedOrderBookResponse
-	// unmarshal rupeeseed response
-	err = json.Unmarshal(body, &obj)
-	// error occured while unmarshal the response
-	if err != nil {
-		logger.Log.Error("Failed to marshal the error", zap.Error(err), zap.Any("recevied", string(body)))
-		response.Errors = append(response.Errors, e.ErrorInfo["JsonUnmarshalError"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
+	// if history is empty, order does not belong to the user
+	if len(events) == 0 {
+		logger.Log.Error("OrderHistory api failure: order not found", zap.String("orderNo", orderNo))
+		response.Errors = append(response.Errors, e.ErrorInfo["NoDataFound"].GetErrorDetails("order not found in OrderHistory."))
+		c.JSON(http.StatusNotFound, response)
 		c.Abort()
 		return
 	}
-	// handling the failure of OrderBook at rupeeseed
-	if obj.Status != Success {
-		logger.Log.Error("OrderBook api failure", zap.String("msg", obj.Message), zap.String("errorCode", obj.ErrorCode))
-		if e.RupeeseedErrors[obj.ErrorCode] == http.StatusBadRequest {
-			response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message))) // Once error code recevies , need to handle errors
-			c.JSON(http.StatusBadRequest, response)
-		} else if e.RupeeseedErrors[obj.ErrorCode] == http.StatusInternalServerError {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message)))
-			c.JSON(http.StatusInternalServerError, response)
+
+	response.Data = buildOrderTimeline(orderNo, events)
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+/*
+orders the history of the broker by serial number and computes
+quantity changes of every step against the previous step
+*/
+func buildOrderTimeline(orderNo string, history []BrokerOrderEvent) OrderTimeline {
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].SerialNo < history[j].SerialNo
+	})
+
+	timeline := OrderTimeline{OrderNo: orderNo}
+	timeline.Steps = make([]OrderTimelineStep, 0, len(history))
+	var (
+		prev       BrokerOrderEvent
+		prevStatus OrderStatus
+	)
+	for i, h := range history {
+		orderStatus, err := ParseOrderStatus(h.Status)
+		if err != nil {
+			logger.Log.Warn("OrderHistory: unknown order status", zap.Error(err), zap.String("orderNo", orderNo))
+		} else if i > 0 && !prevStatus.CanTransitionTo(orderStatus) {
+			logger.Log.Warn("OrderHistory: unexpected status transition", zap.String("orderNo", orderNo),
+				zap.String("from", string(prevStatus)), zap.String("to", string(orderStatus)))
+		}
+
+		var step OrderTimelineStep
+		step.Status = h.Status
+		step.RiseStatus = orderStatus.RiseStatus()
+		step.Time = h.LastUpdatedTime
+		step.Quantity = h.Quantity
+		step.TradedQty = h.TradedQty
+		step.RemainingQuantity = h.RemainingQuantity
+		step.Price = h.Price
+		step.TriggerPrice = h.TriggerPrice
+		step.ErrorCode = h.ErrorCode
+		step.Reason = h.ReasonDescription
+		if i == 0 {
+			step.QuantityChange = h.Quantity
+			step.TradedQtyChange = h.TradedQty
 		} else {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
-			c.JSON(http.StatusInternalServerError, response)
+			step.QuantityChange = h.Quantity - prev.Quantity
+			step.TradedQtyChange = h.TradedQty - prev.TradedQty
 		}
+		timeline.Steps = append(timeline.Steps, step)
+		prev = h
+		prevStatus = orderStatus
+	}
+	return timeline
+}
+
+/*
+TradeBook Returns every exchange fill of the orders placed by the user
+with fill quantity, fill price, exchange trade id & trade time
+supports the same searchTxt, segment, optionsType filters as OrderBook
+*/
+func (s *trade) TradeBook(c *gin.Context) {
+	var (
+		response TradeBookResponse
+	)
+
+	trades, er := s.broker(c).TradeBook(c)
+	if er != nil {
+		response.Errors = append(response.Errors, er.Err)
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
 		c.Abort()
 		return
 	}
-	//creating list of struct OrderBook
-	orderBookList := make([]OrderBook, 0)
-	for _, rOrderBook := range obj.Data {
-		var orderBook OrderBook
-
-		if !filterOrder(c, rOrderBook) { //process only filtered order
+	//creating list of struct TradeBook
+	tradeBookList := make([]TradeBook, 0)
+	for _, trade := range trades {
+		if !filterTrade(c, trade) { //process only filtered fills
 			continue
 		}
 
-		orderBook.GoodTillDaysDate = rOrderBook.GoodTillDaysDate
-		orderBook.Symbol = rOrderBook.Symbol
-		orderBook.DqQtyRem = rOrderBook.DqQtyRem
-		orderBook.DiscQuantity = rOrderBook.DiscQuantity
-		orderBook.Price = rOrderBook.Price
-		orderBook.Segment = rOrderBook.Segment
-		orderBook.LotSize = rOrderBook.LotSize
-		orderBook.OrderType = rOrderBook.OrderType
-		orderBook.SecurityID = rOrderBook.SecurityID
-		orderBook.ExpiryFlag = rOrderBook.ExpiryFlag
-		orderBook.DisplayName = rOrderBook.DisplayName
-		orderBook.ProductName = rOrderBook.ProductName
-		orderBook.LastUpdatedTime = rOrderBook.LastUpdatedTime
-		orderBook.TriggerPrice = rOrderBook.TriggerPrice
-		orderBook.ExchOrderTime = rOrderBook.ExchOrderTime
-		orderBook.Exchange = rOrderBook.Exchange
-		orderBook.ErrorCode = rOrderBook.ErrorCode
-		orderBook.SerialNo = rOrderBook.SerialNo
-		orderBook.Status = rOrderBook.Status
-		orderBook.OrderNo = rOrderBook.OrderNo
-		orderBook.RemainingQuantity = rOrderBook.RemainingQuantity
-		orderBook.ParticipantType = rOrderBook.ParticipantType
-		orderBook.Product = rOrderBook.Product
-		orderBook.OrderDateTime = rOrderBook.OrderDateTime
-		orderBook.Quantity = rOrderBook.Quantity
-		orderBook.ExpiryDate = rOrderBook.ExpiryDate
-		orderBook.ExchOrderNo = rOrderBook.ExchOrderNo
-		orderBook.TradedPrice = rOrderBook.TradedPrice
-		orderBook.TxnType = rOrderBook.TxnType
-		orderBook.RemQtyTotQty = rOrderBook.RemQtyTotQty
-		orderBook.Validity = rOrderBook.Validity
-		orderBook.AvgTradedPrice = rOrderBook.AvgTradedPrice
-		orderBook.TradedQty = rOrderBook.TradedQty
-		orderBook.StreamSymbol = rOrderBook.SecurityID + "_" + rOrderBook.Exchange
-
-		/* Rupeeseed status | Rise Status 			| Section
-		=========================================================
-			Transit 		| Pending 			  	| Open
-			Pending 		| Pending 			  	| Open
-			Modified 		| Pending 			  	| Open
-			Part-traded 	| Partially Executed 	| Open
-			Traded 			| Executed 				| Executed
-			Rejected 		| Rejected 				| Executed
-			Cancelled 		| Cancelled 			| Executed
-		*/
-		if rOrderBook.Status == Traded || rOrderBook.Status == Rejected || rOrderBook.Status == Cancelled {
-			if rOrderBook.Status == Traded {
-				orderBook.Status = Executed
-			}
-			orderBook.Section = Executed
-		}
-		if rOrderBook.Status == Transit || rOrderBook.Status == Pending || rOrderBook.Status == Modified || rOrderBook.Status == PartTraded {
-			if orderBook.Status == PartTraded {
-				orderBook.Status = PartExecuted
-			} else {
-				orderBook.Status = Pending
-			}
-			orderBook.Section = Open
-		}
+		fill := trade.TradeBook
+		fill.StreamSymbol = trade.SecurityID + "_" + trade.Exchange
 
-		orderBookList = append(orderBookList, orderBook)
+		tradeBookList = append(tradeBookList, fill)
 	}
-	// if OrderBook is empty, no order placed
-	if len(orderBookList) == 0 {
-		logger.Log.Error("OrderBook api failure: order not found in OrderBook", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["NoDataFound"].GetErrorDetails("order not found in OrderBook."))
+	// if TradeBook is empty, no order executed
+	if len(tradeBookList) == 0 {
+		logger.Log.Error("TradeBook api failure: trade not found in TradeBook")
+		response.Errors = append(response.Errors, e.ErrorInfo["NoDataFound"].GetErrorDetails("trade not found in TradeBook."))
 		c.JSON(http.StatusNotFound, response)
 		c.Abort()
 		return
 	}
 
-	response.Data = orderBookList
+	response.Data = tradeBookList
 	response.Status = true
 	c.JSON(http.StatusOK, response)
 }
 
-/*
-creating request body for calling rupeeseed api
-for OrderBook through func OrderBook
-*/
-func getOrderBookRupeeseedRequestBody(c *gin.Context) RupeeseedOrderBookRequest {
-	temp := RupeeseedOrderBookRequest{}
-	userId := "TEST2" // only for testing
-	//userId := c.GetString("userId")
-	temp.EntityId = userId
-	temp.Source = Source
-	temp.Data.ClientId = userId
-	temp.Data.UserId = userId
-
-	return temp
-}
-
 /*
 PositionBook Returns all open postions of segment
 equity (T day’s trades, MTF trades), derivative
@@ -457,15 +514,11 @@ func (s *trade) PositionBook(c *gin.Context) {
 		response PositionBookResponse
 	)
 
-	//Call rupeeseed PositionBook Api
-	obj, err := s.fetchPositionBook(c)
+	positions, err := s.broker(c).PositionBook(c)
 	if err != nil {
-		response.Errors = append(response.Errors, *err)
-		if err.ErrName == e.BadRequest {
-			c.JSON(http.StatusBadRequest, response)
-		} else {
-			c.JSON(http.StatusInternalServerError, response)
-		}
+		response.Errors = append(response.Errors, err.Err)
+		setRetryAfter(c, err)
+		c.JSON(brokerErrorStatus(err), response)
 		c.Abort()
 		return
 	}
@@ -473,51 +526,27 @@ func (s *trade) PositionBook(c *gin.Context) {
 	var totalPL float64
 
 	positionBookList := make([]OrderPositionBook, 0)
-	for _, rPosition := range obj.Data {
-
-		var position OrderPositionBook
-		position.Symbol = rPosition.Symbol
-		position.NetQty = rPosition.NetQty
-		position.SellAvg = rPosition.SellAvg
-		position.BuyAvg = rPosition.BuyAvg
-		position.GrossVal = rPosition.GrossVal
-		position.TotSellQty = rPosition.TotSellQty
-		position.LastTradedPrice = rPosition.LastTradedPrice
-		position.Segment = rPosition.Segment
-		position.GrossQty = rPosition.GrossQty
-		position.LotSize = rPosition.LotSize
-		position.TotSellVal = rPosition.TotSellVal
-		position.Product = rPosition.Product
-		position.NetAvg = rPosition.NetAvg
-		position.ExpiryDate = rPosition.ExpiryDate
-		position.TotSellValDay = rPosition.TotSellValDay
-		position.TotBuyVal = rPosition.TotBuyVal
-		position.NetVal = rPosition.NetVal
-		position.DisplayName = rPosition.DisplayName
-		position.TotBuyQty = rPosition.TotBuyQty
-		position.Exchange = rPosition.Exchange
-		position.SecurityID = rPosition.SecurityID
-		position.StreamSymbol = rPosition.SecurityID + "_" + rPosition.Exchange
-		position.RealisedProfit = rPosition.RealisedProfit
+	for _, position := range positions {
+		position.StreamSymbol = position.SecurityID + "_" + position.Exchange
 
 		var orderPL float64
 		var unrealizedPL float64
 		// if net quantity is +ve then it is a open buy position
-		if rPosition.NetQty > 0 {
+		if position.NetQty > 0 {
 			// formula to calculate unrealised profit for open buy position
 			// Total Qty*(LTP - Average Buy Price)
-			unrealizedPL = float64(rPosition.NetQty) * (rPosition.LastTradedPrice - rPosition.BuyAvg)
+			unrealizedPL = float64(position.NetQty) * (position.LastTradedPrice - position.BuyAvg)
 			// if net quantity is -ve then it is a open sell position
-		} else if rPosition.NetQty < 0 {
+		} else if position.NetQty < 0 {
 			//formula to calculate unrealised profit for open sell position
 			//Total Quantity*(Average Sell Price - LTP)
-			unrealizedPL = float64(rPosition.NetQty) * (rPosition.SellAvg - rPosition.LastTradedPrice)
+			unrealizedPL = float64(position.NetQty) * (position.SellAvg - position.LastTradedPrice)
 		}
 		// Total profit for a position =  unrealised profit + realised profit
-		// Realised profit is for closed positions, value of realised profit, we are getting it from Rupeeseed Api
+		// Realised profit is for closed positions, value of realised profit, we are getting it from the broker
 		// Unrealised profit is for open sell or open buy positions
 
-		orderPL = unrealizedPL + rPosition.RealisedProfit
+		orderPL = unrealizedPL + position.RealisedProfit
 
 		// Total profit loss for all the positions in position book
 		totalPL += orderPL
@@ -526,7 +555,7 @@ func (s *trade) PositionBook(c *gin.Context) {
 	}
 
 	if len(positionBookList) == 0 {
-		logger.Log.Error("PositionBook api failure: order not found in PositionBook", zap.Error(err))
+		logger.Log.Error("PositionBook api failure: order not found in PositionBook")
 		response.Errors = append(response.Errors, e.ErrorInfo["NoDataFound"].GetErrorDetails("order not found in PositionBook."))
 		c.JSON(http.StatusNotFound, response)
 		c.Abort()
@@ -539,68 +568,7 @@ func (s *trade) PositionBook(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-/*
-creating request body for calling rupeeseed api
-for NetPosition through func PositionBook
-*/
-func getPositionBookRupeeseedRequestBody(c *gin.Context) RupeeseedPositionBookRequest {
-	temp := RupeeseedPositionBookRequest{}
-	userId := "TEST2" // only for testing
-	//userId := c.GetString("userId")
-	temp.EntityId = userId
-	temp.Source = Source
-	temp.Data.ClientId = userId
-	temp.Data.UserId = userId
-	temp.Data.InteropFlag = "IP"
-
-	return temp
-}
-
-/*
-creating request body for calling rupeeseed api
-for modifying normal order, bracket order,
-cover order through func ModifyOrder,BracketOrderModify, CoverOrderModify
-*/
-func parseVendorRequestBody(c *gin.Context, req ModifyOrderRequest) VendorRequest {
-	temp := VendorRequest{}
-	//userId := c.GetString("userId")
-	userId := "TEST2" // only for testing
-	temp.EntityId = userId
-	temp.Source = Source
-	temp.Data.ClientId = userId
-	temp.Data.UserId = userId
-	temp.Data.TxnType = req.TxnType
-	temp.Data.Exchange = req.Exchange
-	temp.Data.Segment = req.Segment
-	temp.Data.Product = req.Product
-	temp.Data.ExchangeToken = fmt.Sprintf("%d", req.ExchangeToken)
-	temp.Data.Qty = fmt.Sprintf("%d", req.Qty)
-	temp.Data.Price = fmt.Sprintf("%f", req.Price)
-	temp.Data.Validity = req.Validity
-	temp.Data.OrderType = req.OrderType
-	temp.Data.DisclosedQty = fmt.Sprintf("%d", req.Qty)
-	temp.Data.TriggerPrice = fmt.Sprintf("%f", req.TriggerPrice)
-	temp.Data.OffMktFlag = fmt.Sprintf("%v", req.OffMktFlag)
-
-	//For Order Modify API
-	temp.Data.OrderNo = req.OrderNo
-	temp.Data.GroupId = fmt.Sprintf("%d", req.GroupId)
-	temp.Data.SerialNo = fmt.Sprintf("%d", req.SerialNo)
-
-	//For Cover Order
-	if req.Product == CoProductValue {
-		temp.Data.LegNo = fmt.Sprintf("%f", req.LegNo)
-	}
-
-	//For Bracket Order
-	if req.Product == CoProductValue {
-		temp.Data.LegNo = fmt.Sprintf("%d", int(req.LegNo))
-		temp.Data.AlgoOrderNo = req.AlgoOrderNo
-	}
-	return temp
-}
-
-func filterOrder(ctx *gin.Context, order RupeeseedOrderBook) bool {
+func filterOrder(ctx *gin.Context, order BrokerOrder) bool {
 	flag := true
 
 	searchTxt := strings.ToLower(strings.TrimSpace(ctx.Query(SearchTxt)))
@@ -622,16 +590,12 @@ func filterOrder(ctx *gin.Context, order RupeeseedOrderBook) bool {
 	}
 
 	if len(status) != 0 {
-		orderStatus := strings.ToLower(order.Status)
-		if status == strings.ToLower(Open) {
-			flag = flag && (orderStatus == strings.ToLower(Transit) ||
-				orderStatus == strings.ToLower(Pending) ||
-				orderStatus == strings.ToLower(Modified) ||
-				orderStatus == strings.ToLower(PartTraded))
-		} else if status == strings.ToLower(Executed) {
-			flag = flag && (orderStatus == strings.ToLower(Traded) ||
-				orderStatus == strings.ToLower(Rejected) ||
-				orderStatus == strings.ToLower(Cancelled))
+		orderStatus, err := ParseOrderStatus(order.Status)
+		if err != nil {
+			logger.Log.Warn("filterOrder: unknown order status", zap.Error(err), zap.String("orderNo", order.OrderNo))
+		}
+		if status == strings.ToLower(Open) || status == strings.ToLower(Executed) {
+			flag = flag && strings.ToLower(orderStatus.Section()) == status
 		} else {
 			flag = false //If status value is other than open/executed
 		}
@@ -640,6 +604,29 @@ func filterOrder(ctx *gin.Context, order RupeeseedOrderBook) bool {
 	return flag
 }
 
+func filterTrade(ctx *gin.Context, trade BrokerTrade) bool {
+	flag := true
+
+	searchTxt := strings.ToLower(strings.TrimSpace(ctx.Query(SearchTxt)))
+	segment := strings.ToLower(strings.TrimSpace(ctx.Query(Segment)))
+	optionsType := strings.ToLower(strings.TrimSpace(ctx.Query(OptionsType)))
+
+	if len(searchTxt) != 0 {
+		flag = flag && (strings.Contains(strings.ToLower(trade.Symbol), searchTxt) ||
+			strings.Contains(strings.ToLower(trade.DisplayName), searchTxt))
+	}
+
+	if len(segment) != 0 {
+		flag = flag && strings.ToLower(trade.Segment) == segment
+	}
+
+	if len(optionsType) != 0 {
+		flag = flag && strings.ToLower(trade.OptType) == optionsType
+	}
+
+	return flag
+}
+
 /*
 places bracket order with product B(BO – Bracket Order)
 segment E(equity), D(derivative)
@@ -648,122 +635,55 @@ validity IOC(Immediate or cance), DAY(Intraday)
 */
 func (s *trade) PlaceBracketOrder(c *gin.Context) {
 	var (
-		request  PlaceBracketOrderRequest
-		response PlaceBracketOrderResponse
+		request  ConfirmedBracketOrderRequest
+		response BracketOrderEnvelope
 	)
 	//  validating the request payload via gin framework
-	if err := c.BindJSON(&request); err != nil {
+	if err := bindOrderJSON(c, &request); err != nil {
 		logger.Log.Error("Invalid arguement received", zap.Error(err))
-		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(""))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
 		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
 	}
-
-	// validating the request payload for bracket order
-	if err := bracketOrderValidation(request); err != nil {
-		logger.Log.Error("bracketOrderValidation Failed,", zap.Error(err))
-		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(err.Error()))
-		c.JSON(http.StatusBadRequest, response)
-		c.Abort()
-		return
-	}
-
-	st := time.Now()
-	//creating rupeeseed api url for BoOrderEntry
-	uri := rupeeseedObj.EndPoint + BracketOrderApi
-	//creating request body for calling rupeeseed api
-	requestBody := getRupeseedBracketRequestBody(c, request)
-	//call rupeeseed BoOrderEntry api
-	body, status, err := s.restCaller.InvokeHttp(http.MethodPost, uri, requestBody, rupeeseedHeaders, ApiTimeout)
-	logger.Log.Info("api details", zap.Any("lateny", time.Since(st)), zap.Any("status", status), zap.Error(err), zap.Any("data", string(body)))
-	if err != nil {
-		// Rupeeseed api error handling
-		logger.Log.Error("BoOrderEntry: rupeeseed api failure", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorApiFailure"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
-		c.Abort()
-		return
-	} else if status != http.StatusOK {
-		logger.Log.Error("BoOrderEntry: api failure, failed to place bracket order", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorConnectionFailure"].GetErrorDetails(""))
-		c.JSON(status, response)
-		c.Abort()
+	reservation, admitted := s.admitOrderEntry(c, orderEntry{
+		api:     BracketOrderApi,
+		request: request,
+		segment: request.Segment,
+		prepare: func() (int, error) {
+			// orders outside trading session are rejected or converted to AMO
+			if err := applyBracketTradingSession(&request.PlaceBracketOrderRequest); err != nil {
+				logger.Log.Error("bracket order outside trading session", zap.Error(err))
+				return http.StatusBadRequest, err
+			}
+			// validating the request payload for bracket order
+			if err := s.bracketOrderValidation(c, request); err != nil {
+				logger.Log.Error("bracketOrderValidation Failed,", zap.Error(err))
+				return validationStatus(err), err
+			}
+			return http.StatusOK, nil
+		},
+	}, &response)
+	if !admitted {
 		return
 	}
+	defer s.completeIdempotencyKey(c, reservation, &response)
 
-	// struct for parsing the rupeeseed api response
-	var obj RupeeseedBracketOrderResponse
-	// unmarshal rupeeseed response
-	err = json.Unmarshal(body, &obj)
-	// error occured while unmarshal the response
-	if err != nil {
-		logger.Log.Error("Failed to marshal the error", zap.Error(err), zap.Any("recevied", string(body)))
-		response.Errors = append(response.Errors, e.ErrorInfo["JsonUnmarshalError"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
-		c.Abort()
-		return
-	}
-	// handling the failure of Bracket order placement at rupeeseed
-	if obj.Status != Success {
-		logger.Log.Error("BoOrderEntry entry api failure", zap.String("msg", obj.Message), zap.String("errorCode", obj.ErrCode))
-		if e.RupeeseedErrors[obj.ErrCode] == http.StatusBadRequest {
-			response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message))) // Once error code recevies , need to handle errors
-			c.JSON(http.StatusBadRequest, response)
-		} else if e.RupeeseedErrors[obj.ErrCode] == http.StatusInternalServerError {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message)))
-			c.JSON(http.StatusInternalServerError, response)
-		} else {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
-			c.JSON(http.StatusInternalServerError, response)
-		}
+	ack, er := s.broker(c).PlaceBracketOrder(c, request.PlaceBracketOrderRequest)
+	if er != nil {
+		logger.Log.Error("bracket order placement failed at broker", zap.String("msg", ack.Message), zap.Error(er))
+		response.Errors = append(response.Errors, er.Err)
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
 		c.Abort()
 		return
 	}
-	response.Data = obj.Data
+	response.Data = ack.Data
 	response.Status = true
 	c.JSON(http.StatusOK, response)
 }
 
-/*
-creating request body for calling rupeeseed api
-for BoOrderEntry through func PlaceBracketOrder
-*/
-func getRupeseedBracketRequestBody(c *gin.Context, req PlaceBracketOrderRequest) RupeseedBracketOrderRequest {
-	userId := "TEST2" // only for testing
-	temp := RupeseedBracketOrderRequest{}
-	//temp.EntityID = c.GetString("entityId")
-	temp.EntityID = userId
-	temp.Source = Source
-	//temp.Data.ClientID = c.GetString("clientId")
-	temp.Data.ClientID = userId
-	temp.Data.TxnType = req.TxnType
-	temp.Data.Exchange = req.Exchange
-	temp.Data.Segment = req.Segment
-	temp.Data.Product = req.Product
-	temp.Data.Exchange = req.Exchange
-	temp.Data.Quantity = fmt.Sprintf("%d", req.Quantity)
-	temp.Data.Price = fmt.Sprintf("%f", req.Price)
-	temp.Data.Validity = req.Validity
-	temp.Data.OrderType = req.OrderType
-	temp.Data.ProfitValue = fmt.Sprintf("%f", req.ProfitValue)
-	temp.Data.StoplossValue = fmt.Sprintf("%f", req.StoplossValue)
-	temp.Data.ExchangeToken = fmt.Sprintf("%d", req.ExchangeToken)
-	if req.ProfitValue > 0.0 {
-		temp.Data.ProfitValue = fmt.Sprintf("%f", req.ProfitValue)
-	}
-	if req.StoplossValue > 0.0 {
-		temp.Data.StoplossValue = fmt.Sprintf("%f", req.StoplossValue)
-	}
-	if req.OffMktFlag {
-		temp.Data.OffMktFlag = "true"
-	} else {
-		temp.Data.OffMktFlag = "false"
-	}
-
-	return temp
-}
-
 /*
 places cover order with product V(CO – Cover Order)
 segment E(equity), D(derivative)
@@ -772,257 +692,270 @@ validity IOC(Immediate or cancel), DAY(Intraday)
 */
 func (s *trade) PlaceCoverOrder(c *gin.Context) {
 	var (
-		request  PlaceCoverOrderRequest
-		response PlaceOrderResponse
+		request  ConfirmedCoverOrderRequest
+		response PlaceOrderEnvelope
 	)
 	//  validating the request payload via gin framework
-	if err := c.BindJSON(&request); err != nil {
+	if err := bindOrderJSON(c, &request); err != nil {
 		logger.Log.Error("Invalid arguement received", zap.Error(err))
-		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(""))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
 		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
 	}
-
-	// validating the request payload for cover order
-	if err := coverOrderValidation(request); err != nil {
-		logger.Log.Error("coverOrderValidation Failed,", zap.Error(err))
-		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(err.Error()))
-		c.JSON(http.StatusBadRequest, response)
-		c.Abort()
+	reservation, admitted := s.admitOrderEntry(c, orderEntry{
+		api:     CoverOrderApi,
+		request: request,
+		segment: request.Segment,
+		prepare: func() (int, error) {
+			// orders outside trading session are rejected or converted to AMO
+			if err := applyCoverTradingSession(&request.PlaceCoverOrderRequest); err != nil {
+				logger.Log.Error("cover order outside trading session", zap.Error(err))
+				return http.StatusBadRequest, err
+			}
+			// validating the request payload for cover order
+			if err := s.coverOrderValidation(c, request); err != nil {
+				logger.Log.Error("coverOrderValidation Failed,", zap.Error(err))
+				return validationStatus(err), err
+			}
+			return http.StatusOK, nil
+		},
+	}, &response)
+	if !admitted {
 		return
 	}
+	defer s.completeIdempotencyKey(c, reservation, &response)
 
-	st := time.Now()
-	//creating rupeeseed api url for CoOrderEntry
-	uri := rupeeseedObj.EndPoint + CoverOrderApi
-	//creating request body for calling rupeeseed api
-	requestBody := getRupeseedCoverRequestBody(c, request)
-	//call rupeeseed CoOrderEntry api
-	body, status, err := s.restCaller.InvokeHttp(http.MethodPost, uri, requestBody, rupeeseedHeaders, ApiTimeout)
-	logger.Log.Info("api details", zap.Any("lateny", time.Since(st)), zap.Any("status", status), zap.Error(err), zap.Any("data", string(body)))
-	if err != nil {
-		// Rupeeseed api error handling
-		logger.Log.Error("CoOrderEntry: rupeeseed api failure", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorApiFailure"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
+	ack, er := s.broker(c).PlaceCoverOrder(c, request.PlaceCoverOrderRequest)
+	if er != nil {
+		logger.Log.Error("cover order placement failed at broker", zap.String("msg", ack.Message), zap.Error(er))
+		response.Errors = append(response.Errors, er.Err)
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
 		c.Abort()
 		return
-	} else if status != http.StatusOK {
-		logger.Log.Error("CoOrderEntry: rupeeseed api failure, failed to place bracket order", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorConnectionFailure"].GetErrorDetails(""))
-		c.JSON(status, response)
+	}
+	response.Data = ack.Data
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+/*
+handles modification bracket order with product B(BO – Bracket Order)
+segment E(equity), D(derivative)
+with order type MKT(market), LMT(limit) &
+validity IOC(Immediate or cance), DAY(Intraday)
+*/
+func (s *trade) BracketOrderModify(c *gin.Context) {
+	var (
+		request  ConfirmedModifyOrderRequest
+		response ResponseEnvelope
+	)
+	//  validating the request payload via gin framework
+	if err := bindOrderJSON(c, &request); err != nil {
+		logger.Log.Error("Invalid arguement received", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
 	}
 
-	// struct for parsing the rupeeseed api response
-	var obj RupeeseedNormalOrderResponse
-	// unmarshal rupeeseed response
-	err = json.Unmarshal(body, &obj)
-	// error occured while unmarshal the response
-	if err != nil {
-		logger.Log.Error("Failed to marshal the error", zap.Error(err), zap.Any("recevied", string(body)))
-		response.Errors = append(response.Errors, e.ErrorInfo["JsonUnmarshalError"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
+	// validating the modification against risk rules
+	if err := s.modifyOrderValidation(c, BracketOrderKind, request); err != nil {
+		logger.Log.Error("modifyOrderValidation Failed,", zap.String("kind", BracketOrderKind), zap.Error(err))
+		status := validationStatus(err)
+		response.Errors = append(response.Errors, validationDetails(status, err))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(status, response)
 		c.Abort()
 		return
 	}
-	// handling the failure of Cover order placement at rupeeseed
-	if obj.Status != Success {
-		logger.Log.Error("CoOrderEntry api failure", zap.String("msg", obj.Message), zap.String("errorCode", obj.ErrCode))
-		if e.RupeeseedErrors[obj.ErrCode] == http.StatusBadRequest {
-			response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message))) // Once error code recevies , need to handle errors
-			c.JSON(http.StatusBadRequest, response)
-		} else if e.RupeeseedErrors[obj.ErrCode] == http.StatusInternalServerError {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message)))
-			c.JSON(http.StatusInternalServerError, response)
-		} else {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
-			c.JSON(http.StatusInternalServerError, response)
-		}
+
+	ack, er := s.broker(c).ModifyOrder(c, BracketOrderKind, request.ModifyOrderRequest)
+	if er != nil {
+		logger.Log.Error("bracket order modification failed at broker", zap.String("msg", ack.Message), zap.Error(er))
+		response.Errors = append(response.Errors, er.Err)
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
 		c.Abort()
 		return
 	}
-	response.Data = obj.Data
+	response.Data = ack.Data
 	response.Status = true
+	response.Message = OrderModificationSuccess
 	c.JSON(http.StatusOK, response)
 }
 
 /*
-creating request body for calling rupeeseed api
-for CoOrderEntry through func PlaceCoverOrder
-*/
-func getRupeseedCoverRequestBody(c *gin.Context, req PlaceCoverOrderRequest) RupeseedCoverOrderRequest {
-	userId := "TEST2" // only for testing
-	temp := RupeseedCoverOrderRequest{}
-	//temp.EntityID = c.GetString("entityId")
-	temp.EntityID = userId
-	temp.Source = Source
-	//temp.Data.ClientID = c.GetString("clientId")
-	temp.Data.ClientID = userId
-	temp.Data.TxnType = req.TxnType
-	temp.Data.Exchange = req.Exchange
-	temp.Data.Segment = req.Segment
-	temp.Data.Product = req.Product
-	temp.Data.Exchange = req.Exchange
-	temp.Data.Quantity = fmt.Sprintf("%d", req.Quantity)
-	temp.Data.Price = fmt.Sprintf("%f", req.Price)
-	temp.Data.Validity = req.Validity
-	temp.Data.OrderType = req.OrderType
-	temp.Data.TriggerPrice = fmt.Sprintf("%f", req.TriggerPrice)
-	temp.Data.ExchangeToken = fmt.Sprintf("%d", req.ExchangeToken)
-	if req.OffMktFlag {
-		temp.Data.OffMktFlag = "true"
-	} else {
-		temp.Data.OffMktFlag = "false"
-	}
-
-	return temp
-}
-
-/*
-handles modification bracket order with product B(BO – Bracket Order)
+handles modification bracket order with product V(CO – Cover Order)
 segment E(equity), D(derivative)
 with order type MKT(market), LMT(limit) &
 validity IOC(Immediate or cance), DAY(Intraday)
 */
-func (s *trade) BracketOrderModify(c *gin.Context) {
+func (s *trade) CoverOrderModify(c *gin.Context) {
 	var (
-		request  ModifyOrderRequest
-		response Response
+		request  ConfirmedModifyOrderRequest
+		response ResponseEnvelope
 	)
 	//  validating the request payload via gin framework
-	if err := c.BindJSON(&request); err != nil {
+	if err := bindOrderJSON(c, &request); err != nil {
 		logger.Log.Error("Invalid arguement received", zap.Error(err))
-		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(""))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
 		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
 	}
 
-	//creating rupeeseed api url for bracket order
-	uri := rupeeseedObj.EndPoint + BoModifyOrderAPI
-	//creating request body for calling rupeeseed api
-	requestBody := parseVendorRequestBody(c, request)
-	//call rupeeseed modify BoOrderModify api
-	body, status, err := s.restCaller.InvokeHttp(http.MethodPost, uri, requestBody, rupeeseedHeaders, ApiTimeout)
-	if err != nil {
-		logger.Log.Error("BoOrderModify: rupeseed api failure", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorApiFailure"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
+	// validating the modification against risk rules
+	if err := s.modifyOrderValidation(c, CoverOrderKind, request); err != nil {
+		logger.Log.Error("modifyOrderValidation Failed,", zap.String("kind", CoverOrderKind), zap.Error(err))
+		status := validationStatus(err)
+		response.Errors = append(response.Errors, validationDetails(status, err))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(status, response)
 		c.Abort()
 		return
-	} else if status != http.StatusOK {
-		logger.Log.Error("BoOrderModify: rupeseed api failure, failed to modify bracket order", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorConnectionFailure"].GetErrorDetails(""))
-		c.JSON(status, response)
+	}
+
+	ack, er := s.broker(c).ModifyOrder(c, CoverOrderKind, request.ModifyOrderRequest)
+	if er != nil {
+		logger.Log.Error("cover order modification failed at broker", zap.String("msg", ack.Message), zap.Error(er))
+		response.Errors = append(response.Errors, er.Err)
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
 		c.Abort()
 		return
 	}
-	// struct for parsing the rupeeseed api response
-	var obj RupeeseedNormalOrderResponse
-	// unmarshal rupeeseed response
-	err = json.Unmarshal(body, &obj)
-	// error occured while unmarshal the response
-	if err != nil {
-		logger.Log.Error("Failed to marshal the error", zap.Error(err), zap.Any("recevied", string(body)))
-		response.Errors = append(response.Errors, e.ErrorInfo["JsonUnmarshalError"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
+	response.Data = ack.Data
+	response.Status = true
+	response.Message = OrderModificationSuccess
+	c.JSON(http.StatusOK, response)
+}
+
+/*
+handles cancellation of pending normal order for product delivery, intraday with
+segment equity, derivative, currency, commodity
+*/
+func (s *trade) CancelOrder(c *gin.Context) {
+	var (
+		request  CancelOrderRequest
+		response ResponseEnvelope
+	)
+	//  validating the request payload via gin framework
+	if err := bindOrderJSON(c, &request); err != nil {
+		logger.Log.Error("Invalid arguement received", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
 	}
-	// handling the failure of bracket order modification at rupeeseed
-	if obj.Status != Success {
-		logger.Log.Error("bracket order modify api failure", zap.String("msg", obj.Message), zap.String("errorCode", obj.ErrCode))
-		if e.RupeeseedErrors[obj.ErrCode] == http.StatusBadRequest {
-			response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message))) // Once error code recevies , need to handle errors
-			c.JSON(http.StatusBadRequest, response)
-		} else if e.RupeeseedErrors[obj.ErrCode] == http.StatusInternalServerError {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message)))
-			c.JSON(http.StatusInternalServerError, response)
-		} else {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
-			c.JSON(http.StatusInternalServerError, response)
-		}
+
+	ack, er := s.broker(c).CancelOrder(c, request)
+	if er != nil {
+		logger.Log.Error("order cancellation failed at broker", zap.String("msg", ack.Message), zap.Error(er))
+		response.Errors = append(response.Errors, er.Err)
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
 		c.Abort()
 		return
 	}
-	response.Data = obj.Data
+	response.Data = ack.Data
 	response.Status = true
-	response.Message = OrderModificationSuccess
+	response.Message = OrderCancellationSuccess
 	c.JSON(http.StatusOK, response)
 }
 
 /*
-handles modification bracket order with product V(CO – Cover Order)
-segment E(equity), D(derivative)
-with order type MKT(market), LMT(limit) &
-validity IOC(Immediate or cance), DAY(Intraday)
+exits bracket order with product B(BO – Bracket Order)
+pending main leg is cancelled, executed main leg squares off
+the profit & stoploss legs
 */
-func (s *trade) CoverOrderModify(c *gin.Context) {
+func (s *trade) BracketOrderExit(c *gin.Context) {
 	var (
-		request  ModifyOrderRequest
-		response Response
+		request  CancelOrderRequest
+		response ResponseEnvelope
 	)
 	//  validating the request payload via gin framework
-	if err := c.BindJSON(&request); err != nil {
+	if err := bindOrderJSON(c, &request); err != nil {
 		logger.Log.Error("Invalid arguement received", zap.Error(err))
-		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(""))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
 		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
 	}
 
-	//creating rupeeseed api url for CoOrderModify
-	uri := rupeeseedObj.EndPoint + CoModifyOrderApi
-	//call rupeeseed modify CoOrderModify api
-	requestBody := parseVendorRequestBody(c, request)
-	body, status, err := s.restCaller.InvokeHttp(http.MethodPost, uri, requestBody, rupeeseedHeaders, ApiTimeout)
-	if err != nil {
-		logger.Log.Error("CoverOrderModify: rupeseed api failure", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorApiFailure"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
+	// validating the request payload for bracket order exit
+	if err := bracketExitValidation(request); err != nil {
+		logger.Log.Error("bracketExitValidation Failed,", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(err.Error()))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
-	} else if status != http.StatusOK {
-		logger.Log.Error("CoverOrderModify: rupeseed api failure", zap.Error(err), zap.String("api:", uri))
-		response.Errors = append(response.Errors, e.ErrorInfo["VendorConnectionFailure"].GetErrorDetails(""))
-		c.JSON(status, response)
+	}
+
+	ack, er := s.broker(c).ExitOrder(c, BracketOrderKind, request)
+	if er != nil {
+		logger.Log.Error("bracket order exit failed at broker", zap.String("msg", ack.Message), zap.Error(er))
+		response.Errors = append(response.Errors, er.Err)
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
 		c.Abort()
 		return
 	}
-	// struct for parsing the rupeeseed api response
-	var obj RupeeseedNormalOrderResponse
-	// unmarshal rupeeseed response
-	err = json.Unmarshal(body, &obj)
-	// error occured while unmarshal the response
-	if err != nil {
-		logger.Log.Error("Failed to marshal the error", zap.Error(err), zap.Any("recevied", string(body)))
-		response.Errors = append(response.Errors, e.ErrorInfo["JsonUnmarshalError"].GetErrorDetails(""))
-		c.JSON(http.StatusInternalServerError, response)
+	response.Data = ack.Data
+	response.Status = true
+	response.Message = OrderExitSuccess
+	c.JSON(http.StatusOK, response)
+}
+
+/*
+exits cover order with product V(CO – Cover Order)
+pending main leg is cancelled, executed main leg squares off
+the stoploss leg
+*/
+func (s *trade) CoverOrderExit(c *gin.Context) {
+	var (
+		request  CancelOrderRequest
+		response ResponseEnvelope
+	)
+	//  validating the request payload via gin framework
+	if err := bindOrderJSON(c, &request); err != nil {
+		logger.Log.Error("Invalid arguement received", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
 	}
-	// handling the failure of cover order modification at rupeeseed
-	if obj.Status != Success {
-		logger.Log.Error("cover order modify api failure", zap.String("msg", obj.Message), zap.String("errorCode", obj.ErrCode))
-		if e.RupeeseedErrors[obj.ErrCode] == http.StatusBadRequest {
-			response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message))) // Once error code recevies , need to handle errors
-			c.JSON(http.StatusBadRequest, response)
-		} else if e.RupeeseedErrors[obj.ErrCode] == http.StatusInternalServerError {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message)))
-			c.JSON(http.StatusInternalServerError, response)
-		} else {
-			response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
-			c.JSON(http.StatusInternalServerError, response)
-		}
+
+	// validating the request payload for cover order exit
+	if err := coverExitValidation(request); err != nil {
+		logger.Log.Error("coverExitValidation Failed,", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(err.Error()))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
 	}
-	response.Data = obj.Data
+
+	ack, er := s.broker(c).ExitOrder(c, CoverOrderKind, request)
+	if er != nil {
+		logger.Log.Error("cover order exit failed at broker", zap.String("msg", ack.Message), zap.Error(er))
+		response.Errors = append(response.Errors, er.Err)
+		setRetryAfter(c, er)
+		c.JSON(brokerErrorStatus(er), response)
+		c.Abort()
+		return
+	}
+	response.Data = ack.Data
 	response.Status = true
-	response.Message = OrderModificationSuccess
+	response.Message = OrderExitSuccess
 	c.JSON(http.StatusOK, response)
 }
 
@@ -1034,18 +967,19 @@ steps within api
   - identify open positions
   - match request parameters with open positions (product, position type(B.S), exchange, security id)
   - check if surplus quantity for conversion is available
-  - call ruppeeseed api to convert
+  - call broker to convert
   - analyse result and reply
 */
 func (s *trade) ConvertPosition(c *gin.Context) {
 	//fetch position book
 	var (
 		request  ConvertPositionRequest
-		response ConvertPositionResponse
+		response ConvertPositionEnvelope
 	)
-	if err := c.BindJSON(&request); err != nil {
+	if err := bindOrderJSON(c, &request); err != nil {
 		logger.Log.Error("Invalid arguement received for Convert Position", zap.Error(err))
-		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(""))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
 		c.JSON(http.StatusBadRequest, response)
 		c.Abort()
 		return
@@ -1053,17 +987,17 @@ func (s *trade) ConvertPosition(c *gin.Context) {
 	request.UserID = c.GetString("userId")
 
 	//fetch the net position for the user and check if there is any position matching this conversion requirement
-	obj, err := s.fetchPositionBook(c)
+	positions, err := s.broker(c).PositionBook(c)
 	if err != nil {
-		response.Errors = append(response.Errors, *err)
-		c.JSON(http.StatusInternalServerError, response)
+		response.Errors = append(response.Errors, err.Err)
+		setRetryAfter(c, err)
+		c.JSON(brokerErrorStatus(err), response)
 		c.Abort()
 		return
 	}
 
 	found := false
-	var position RupeeSeedPositionBook
-	for _, position = range obj.Data {
+	for _, position := range positions {
 		qty := position.NetQty
 		//do not check for closed positions
 		if qty == 0 {
@@ -1102,180 +1036,155 @@ func (s *trade) ConvertPosition(c *gin.Context) {
 		return
 	}
 
-	//call ruppeeseed api for position conversion
-	convertPosReq := getRupeeseedConvertPositionRequestBody(c, request)
-	obj1, err := s.convertPosition(convertPosReq)
+	//call broker for position conversion
+	ack, err := s.broker(c).ConvertPosition(c, request)
 	if err != nil {
-		if err.ErrName == e.VendorOMSError {
+		if err.Err.ErrName == e.VendorOMSError {
 			response.Status = false
-			response.Data = obj1.Message
+			response.Data = ack.Message
 			c.JSON(http.StatusOK, response)
 			return
 		}
-		response.Errors = append(response.Errors, *err)
-		c.JSON(http.StatusInternalServerError, response)
+		response.Errors = append(response.Errors, err.Err)
+		setRetryAfter(c, err)
+		c.JSON(brokerErrorStatus(err), response)
 		c.Abort()
 		return
 	}
-	response.Data = obj1.Message
+	response.Data = ack.Message
 	response.Status = true
 	c.JSON(http.StatusOK, response)
 }
 
 /*
-fetch Position Book from Ruppeeseed
-any error from invoking Ruppeeseed API is
-converted to Error struct from ("equity-trading/pkg/errors") package
-
-	input:
-		context
-	output:
-		RuppeeseedPositionBookResponse
-		*Error
+validating business constraints for placing
+cover order through risk engine & exposure limits
 */
-func (s *trade) fetchPositionBook(c *gin.Context) (RupeeseedPositionBookResponse, *e.Error) {
-	var (
-		obj RupeeseedPositionBookResponse
-		er  e.Error
-	)
-
-	st := time.Now()
-	//creating rupeeseed api url for NetPosition
-	uri := rupeeseedObj.EndPoint + PositionBookApi
-	//creating request body for calling rupeeseed NetPosition api
-	positionRequestBody := getPositionBookRupeeseedRequestBody(c)
-	//call rupeeseed NetPosition api
-	body, status, err := s.restCaller.InvokeResty(http.MethodPost, uri, positionRequestBody, nil, 700)
-	logger.Log.Info("api details", zap.Any("latency", time.Since(st)), zap.Any("status", status), zap.Error(err), zap.Any("data", string(body)))
+func (s *trade) coverOrderValidation(c *gin.Context, request ConfirmedCoverOrderRequest) error {
+	order := coverRiskOrder(request.PlaceCoverOrderRequest)
+	order.PriceConfirmed = request.PriceConfirmed
+	if err := riskEngine.Evaluate(order, s); err != nil {
+		return err
+	}
+	return s.checkExposureLimits(c, order)
+}
 
-	if err != nil {
-		logger.Log.Error("PositionBook rupeeseed api failure", zap.Error(err), zap.String("api:", uri))
-		er = e.ErrorInfo["VendorApiFailure"].GetErrorDetails("")
-		return obj, &er
-	} else if status != http.StatusOK {
-		// Rupeeseed api unable to retreive PositionBook
-		logger.Log.Error("NetPosition: rupeeseed api failure, failed to retreive positionBook", zap.Error(err), zap.String("api:", uri))
-		er = e.ErrorInfo["VendorConnectionFailure"].GetErrorDetails("")
-		return obj, &er
-	}
-	// unmarshal rupeeseed response
-	err = json.Unmarshal(body, &obj)
-	if err != nil {
-		logger.Log.Error("Failed to marshal the error", zap.Error(err), zap.Any("recevied", string(body)))
-		er = e.ErrorInfo["JsonUnmarshalError"].GetErrorDetails("")
-		return obj, &er
-	}
-	// handling the failure of PositionBook at rupeeseed
-	if obj.Status != Success {
-		logger.Log.Error("NetPosition api failure", zap.String("msg", obj.Message), zap.String("errorCode", obj.ErrorCode))
-		if e.RupeeseedErrors[obj.ErrorCode] == http.StatusBadRequest {
-			er = e.ErrorInfo["BadRequest"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message))
-			return obj, &er
-		} else if e.RupeeseedErrors[obj.ErrorCode] == http.StatusInternalServerError {
-			er = e.ErrorInfo["InternalServerError"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message))
-			return obj, &er
-		} else {
-			er = e.ErrorInfo["InternalServerError"].GetErrorDetails("")
-			return obj, &er
-		}
+func coverRiskOrder(request PlaceCoverOrderRequest) RiskOrder {
+	return RiskOrder{
+		Action:              RiskPlace,
+		Kind:                CoverOrderKind,
+		TxnType:             request.TxnType,
+		Exchange:            request.Exchange,
+		Segment:             request.Segment,
+		Product:             request.Product,
+		OrderType:           request.OrderType,
+		Validity:            request.Validity,
+		ExchangeToken:       request.ExchangeToken,
+		Quantity:            request.Quantity,
+		Price:               request.Price,
+		TriggerPrice:        request.TriggerPrice,
+		OffMktFlag:          request.OffMktFlag,
+		OffMktOrderTimeFlag: request.OffMktOrderTimeFlag,
 	}
-	return obj, nil
 }
 
 /*
-call Ruppeeseed API to convert product type for open positions
-any error from from Ruppeeseed API is handled based on type of
-error and returned as custom error ("equity-trading/pkg/errors")
-
-	input:
-		RuppeeseedConvertPositionRequest
-	output:
-		RuppeeseedConvertPositionResponse
-		*Error
+validating business constraints for placing
+bracket order through risk engine & exposure limits
 */
-func (s *trade) convertPosition(request RuppeeseedConvertPositionRequest) (RuppeeseedConvertPositionResponse, *e.Error) {
-	var (
-		obj RuppeeseedConvertPositionResponse
-		er  e.Error
-	)
-
-	st := time.Now()
-	uri := rupeeseedObj.EndPoint + ConvertPositionApi
-	logger.Log.Info("created convert position request", zap.Any("request", request))
-	body, status, err := s.restCaller.InvokeResty(http.MethodPost, uri, request, nil, 1000)
-	logger.Log.Info("api details", zap.Any("lateny", time.Since(st)), zap.Any("status", status), zap.Error(err), zap.Any("data", string(body)))
-	if err != nil || status != http.StatusOK {
-		logger.Log.Error("convert position rupeeseed api failure", zap.Error(err), zap.String("api:", uri))
-		er = e.ErrorInfo["VendorApiFailure"].GetErrorDetails("")
-		return obj, &er
+func (s *trade) bracketOrderValidation(c *gin.Context, request ConfirmedBracketOrderRequest) error {
+	order := bracketRiskOrder(request.PlaceBracketOrderRequest)
+	order.PriceConfirmed = request.PriceConfirmed
+	if err := riskEngine.Evaluate(order, s); err != nil {
+		return err
 	}
+	return s.checkExposureLimits(c, order)
+}
 
-	err = json.Unmarshal(body, &obj)
-	if err != nil {
-		logger.Log.Error("Failed to marshal the error", zap.Error(err), zap.Any("recevied", string(body)))
-		er = e.ErrorInfo["JsonUnmarshalError"].GetErrorDetails("")
-		return obj, &er
-	}
-	if obj.Status != Success {
-		logger.Log.Error("order entry api failure", zap.String("msg", obj.Message), zap.String("errorCode", obj.ErrorCode))
-		if obj.ErrorCode == "RS-0022" {
-			er = e.ErrorInfo["VendorOMSError"].GetErrorDetails("")
-			return obj, &er
-		} else {
-			er = e.ErrorInfo["VendorApiFailure"].GetErrorDetails(fmt.Sprintf(":%s", obj.Message)) // Once error code recevied , need to handle errors
-			return obj, &er
-		}
+func bracketRiskOrder(request PlaceBracketOrderRequest) RiskOrder {
+	return RiskOrder{
+		Action:              RiskPlace,
+		Kind:                BracketOrderKind,
+		TxnType:             request.TxnType,
+		Exchange:            request.Exchange,
+		Segment:             request.Segment,
+		Product:             request.Product,
+		OrderType:           request.OrderType,
+		Validity:            request.Validity,
+		ExchangeToken:       request.ExchangeToken,
+		Quantity:            request.Quantity,
+		Price:               request.Price,
+		ProfitValue:         request.ProfitValue,
+		StoplossValue:       request.StoplossValue,
+		OffMktFlag:          request.OffMktFlag,
+		OffMktOrderTimeFlag: request.OffMktOrderTimeFlag,
 	}
-	return obj, nil
-
 }
 
 /*
-validating business constraints for placing
-cover order
+validating business constraints for modifying normal, bracket &
+cover order against the order in OrderBook, risk engine checks the
+order merged with the requested changes
 */
-func coverOrderValidation(request PlaceCoverOrderRequest) error {
-	//limit trigger order validation
-
-	if request.OrderType == LMT && request.Price <= 0.0 {
-		return errors.New(":Price cannot be zero with limit order")
+func (s *trade) modifyOrderValidation(c *gin.Context, kind string, request ConfirmedModifyOrderRequest) error {
+	current, err := s.currentOrder(c, request.OrderNo)
+	if err != nil {
+		return err
 	}
-
-	if request.TriggerPrice <= 0.0 {
-		return errors.New(":Trigger Price cannot be zero")
+	if err := checkOrderModification(current, request.ModifyOrderRequest); err != nil {
+		return err
 	}
+	order := modifyRiskOrder(kind, mergeOrderModification(current, request.ModifyOrderRequest))
+	order.PriceConfirmed = request.PriceConfirmed
+	return riskEngine.Evaluate(order, s)
+}
 
-	if request.OffMktFlag && request.OffMktOrderTimeFlag <= 0 {
-		return errors.New(":OffMktOrderTimeFlag possible allowed values are 1|2|3 in AMO")
+func modifyRiskOrder(kind string, request ModifyOrderRequest) RiskOrder {
+	return RiskOrder{
+		Action:        RiskModify,
+		Kind:          kind,
+		TxnType:       request.TxnType,
+		Exchange:      request.Exchange,
+		Segment:       request.Segment,
+		Product:       request.Product,
+		OrderType:     request.OrderType,
+		Validity:      request.Validity,
+		ExchangeToken: request.ExchangeToken,
+		Quantity:      request.Qty,
+		Price:         request.Price,
+		TriggerPrice:  request.TriggerPrice,
+		OffMktFlag:    request.OffMktFlag,
 	}
-
-	return nil
 }
 
 /*
-validating business constraints for placing
+validating business constraints for exiting
 bracket order
 */
-func bracketOrderValidation(request PlaceBracketOrderRequest) error {
-	//limit trigger order validation
-
-	if request.OrderType == LMT && request.Price <= 0.0 {
-		return errors.New(":Price cannot be zero with limit order")
-	}
-
-	if request.ProfitValue <= 0.0 && request.StoplossValue <= 0.0 {
-		return errors.New(":ProfitValue, StoplossValue cannot be zero")
+func bracketExitValidation(request CancelOrderRequest) error {
+	if request.Product != BoProductValue {
+		return fieldError("product", "oneof", "Product must be B for bracket order exit", "allowed", BoProductValue)
 	}
-
-	if request.ProfitValue <= 0.0 {
-		return errors.New(":ProfitValue cannot be zero")
+	if request.LegNo <= 0 {
+		return fieldError("leg_no", "required", "LegNo cannot be zero for bracket order exit")
 	}
-	if request.StoplossValue <= 0.0 {
-		return errors.New(":StoplossValue cannot be zero")
+	if len(strings.TrimSpace(request.AlgoOrderNo)) == 0 {
+		return fieldError("algo_order_no", "required", "AlgoOrderNo cannot be empty for bracket order exit")
 	}
 
-	if request.OffMktFlag && request.OffMktOrderTimeFlag <= 0 {
-		return errors.New(":OffMktOrderTimeFlag possible allowed values are 1|2|3 in AMO")
+	return nil
+}
+
+/*
+validating business constraints for exiting
+cover order
+*/
+func coverExitValidation(request CancelOrderRequest) error {
+	if request.Product != CoProductValue {
+		return fieldError("product", "oneof", "Product must be V for cover order exit", "allowed", CoProductValue)
+	}
+	if request.LegNo <= 0 {
+		return fieldError("leg_no", "required", "LegNo cannot be zero for cover order exit")
 	}
 
 	return nil
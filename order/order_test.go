@@ -5,7 +5,12 @@ import (
 	"encoding/json"
 	config "equity-trading/pkg/config"
 	db "equity-trading/pkg/db"
+	"equity-trading/pkg/db/gtt"
 	dbmock "equity-trading/pkg/db/mock"
+	"equity-trading/pkg/db/paper"
+	"equity-trading/pkg/db/scrip"
+	"equity-trading/pkg/db/squareoff"
+	e "equity-trading/pkg/errors"
 	"equity-trading/pkg/logger"
 	"equity-trading/pkg/utils"
 	mock "equity-trading/pkg/utils/mock"
@@ -14,9 +19,15 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 	"github.com/golang/mock/gomock"
 	"go.uber.org/zap"
 )
@@ -25,6 +36,14 @@ type OrderData struct {
 	Order string `json:"order_no"`
 }
 
+// handlers are tested within equity session of a trading day unless a test sets its own clock
+func TestMain(m *testing.M) {
+	marketCalendar.now = func() time.Time { return time.Date(2024, time.March, 12, 10, 30, 0, 0, istLocation) }
+	// circuits of every endpoint start closed
+	circuitBreakers.breakers = make(map[string]*circuitBreaker)
+	os.Exit(m.Run())
+}
+
 func getConntext(method string, data interface{}) *gin.Context {
 	recorder := httptest.NewRecorder()
 	temp, _ := gin.CreateTestContext(recorder)
@@ -78,8 +97,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 			},
 			output:  PlaceOrderResponse{},
 			wantErr: true,
@@ -101,8 +122,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 			},
 			output:  PlaceOrderResponse{},
 			wantErr: true,
@@ -124,8 +147,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 			},
 			output:  PlaceOrderResponse{},
 			wantErr: true,
@@ -147,8 +172,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 			},
 			output:  PlaceOrderResponse{},
 			wantErr: true,
@@ -170,8 +197,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 			},
 			output:  PlaceOrderResponse{},
 			wantErr: true,
@@ -193,8 +222,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 			},
 			output:  PlaceOrderResponse{},
 			wantErr: true,
@@ -218,8 +249,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 			},
 			output:  PlaceOrderResponse{},
 			wantErr: true,
@@ -243,8 +276,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 			},
 			output:  PlaceOrderResponse{},
 			wantErr: true,
@@ -268,8 +303,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 			},
 			output:  PlaceOrderResponse{},
 			wantErr: true,
@@ -291,8 +328,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 			},
 			output:  PlaceOrderResponse{},
 			wantErr: true,
@@ -314,8 +353,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 				req := getRupeeseedOrderRequestBody(c, data)
 				resp := RupeeseedNormalOrderResponse{
 					Status:  "success",
@@ -352,8 +393,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 				req := getRupeeseedOrderRequestBody(c, data)
 				resp := RupeeseedNormalOrderResponse{
 					Status:  "error",
@@ -390,8 +433,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 				req := getRupeeseedOrderRequestBody(c, data)
 				resp := RupeeseedNormalOrderResponse{
 					Status:  "error",
@@ -428,8 +473,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 				req := getRupeeseedOrderRequestBody(c, data)
 				resp := RupeeseedNormalOrderResponse{
 					Status:  "error",
@@ -466,8 +513,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 				req := getRupeeseedOrderRequestBody(c, data)
 				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
 				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(nil, 0, errors.New("ApiFormatError")).Times(1)
@@ -492,8 +541,10 @@ func TestPlaceOrder(t *testing.T) {
 				invoker := mock.NewMockUtils(ctrl)
 				repo := dbmock.NewMockDBLayer(ctrl)
 				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
 				redisCaller = invoker
 				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
 				req := getRupeeseedOrderRequestBody(c, data)
 				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
 				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return([]byte("SORRY"), 0, errors.New("ApiFormatError")).Times(1)
@@ -522,3 +573,3251 @@ func TestPlaceOrder(t *testing.T) {
 	}
 
 }
+
+func TestCancelOrder(t *testing.T) {
+	var (
+		dbObj       db.DBLayer
+		restCaller  utils.RestCaller
+		redisCaller utils.RedisInterface
+	)
+
+	tests := []struct {
+		name       string
+		input      CancelOrderRequest
+		setup      func(*gin.Context, CancelOrderRequest)
+		output     Response
+		httpStatus bool
+		wantErr    bool
+		ctx        *gin.Context
+	}{
+		{
+			name: "InvalidOrderNo",
+			input: CancelOrderRequest{
+				SerialNo: 1,
+				GroupId:  1,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "C",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "OrderCancelled",
+			input: CancelOrderRequest{
+				OrderNo:  "112211242008",
+				SerialNo: 1,
+				GroupId:  1,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "C",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedCancelOrderRequestBody(c, data)
+				resp := RupeeseedNormalOrderResponse{
+					Status:  "success",
+					ErrCode: "",
+					Message: "ordercancelled",
+				}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + CancelOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+			},
+			output:  Response{},
+			wantErr: false,
+		},
+		{
+			name: "VendorRejected",
+			input: CancelOrderRequest{
+				OrderNo:  "112211242008",
+				SerialNo: 1,
+				GroupId:  1,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "C",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedCancelOrderRequestBody(c, data)
+				resp := RupeeseedNormalOrderResponse{
+					Status:  "error",
+					ErrCode: "RS-0023",
+					Message: "ORDER ALREADY TRADED",
+				}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + CancelOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "VendorApifailure-InternalServerError",
+			input: CancelOrderRequest{
+				OrderNo:  "112211242008",
+				SerialNo: 1,
+				GroupId:  1,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "C",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedCancelOrderRequestBody(c, data)
+				resp := RupeeseedNormalOrderResponse{
+					Status:  "error",
+					ErrCode: "RS-0023",
+					Message: "ORDER ALREADY TRADED",
+				}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + CancelOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusInternalServerError, nil).Times(1)
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "ApiCallingFailure",
+			input: CancelOrderRequest{
+				OrderNo:  "112211242008",
+				SerialNo: 1,
+				GroupId:  1,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "C",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedCancelOrderRequestBody(c, data)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + CancelOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(nil, 0, errors.New("ApiFormatError")).Times(1)
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.ctx = getConntext("POST", test.input)
+			test.setup(test.ctx, test.input)
+			servObj := NewTradeGroup(dbObj, restCaller, redisCaller)
+			servObj.CancelOrder(test.ctx)
+			if test.wantErr != test.ctx.IsAborted() {
+				t.Errorf("TestCancelOrder() failed testcase=[%s] want contextaborted [%v], got  [%v]", test.name, test.wantErr, test.ctx.IsAborted())
+				return
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+}
+
+func TestBracketOrderExit(t *testing.T) {
+	var (
+		dbObj       db.DBLayer
+		restCaller  utils.RestCaller
+		redisCaller utils.RedisInterface
+	)
+
+	tests := []struct {
+		name       string
+		input      CancelOrderRequest
+		setup      func(*gin.Context, CancelOrderRequest)
+		output     Response
+		httpStatus bool
+		wantErr    bool
+		ctx        *gin.Context
+	}{
+		{
+			name: "InvalidOrderNo",
+			input: CancelOrderRequest{
+				SerialNo:    1,
+				GroupId:     1,
+				LegNo:       2,
+				AlgoOrderNo: "5001",
+				TxnType:     "B",
+				Exchange:    "NSE",
+				Segment:     "E",
+				Product:     "B",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "InvalidProductType",
+			input: CancelOrderRequest{
+				OrderNo:     "112211242008",
+				SerialNo:    1,
+				GroupId:     1,
+				LegNo:       2,
+				AlgoOrderNo: "5001",
+				TxnType:     "B",
+				Exchange:    "NSE",
+				Segment:     "E",
+				Product:     "V",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "InvalidAlgoOrderNo",
+			input: CancelOrderRequest{
+				OrderNo:  "112211242008",
+				SerialNo: 1,
+				GroupId:  1,
+				LegNo:    2,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "B",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "OrderExited",
+			input: CancelOrderRequest{
+				OrderNo:     "112211242008",
+				SerialNo:    1,
+				GroupId:     1,
+				LegNo:       2,
+				AlgoOrderNo: "5001",
+				TxnType:     "B",
+				Exchange:    "NSE",
+				Segment:     "E",
+				Product:     "B",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedBracketExitRequestBody(c, data)
+				resp := RupeeseedNormalOrderResponse{
+					Status:  "success",
+					ErrCode: "",
+					Message: "orderexited",
+				}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + BoExitOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+			},
+			output:  Response{},
+			wantErr: false,
+		},
+		{
+			name: "VendorRejected",
+			input: CancelOrderRequest{
+				OrderNo:     "112211242008",
+				SerialNo:    1,
+				GroupId:     1,
+				LegNo:       2,
+				AlgoOrderNo: "5001",
+				TxnType:     "B",
+				Exchange:    "NSE",
+				Segment:     "E",
+				Product:     "B",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedBracketExitRequestBody(c, data)
+				resp := RupeeseedNormalOrderResponse{
+					Status:  "error",
+					ErrCode: "RS-0023",
+					Message: "ORDER ALREADY TRADED",
+				}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + BoExitOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "VendorApifailure-InternalServerError",
+			input: CancelOrderRequest{
+				OrderNo:     "112211242008",
+				SerialNo:    1,
+				GroupId:     1,
+				LegNo:       2,
+				AlgoOrderNo: "5001",
+				TxnType:     "B",
+				Exchange:    "NSE",
+				Segment:     "E",
+				Product:     "B",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedBracketExitRequestBody(c, data)
+				resp := RupeeseedNormalOrderResponse{
+					Status:  "error",
+					ErrCode: "RS-0023",
+					Message: "ORDER ALREADY TRADED",
+				}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + BoExitOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusInternalServerError, nil).Times(1)
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "ApiCallingFailure",
+			input: CancelOrderRequest{
+				OrderNo:     "112211242008",
+				SerialNo:    1,
+				GroupId:     1,
+				LegNo:       2,
+				AlgoOrderNo: "5001",
+				TxnType:     "B",
+				Exchange:    "NSE",
+				Segment:     "E",
+				Product:     "B",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedBracketExitRequestBody(c, data)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + BoExitOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(nil, 0, errors.New("ApiFormatError")).Times(1)
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.ctx = getConntext("POST", test.input)
+			test.setup(test.ctx, test.input)
+			servObj := NewTradeGroup(dbObj, restCaller, redisCaller)
+			servObj.BracketOrderExit(test.ctx)
+			if test.wantErr != test.ctx.IsAborted() {
+				t.Errorf("TestBracketOrderExit() failed testcase=[%s] want contextaborted [%v], got  [%v]", test.name, test.wantErr, test.ctx.IsAborted())
+				return
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+}
+
+func TestCoverOrderExit(t *testing.T) {
+	var (
+		dbObj       db.DBLayer
+		restCaller  utils.RestCaller
+		redisCaller utils.RedisInterface
+	)
+
+	tests := []struct {
+		name       string
+		input      CancelOrderRequest
+		setup      func(*gin.Context, CancelOrderRequest)
+		output     Response
+		httpStatus bool
+		wantErr    bool
+		ctx        *gin.Context
+	}{
+		{
+			name: "InvalidOrderNo",
+			input: CancelOrderRequest{
+				SerialNo: 1,
+				GroupId:  1,
+				LegNo:    2,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "V",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "InvalidProductType",
+			input: CancelOrderRequest{
+				OrderNo:  "112211242008",
+				SerialNo: 1,
+				GroupId:  1,
+				LegNo:    2,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "B",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "InvalidLegNo",
+			input: CancelOrderRequest{
+				OrderNo:  "112211242008",
+				SerialNo: 1,
+				GroupId:  1,
+				LegNo:    0,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "V",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "OrderExited",
+			input: CancelOrderRequest{
+				OrderNo:  "112211242008",
+				SerialNo: 1,
+				GroupId:  1,
+				LegNo:    2,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "V",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedCoverExitRequestBody(c, data)
+				resp := RupeeseedNormalOrderResponse{
+					Status:  "success",
+					ErrCode: "",
+					Message: "orderexited",
+				}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + CoExitOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+			},
+			output:  Response{},
+			wantErr: false,
+		},
+		{
+			name: "VendorRejected",
+			input: CancelOrderRequest{
+				OrderNo:  "112211242008",
+				SerialNo: 1,
+				GroupId:  1,
+				LegNo:    2,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "V",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedCoverExitRequestBody(c, data)
+				resp := RupeeseedNormalOrderResponse{
+					Status:  "error",
+					ErrCode: "RS-0023",
+					Message: "ORDER ALREADY TRADED",
+				}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + CoExitOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "VendorApifailure-InternalServerError",
+			input: CancelOrderRequest{
+				OrderNo:  "112211242008",
+				SerialNo: 1,
+				GroupId:  1,
+				LegNo:    2,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "V",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedCoverExitRequestBody(c, data)
+				resp := RupeeseedNormalOrderResponse{
+					Status:  "error",
+					ErrCode: "RS-0023",
+					Message: "ORDER ALREADY TRADED",
+				}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + CoExitOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusInternalServerError, nil).Times(1)
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+		{
+			name: "ApiCallingFailure",
+			input: CancelOrderRequest{
+				OrderNo:  "112211242008",
+				SerialNo: 1,
+				GroupId:  1,
+				LegNo:    2,
+				TxnType:  "B",
+				Exchange: "NSE",
+				Segment:  "E",
+				Product:  "V",
+			},
+			setup: func(c *gin.Context, data CancelOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getRupeeseedCoverExitRequestBody(c, data)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + CoExitOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(nil, 0, errors.New("ApiFormatError")).Times(1)
+			},
+			output:  Response{},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.ctx = getConntext("POST", test.input)
+			test.setup(test.ctx, test.input)
+			servObj := NewTradeGroup(dbObj, restCaller, redisCaller)
+			servObj.CoverOrderExit(test.ctx)
+			if test.wantErr != test.ctx.IsAborted() {
+				t.Errorf("TestCoverOrderExit() failed testcase=[%s] want contextaborted [%v], got  [%v]", test.name, test.wantErr, test.ctx.IsAborted())
+				return
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+}
+
+func TestParseVendorRequestBody(t *testing.T) {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	modify := ModifyOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", ExchangeToken: 3045, Qty: 10, Price: 100, Validity: "DAY", OrderType: "LMT", OrderNo: "1001", GroupId: 1, SerialNo: 1, LegNo: 2, AlgoOrderNo: "A1"}
+	tests := []struct {
+		name            string
+		product         string
+		wantLegNo       string
+		wantAlgoOrderNo string
+	}{
+		{name: "NormalOrder", product: "I"},
+		{name: "CoverOrder", product: CoProductValue, wantLegNo: "2.000000"},
+		{name: "BracketOrder", product: BoProductValue, wantLegNo: "2", wantAlgoOrderNo: "A1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := modify
+			req.Product = tt.product
+			body := parseVendorRequestBody(ctx, req)
+			if body.Data.LegNo != tt.wantLegNo || body.Data.AlgoOrderNo != tt.wantAlgoOrderNo {
+				t.Errorf("parseVendorRequestBody() LegNo = %q AlgoOrderNo = %q, want %q %q", body.Data.LegNo, body.Data.AlgoOrderNo, tt.wantLegNo, tt.wantAlgoOrderNo)
+			}
+		})
+	}
+}
+
+func TestTradeBook(t *testing.T) {
+	var (
+		dbObj       db.DBLayer
+		restCaller  utils.RestCaller
+		redisCaller utils.RedisInterface
+	)
+
+	fills := []RupeeseedTradeBook{
+		{
+			OrderNo:       "112211242008",
+			ExchOrderNo:   "1100000012345678",
+			ExchTradeID:   "50001",
+			TradedQty:     5,
+			TradedPrice:   2450.5,
+			TradeDateTime: "2022-11-24 10:15:02",
+			Symbol:        "RELIANCE",
+			Exchange:      "NSE",
+			Segment:       "E",
+			SecurityID:    "2885",
+			TxnType:       "B",
+			Product:       "C",
+		},
+		{
+			OrderNo:       "112211242008",
+			ExchOrderNo:   "1100000012345678",
+			ExchTradeID:   "50002",
+			TradedQty:     5,
+			TradedPrice:   2451.0,
+			TradeDateTime: "2022-11-24 10:15:03",
+			Symbol:        "RELIANCE",
+			Exchange:      "NSE",
+			Segment:       "E",
+			SecurityID:    "2885",
+			TxnType:       "B",
+			Product:       "C",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		setup   func(*gin.Context)
+		wantErr bool
+		ctx     *gin.Context
+	}{
+		{
+			name:  "AllFills",
+			query: "",
+			setup: func(c *gin.Context) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getTradeBookRupeeseedRequestBody(c)
+				resp := RupeeseedTradeBookResponse{Status: "success", Data: fills}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + TradeBookApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+			},
+			wantErr: false,
+		},
+		{
+			name:  "FilterBySegmentNoMatch",
+			query: "segment=D",
+			setup: func(c *gin.Context) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getTradeBookRupeeseedRequestBody(c)
+				resp := RupeeseedTradeBookResponse{Status: "success", Data: fills}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + TradeBookApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "VendorRejected",
+			query: "",
+			setup: func(c *gin.Context) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getTradeBookRupeeseedRequestBody(c)
+				resp := RupeeseedTradeBookResponse{Status: "error", ErrorCode: "RS-0023", Message: "NO DATA"}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + TradeBookApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "ApiCallingFailure",
+			query: "",
+			setup: func(c *gin.Context) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getTradeBookRupeeseedRequestBody(c)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + TradeBookApi
+				// read calls are retried
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(nil, 0, errors.New("ApiFormatError")).Times(readRetryPolicy.attempts)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.ctx = getConntext("GET", nil)
+			test.ctx.Request.URL = &url.URL{RawQuery: test.query}
+			test.setup(test.ctx)
+			servObj := NewTradeGroup(dbObj, restCaller, redisCaller)
+			servObj.TradeBook(test.ctx)
+			if test.wantErr != test.ctx.IsAborted() {
+				t.Errorf("TestTradeBook() failed testcase=[%s] want contextaborted [%v], got  [%v]", test.name, test.wantErr, test.ctx.IsAborted())
+				return
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+}
+
+func TestOrderHistory(t *testing.T) {
+	var (
+		dbObj       db.DBLayer
+		restCaller  utils.RestCaller
+		redisCaller utils.RedisInterface
+	)
+
+	history := []RupeeseedOrderHistory{
+		{OrderNo: "112211242008", SerialNo: 3, Status: "Part-traded", Quantity: 20, TradedQty: 5, RemainingQuantity: 15, Price: 101},
+		{OrderNo: "112211242008", SerialNo: 1, Status: "Transit", Quantity: 10, Price: 100},
+		{OrderNo: "112211242008", SerialNo: 2, Status: "Modified", Quantity: 20, RemainingQuantity: 20, Price: 101},
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		setup   func(*gin.Context)
+		wantErr bool
+		ctx     *gin.Context
+	}{
+		{
+			name:  "MissingOrderNo",
+			query: "",
+			setup: func(c *gin.Context) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+			},
+			wantErr: true,
+		},
+		{
+			name:  "OrderTimeline",
+			query: "orderNo=112211242008",
+			setup: func(c *gin.Context) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getOrderHistoryRupeeseedRequestBody(c, "112211242008")
+				resp := RupeeseedOrderHistoryResponse{Status: "success", Data: history}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderHistoryApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+			},
+			wantErr: false,
+		},
+		{
+			name:  "OrderNotFound",
+			query: "orderNo=112211242009",
+			setup: func(c *gin.Context) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getOrderHistoryRupeeseedRequestBody(c, "112211242009")
+				resp := RupeeseedOrderHistoryResponse{Status: "success"}
+				byteData, _ := json.Marshal(resp)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderHistoryApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "VendorApifailure-InternalServerError",
+			query: "orderNo=112211242008",
+			setup: func(c *gin.Context) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				req := getOrderHistoryRupeeseedRequestBody(c, "112211242008")
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderHistoryApi
+				// read calls are retried
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(nil, http.StatusInternalServerError, nil).Times(readRetryPolicy.attempts)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.ctx = getConntext("GET", nil)
+			test.ctx.Request.URL = &url.URL{RawQuery: test.query}
+			test.setup(test.ctx)
+			servObj := NewTradeGroup(dbObj, restCaller, redisCaller)
+			servObj.OrderHistory(test.ctx)
+			if test.wantErr != test.ctx.IsAborted() {
+				t.Errorf("TestOrderHistory() failed testcase=[%s] want contextaborted [%v], got  [%v]", test.name, test.wantErr, test.ctx.IsAborted())
+				return
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+
+	events := make([]BrokerOrderEvent, 0, len(history))
+	for _, h := range history {
+		events = append(events, BrokerOrderEvent(h))
+	}
+	timeline := buildOrderTimeline("112211242008", events)
+	if len(timeline.Steps) != 3 || timeline.Steps[0].Status != "Transit" {
+		t.Errorf("buildOrderTimeline() steps not ordered by serial no, got [%v]", timeline.Steps)
+	} else if timeline.Steps[1].QuantityChange != 10 || timeline.Steps[2].TradedQtyChange != 5 {
+		t.Errorf("buildOrderTimeline() wrong quantity changes, got [%v]", timeline.Steps)
+	}
+}
+
+func TestOrderStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		riseStatus string
+		section    string
+		wantErr    bool
+	}{
+		{name: "Transit", input: "Transit", riseStatus: Pending, section: Open},
+		{name: "Modified", input: "Modified", riseStatus: Pending, section: Open},
+		{name: "PartTradedCaseInsensitive", input: "part-traded", riseStatus: PartExecuted, section: Open},
+		{name: "Traded", input: "Traded", riseStatus: Executed, section: Executed},
+		{name: "Rejected", input: "Rejected", riseStatus: Rejected, section: Executed},
+		{name: "Cancelled", input: " Cancelled ", riseStatus: Cancelled, section: Executed},
+		{name: "UnknownStatus", input: "Frozen", riseStatus: UnknownStatus, section: UnknownStatus, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, err := ParseOrderStatus(test.input)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ParseOrderStatus() failed testcase=[%s] want error [%v], got [%v]", test.name, test.wantErr, err)
+				return
+			}
+			if status.RiseStatus() != test.riseStatus || status.Section() != test.section {
+				t.Errorf("ParseOrderStatus() failed testcase=[%s] want [%s|%s], got [%s|%s]", test.name, test.riseStatus, test.section, status.RiseStatus(), status.Section())
+			}
+		})
+	}
+
+	transitions := []struct {
+		from, to OrderStatus
+		allowed  bool
+	}{
+		{Transit, Pending, true},
+		{Pending, Modified, true},
+		{PartTraded, Traded, true},
+		{Traded, Cancelled, false},
+		{Cancelled, Pending, false},
+		{PartTraded, Pending, false},
+	}
+	for _, tr := range transitions {
+		if tr.from.CanTransitionTo(tr.to) != tr.allowed {
+			t.Errorf("CanTransitionTo() from [%s] to [%s] want [%v]", tr.from, tr.to, tr.allowed)
+		}
+	}
+}
+
+func TestPlaceOrderIdempotency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	// redis shared by every instance, ttl is recorded to check in flight reservations lapse early
+	store := make(map[string]string)
+	ttls := make(map[string]time.Duration)
+	invoker.EXPECT().Get(gomock.Any()).DoAndReturn(func(key string) (string, error) {
+		return store[key], nil
+	}).AnyTimes()
+	invoker.EXPECT().SetNX(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(key string, value interface{}, ttl time.Duration) (bool, error) {
+		if _, ok := store[key]; ok {
+			return false, nil
+		}
+		store[key], ttls[key] = value.(string), ttl
+		return true, nil
+	}).AnyTimes()
+	invoker.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(key string, value interface{}, ttl time.Duration) error {
+		store[key], ttls[key] = value.(string), ttl
+		return nil
+	}).AnyTimes()
+	repo := dbmock.NewMockDBLayer(ctrl)
+	repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+	servObj := NewTradeGroup(repo, invoker, invoker)
+
+	input := PlaceOrderRequest{
+		TxnType:       "B",
+		Exchange:      "NSE",
+		Segment:       "E",
+		Product:       "C",
+		ExchangeToken: 1594,
+		Quantity:      1,
+		Validity:      "DAY",
+		OrderType:     "MKT",
+	}
+	resp := RupeeseedNormalOrderResponse{
+		Status:  "success",
+		Message: "Order submitted successfully. Your Order Ref No. 112211242010",
+		Data:    []OrderData{{Order: "112211242010"}},
+	}
+	byteData, _ := json.Marshal(resp)
+	uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+	// rupeeseed must be called only once for the same key
+	invoker.EXPECT().InvokeHttp(http.MethodPost, uri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(byteData, http.StatusOK, nil).Times(1)
+
+	tests := []struct {
+		name       string
+		input      PlaceOrderRequest
+		key        string
+		afterClose bool
+		httpStatus int
+	}{
+		{name: "FirstSubmission", input: input, key: "retry-key-1", httpStatus: http.StatusOK},
+		{name: "RetriedSubmission", input: input, key: "retry-key-1", httpStatus: http.StatusOK},
+		// replayed before the trading session is checked again
+		{name: "RetriedAfterMarketClose", input: input, key: "retry-key-1", afterClose: true, httpStatus: http.StatusOK},
+		{name: "KeyReusedWithDifferentPayload", input: PlaceOrderRequest{
+			TxnType:       "S",
+			Exchange:      "NSE",
+			Segment:       "E",
+			Product:       "C",
+			ExchangeToken: 1594,
+			Quantity:      1,
+			Validity:      "DAY",
+			OrderType:     "MKT",
+		}, key: "retry-key-1", httpStatus: http.StatusConflict},
+		// rejected before the broker is called, the key stays free for the corrected request
+		{name: "ValidationFailureNotRemembered", input: PlaceOrderRequest{
+			TxnType:       "B",
+			Exchange:      "NSE",
+			Segment:       "E",
+			Product:       "C",
+			ExchangeToken: 1594,
+			Quantity:      1,
+			Validity:      "DAY",
+			OrderType:     "LMT",
+		}, key: "retry-key-2", httpStatus: http.StatusBadRequest},
+	}
+
+	var firstBody string
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.afterClose {
+				defer func(now func() time.Time) { marketCalendar.now = now }(marketCalendar.now)
+				marketCalendar.now = func() time.Time { return time.Date(2024, time.March, 12, 20, 0, 0, 0, istLocation) }
+			}
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			byteData, _ := json.Marshal(test.input)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(byteData))
+			ctx.Request.Header.Set("Content-Type", "application/json")
+			ctx.Request.Header.Set(IdempotencyKeyHeader, test.key)
+			servObj.PlaceOrder(ctx)
+			if recorder.Code != test.httpStatus {
+				t.Errorf("TestPlaceOrderIdempotency() failed testcase=[%s] want status [%d], got [%d]", test.name, test.httpStatus, recorder.Code)
+				return
+			}
+			if test.httpStatus == http.StatusOK {
+				if len(firstBody) == 0 {
+					firstBody = recorder.Body.String()
+				} else if recorder.Body.String() != firstBody {
+					t.Errorf("TestPlaceOrderIdempotency() failed testcase=[%s] want original response [%s], got [%s]", test.name, firstBody, recorder.Body.String())
+					return
+				}
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+	if ttl := ttls[IdempotencyKeyPrefix+":"+OrderApi+":retry-key-1"]; ttl != IdempotencyKeyTTL {
+		t.Errorf("TestPlaceOrderIdempotency() want completed key kept for [%v], got [%v]", IdempotencyKeyTTL, ttl)
+	}
+	if _, ok := store[IdempotencyKeyPrefix+":"+OrderApi+":retry-key-2"]; ok {
+		t.Errorf("TestPlaceOrderIdempotency() want key of request rejected before the broker call not remembered")
+	}
+}
+
+func TestPlaceOrderIdempotencyLapsedReservation(t *testing.T) {
+	input := PlaceOrderRequest{
+		TxnType:       "B",
+		Exchange:      "NSE",
+		Segment:       "D",
+		Product:       "M",
+		ExchangeToken: 43210,
+		Quantity:      3600,
+		Validity:      "DAY",
+		OrderType:     "MKT",
+	}
+	resp := RupeeseedNormalOrderResponse{
+		Status:  "success",
+		Message: "Order submitted successfully. Your Order Ref No. 112211242010",
+		Data:    []OrderData{{Order: "112211242010"}},
+	}
+	placed, _ := json.Marshal(resp)
+	key := IdempotencyKeyPrefix + ":" + OrderApi + ":lapse-key"
+	taken, _ := json.Marshal(IdempotentRecord{Fingerprint: "other-request", InFlight: true})
+
+	tests := []struct {
+		name string
+		// what happens to the reservation while the children are sent to rupeeseed
+		lapse      func(store map[string]string)
+		wantStored bool
+	}{
+		{name: "OutcomeStoredAfterLapse", lapse: func(store map[string]string) { delete(store, key) }, wantStored: true},
+		{name: "KeyTakenByDifferentRequest", lapse: func(store map[string]string) { store[key] = string(taken) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			invoker := mock.NewMockUtils(ctrl)
+			store := make(map[string]string)
+			var reservedFor time.Duration
+			invoker.EXPECT().Get(gomock.Any()).DoAndReturn(func(key string) (string, error) {
+				return store[key], nil
+			}).AnyTimes()
+			invoker.EXPECT().SetNX(key, gomock.Any(), gomock.Any()).DoAndReturn(func(key string, value interface{}, ttl time.Duration) (bool, error) {
+				store[key], reservedFor = value.(string), ttl
+				return true, nil
+			}).Times(1)
+			invoker.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(key string, value interface{}, ttl time.Duration) error {
+				store[key] = value.(string)
+				return nil
+			}).AnyTimes()
+			repo := dbmock.NewMockDBLayer(ctrl)
+			repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 50, TickSize: 0.05, FreezeQty: 1800}}, 1, nil).AnyTimes()
+			servObj := NewTradeGroup(repo, invoker, invoker)
+
+			uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+			invoker.EXPECT().InvokeHttp(http.MethodPost, uri, gomock.Any(), rupeeseedHeaders, ApiTimeout).DoAndReturn(
+				func(method, uri string, body interface{}, headers map[string]string, timeout int) ([]byte, int, error) {
+					test.lapse(store)
+					return placed, http.StatusOK, nil
+				}).Times(2)
+
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			byteData, _ := json.Marshal(input)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(byteData))
+			ctx.Request.Header.Set("Content-Type", "application/json")
+			ctx.Request.Header.Set(IdempotencyKeyHeader, "lapse-key")
+			servObj.PlaceOrder(ctx)
+			if recorder.Code != http.StatusOK {
+				t.Errorf("TestPlaceOrderIdempotencyLapsedReservation() failed testcase=[%s] want status [%d], got [%d]", test.name, http.StatusOK, recorder.Code)
+				return
+			}
+			// both children may run into timeout before the reservation lapses
+			if want := IdempotencyInFlightTTL + 2*time.Duration(ApiTimeout)*time.Millisecond; reservedFor != want {
+				t.Errorf("TestPlaceOrderIdempotencyLapsedReservation() failed testcase=[%s] want reservation kept for [%v], got [%v]", test.name, want, reservedFor)
+			}
+			var rec IdempotentRecord
+			if err := json.Unmarshal([]byte(store[key]), &rec); err != nil {
+				t.Errorf("TestPlaceOrderIdempotencyLapsedReservation() failed testcase=[%s] err=%v", test.name, err)
+				return
+			}
+			if stored := !rec.InFlight && rec.Status == http.StatusOK && rec.Fingerprint != "other-request"; stored != test.wantStored {
+				t.Errorf("TestPlaceOrderIdempotencyLapsedReservation() failed testcase=[%s] want outcome stored [%v], got record %+v", test.name, test.wantStored, rec)
+				return
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+}
+
+func TestPlaceBasketOrder(t *testing.T) {
+	var (
+		dbObj       db.DBLayer
+		restCaller  utils.RestCaller
+		redisCaller utils.RedisInterface
+	)
+
+	buyLeg := PlaceOrderRequest{
+		TxnType:       "B",
+		Exchange:      "NSE",
+		Segment:       "D",
+		Product:       "M",
+		ExchangeToken: 43210,
+		Quantity:      50,
+		Validity:      "DAY",
+		OrderType:     "MKT",
+	}
+	sellLeg := buyLeg
+	sellLeg.TxnType = "S"
+	sellLeg.ExchangeToken = 43211
+	invalidLeg := sellLeg
+	invalidLeg.OrderType = "LMT"
+
+	placed := func(orderNo string) []byte {
+		byteData, _ := json.Marshal(RupeeseedNormalOrderResponse{
+			Status:  "success",
+			Message: "Order submitted successfully. Your Order Ref No. " + orderNo,
+			Data:    []OrderData{{Order: orderNo}},
+		})
+		return byteData
+	}
+	rejected, _ := json.Marshal(RupeeseedNormalOrderResponse{Status: "error", ErrCode: "RS-0023", Message: "SCRIP IS BLOCKED"})
+
+	tests := []struct {
+		name     string
+		input    BasketOrderRequest
+		setup    func(*gin.Context, BasketOrderRequest)
+		wantErr  bool
+		wantPass bool
+		// placed leg reported as not rolled back
+		wantLegError bool
+	}{
+		{
+			name:  "InvalidLeg",
+			input: BasketOrderRequest{Legs: []PlaceOrderRequest{buyLeg, invalidLeg}},
+			setup: func(c *gin.Context, data BasketOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				redisCaller = invoker
+				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+			},
+			wantErr: true,
+		},
+		{
+			name:  "AllLegsPlaced",
+			input: BasketOrderRequest{Legs: []PlaceOrderRequest{buyLeg, sellLeg}},
+			setup: func(c *gin.Context, data BasketOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				redisCaller = invoker
+				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, buyLeg), rupeeseedHeaders, ApiTimeout).Return(placed("112211242011"), http.StatusOK, nil).Times(1)
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, sellLeg), rupeeseedHeaders, ApiTimeout).Return(placed("112211242012"), http.StatusOK, nil).Times(1)
+			},
+			wantErr:  false,
+			wantPass: true,
+		},
+		{
+			name:  "LegFailedCancelOnFailure",
+			input: BasketOrderRequest{Legs: []PlaceOrderRequest{buyLeg, sellLeg}, CancelOnFailure: true},
+			setup: func(c *gin.Context, data BasketOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				redisCaller = invoker
+				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, buyLeg), rupeeseedHeaders, ApiTimeout).Return(placed("112211242011"), http.StatusOK, nil).Times(1)
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, sellLeg), rupeeseedHeaders, ApiTimeout).Return(rejected, http.StatusOK, nil).Times(1)
+
+				book, _ := json.Marshal(RupeeseedOrderBookResponse{
+					Status: "success",
+					Data:   []RupeeseedOrderBook{{OrderNo: "112211242011", Status: "Pending", SerialNo: 1, GroupId: 2}},
+				})
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderBookApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, bookUri, getOrderBookRupeeseedRequestBody(c), rupeeseedHeaders, ApiTimeout).Return(book, http.StatusOK, nil).Times(1)
+
+				// cancel carries group of the leg from the order book
+				cancel := getRupeeseedCancelOrderRequestBody(c, CancelOrderRequest{
+					OrderNo:  "112211242011",
+					SerialNo: 1,
+					GroupId:  2,
+					TxnType:  buyLeg.TxnType,
+					Exchange: buyLeg.Exchange,
+					Segment:  buyLeg.Segment,
+					Product:  buyLeg.Product,
+				})
+				cancelled, _ := json.Marshal(RupeeseedNormalOrderResponse{Status: "success", Message: "Order cancelled"})
+				cancelUri := config.GetConfig().GetString("rupeeseed.endpoint") + CancelOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, cancelUri, cancel, rupeeseedHeaders, ApiTimeout).Return(cancelled, http.StatusOK, nil).Times(1)
+			},
+			wantErr:  false,
+			wantPass: false,
+		},
+		{
+			name:  "PlacedLegMissingFromOrderBook",
+			input: BasketOrderRequest{Legs: []PlaceOrderRequest{buyLeg, sellLeg}, CancelOnFailure: true},
+			setup: func(c *gin.Context, data BasketOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				redisCaller = invoker
+				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, buyLeg), rupeeseedHeaders, ApiTimeout).Return(placed("112211242011"), http.StatusOK, nil).Times(1)
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, sellLeg), rupeeseedHeaders, ApiTimeout).Return(rejected, http.StatusOK, nil).Times(1)
+
+				empty, _ := json.Marshal(RupeeseedOrderBookResponse{Status: "success"})
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderBookApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, bookUri, getOrderBookRupeeseedRequestBody(c), rupeeseedHeaders, ApiTimeout).Return(empty, http.StatusOK, nil).Times(1)
+			},
+			wantErr:      false,
+			wantPass:     false,
+			wantLegError: true,
+		},
+		{
+			name:  "FilledLegUnwound",
+			input: BasketOrderRequest{Legs: []PlaceOrderRequest{buyLeg, sellLeg}, CancelOnFailure: true},
+			setup: func(c *gin.Context, data BasketOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				redisCaller = invoker
+				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, buyLeg), rupeeseedHeaders, ApiTimeout).Return(placed("112211242011"), http.StatusOK, nil).Times(1)
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, sellLeg), rupeeseedHeaders, ApiTimeout).Return(rejected, http.StatusOK, nil).Times(1)
+
+				book, _ := json.Marshal(RupeeseedOrderBookResponse{
+					Status: "success",
+					Data:   []RupeeseedOrderBook{{OrderNo: "112211242011", Status: "Traded", TradedQty: 50}},
+				})
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderBookApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, bookUri, getOrderBookRupeeseedRequestBody(c), rupeeseedHeaders, ApiTimeout).Return(book, http.StatusOK, nil).Times(1)
+
+				// executed buy leg is sold back at market
+				unwind := buyLeg
+				unwind.TxnType = "S"
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, unwind), rupeeseedHeaders, ApiTimeout).Return(placed("112211242013"), http.StatusOK, nil).Times(1)
+			},
+			wantErr:  false,
+			wantPass: false,
+		},
+		{
+			name:  "AllLegsFailed",
+			input: BasketOrderRequest{Legs: []PlaceOrderRequest{buyLeg, sellLeg}, CancelOnFailure: true},
+			setup: func(c *gin.Context, data BasketOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				redisCaller = invoker
+				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(rejected, http.StatusOK, nil).Times(2)
+
+				empty, _ := json.Marshal(RupeeseedOrderBookResponse{Status: "success"})
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderBookApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, bookUri, getOrderBookRupeeseedRequestBody(c), rupeeseedHeaders, ApiTimeout).Return(empty, http.StatusOK, nil).AnyTimes()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			byteData, _ := json.Marshal(test.input)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(byteData))
+			ctx.Request.Header.Set("Content-Type", "application/json")
+			test.setup(ctx, test.input)
+			servObj := NewTradeGroup(dbObj, restCaller, redisCaller)
+			servObj.PlaceBasketOrder(ctx)
+			if test.wantErr != ctx.IsAborted() {
+				t.Errorf("TestPlaceBasketOrder() failed testcase=[%s] want contextaborted [%v], got  [%v]", test.name, test.wantErr, ctx.IsAborted())
+				return
+			}
+			if !test.wantErr {
+				var response BasketOrderResponse
+				_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+				if response.Status != test.wantPass || len(response.Data) != len(test.input.Legs) {
+					t.Errorf("TestPlaceBasketOrder() failed testcase=[%s] want status [%v], got [%s]", test.name, test.wantPass, recorder.Body.String())
+					return
+				}
+				if test.wantLegError && len(response.Data[0].Errors) == 0 {
+					t.Errorf("TestPlaceBasketOrder() failed testcase=[%s] want error on leg not rolled back, got [%s]", test.name, recorder.Body.String())
+					return
+				}
+				if test.input.CancelOnFailure && !test.wantLegError && !response.Data[0].Cancelled && !response.Data[0].Unwound {
+					t.Errorf("TestPlaceBasketOrder() failed testcase=[%s] want placed leg rolled back, got [%s]", test.name, recorder.Body.String())
+					return
+				}
+				// some legs placed is reported as multi status
+				wantStatus := http.StatusMultiStatus
+				if test.wantPass {
+					wantStatus = http.StatusOK
+				}
+				if recorder.Code != wantStatus {
+					t.Errorf("TestPlaceBasketOrder() failed testcase=[%s] want http status [%d], got [%d]", test.name, wantStatus, recorder.Code)
+					return
+				}
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+}
+
+func TestPlaceGTTOrder(t *testing.T) {
+	var (
+		dbObj       db.DBLayer
+		restCaller  utils.RestCaller
+		redisCaller utils.RedisInterface
+	)
+
+	order := PlaceOrderRequest{
+		TxnType:       "B",
+		Exchange:      "NSE",
+		Segment:       "E",
+		Product:       "C",
+		ExchangeToken: 1594,
+		Quantity:      1,
+		Validity:      "DAY",
+		OrderType:     "LMT",
+		Price:         1450.0,
+	}
+
+	tests := []struct {
+		name    string
+		input   GTTOrderRequest
+		setup   func(*gin.Context, GTTOrderRequest)
+		wantErr bool
+	}{
+		{
+			name:  "InvalidOrder",
+			input: GTTOrderRequest{TriggerPrice: 1450.0, Order: PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "C", ExchangeToken: 1594, Quantity: 1, Validity: "DAY", OrderType: "LMT"}},
+			setup: func(c *gin.Context, data GTTOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				redisCaller = invoker
+				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+			},
+			wantErr: true,
+		},
+		{
+			name:  "TriggerAtLastTradedPrice",
+			input: GTTOrderRequest{TriggerPrice: 1500.0, Order: order},
+			setup: func(c *gin.Context, data GTTOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				redisCaller = invoker
+				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+				repo.EXPECT().GetSymbolTickData("1594", "NSE").Return(scrip.SymbolTickData{LastTradedPrice: 1500.0}, nil).Times(1)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "GTTPlaced",
+			input: GTTOrderRequest{TriggerPrice: 1450.0, Order: order},
+			setup: func(c *gin.Context, data GTTOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				redisCaller = invoker
+				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+				repo.EXPECT().GetSymbolTickData("1594", "NSE").Return(scrip.SymbolTickData{LastTradedPrice: 1500.0}, nil).Times(1)
+				repo.EXPECT().CreateGTTOrder(gomock.Any()).DoAndReturn(func(record gtt.GTTOrder) (int64, error) {
+					if record.Condition != GTTConditionBelow || record.Status != GTTActive {
+						t.Errorf("CreateGTTOrder() want active BELOW GTT, got [%v]", record)
+					}
+					return 1, nil
+				}).Times(1)
+			},
+			wantErr: false,
+		},
+		{
+			name:  "StoreFailure",
+			input: GTTOrderRequest{TriggerPrice: 1550.0, Order: order},
+			setup: func(c *gin.Context, data GTTOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				redisCaller = invoker
+				restCaller = invoker
+				invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+				repo.EXPECT().GetSymbolTickData("1594", "NSE").Return(scrip.SymbolTickData{LastTradedPrice: 1500.0}, nil).Times(1)
+				repo.EXPECT().CreateGTTOrder(gomock.Any()).Return(int64(0), errors.New("connection refused")).Times(1)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := getConntext("POST", test.input)
+			test.setup(ctx, test.input)
+			servObj := NewTradeGroup(dbObj, restCaller, redisCaller)
+			servObj.PlaceGTTOrder(ctx)
+			if test.wantErr != ctx.IsAborted() {
+				t.Errorf("TestPlaceGTTOrder() failed testcase=[%s] want contextaborted [%v], got  [%v]", test.name, test.wantErr, ctx.IsAborted())
+				return
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+}
+
+func TestDeleteGTTOrder(t *testing.T) {
+	// GTT 1 is active, GTT 2 was triggered by the evaluator, GTT 3 belongs to another user
+	stored := map[int64]gtt.GTTOrder{
+		1: {ID: 1, UserID: "TEST2", OrderPayload: "{}", Status: GTTActive},
+		2: {ID: 2, UserID: "TEST2", OrderPayload: "{}", Status: GTTTriggered},
+		3: {ID: 3, UserID: "TEST3", OrderPayload: "{}", Status: GTTActive},
+	}
+	tests := []struct {
+		name       string
+		id         string
+		storeErr   error
+		httpStatus int
+	}{
+		{name: "ActiveDeleted", id: "1", httpStatus: http.StatusOK},
+		{name: "NoLongerActive", id: "2", httpStatus: http.StatusConflict},
+		{name: "OtherUsersGTT", id: "3", httpStatus: http.StatusNotFound},
+		{name: "UnknownId", id: "4", httpStatus: http.StatusNotFound},
+		{name: "InvalidId", id: "abc", httpStatus: http.StatusBadRequest},
+		{name: "StoreFailure", id: "1", storeErr: errors.New("connection refused"), httpStatus: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			invoker := mock.NewMockUtils(ctrl)
+			repo := dbmock.NewMockDBLayer(ctrl)
+			repo.EXPECT().DeleteGTTOrderIfStatus(gomock.Any(), "TEST2", GTTActive).DoAndReturn(func(id int64, userId, status string) (bool, error) {
+				record, ok := stored[id]
+				return ok && record.UserID == userId && record.Status == status, test.storeErr
+			}).AnyTimes()
+			repo.EXPECT().GetGTTOrders("TEST2").DoAndReturn(func(userId string) ([]gtt.GTTOrder, error) {
+				var records []gtt.GTTOrder
+				for _, record := range stored {
+					if record.UserID == userId {
+						records = append(records, record)
+					}
+				}
+				return records, nil
+			}).AnyTimes()
+			servObj := NewTradeGroup(repo, invoker, invoker)
+
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			ctx.Request = httptest.NewRequest(http.MethodDelete, "/?"+GTTIdParam+"="+test.id, nil)
+			ctx.Set("userId", "TEST2")
+			servObj.DeleteGTTOrder(ctx)
+			if recorder.Code != test.httpStatus {
+				t.Errorf("TestDeleteGTTOrder() failed testcase=[%s] want status [%d], got [%d] body [%s]", test.name, test.httpStatus, recorder.Code, recorder.Body.String())
+				return
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+}
+
+func TestEvaluateGTTOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+	repo := dbmock.NewMockDBLayer(ctrl)
+	servObj := NewTradeGroup(repo, invoker, invoker)
+
+	now := time.Now()
+	order := PlaceOrderRequest{
+		TxnType:       "B",
+		Exchange:      "NSE",
+		Segment:       "E",
+		Product:       "C",
+		ExchangeToken: 1594,
+		Quantity:      1,
+		Validity:      "DAY",
+		OrderType:     "MKT",
+	}
+	payload, _ := json.Marshal(order)
+	record := func(id int64, condition string, trigger float64, expiresAt time.Time) gtt.GTTOrder {
+		return gtt.GTTOrder{ID: id, UserID: "TEST2", Exchange: "NSE", ExchangeToken: "1594", TriggerPrice: trigger,
+			Condition: condition, OrderPayload: string(payload), Status: GTTActive, ExpiresAt: expiresAt}
+	}
+
+	repo.EXPECT().GetActiveGTTOrders().Return([]gtt.GTTOrder{
+		record(1, GTTConditionBelow, 1450.0, now.Add(time.Hour)),
+		record(2, GTTConditionAbove, 1550.0, now.Add(time.Hour)),
+		record(3, GTTConditionAbove, 1550.0, now.Add(-time.Hour)),
+		record(4, GTTConditionBelow, 1450.0, now.Add(time.Hour)),
+	}, nil).Times(1)
+	repo.EXPECT().GetSymbolTickData("1594", "NSE").Return(scrip.SymbolTickData{LastTradedPrice: 1440.0}, nil).AnyTimes()
+	repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+
+	placed, _ := json.Marshal(RupeeseedNormalOrderResponse{
+		Status:  "success",
+		Message: "Order submitted successfully. Your Order Ref No. 112211242020",
+		Data:    []OrderData{{Order: "112211242020"}},
+	})
+	uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+	invoker.EXPECT().InvokeHttp(http.MethodPost, uri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(placed, http.StatusOK, nil).Times(1)
+
+	// GTT 4 was claimed by another evaluator after it was read
+	stored := map[int64]string{1: GTTActive, 2: GTTActive, 3: GTTActive, 4: GTTTriggered}
+	statuses := make(map[int64][]string)
+	repo.EXPECT().UpdateGTTOrderIfStatus(gomock.Any(), GTTActive).DoAndReturn(func(r gtt.GTTOrder, status string) (bool, error) {
+		if stored[r.ID] != status {
+			return false, nil
+		}
+		stored[r.ID] = r.Status
+		statuses[r.ID] = append(statuses[r.ID], r.Status)
+		return true, nil
+	}).AnyTimes()
+	repo.EXPECT().UpdateGTTOrder(gomock.Any()).DoAndReturn(func(r gtt.GTTOrder) error {
+		stored[r.ID] = r.Status
+		statuses[r.ID] = append(statuses[r.ID], r.Status)
+		return nil
+	}).AnyTimes()
+
+	servObj.evaluateGTTOrders(now)
+
+	if fmt.Sprint(statuses[1]) != fmt.Sprint([]string{GTTTriggered, GTTTriggered}) {
+		t.Errorf("evaluateGTTOrders() want GTT 1 triggered, got [%v]", statuses[1])
+	}
+	if len(statuses[2]) != 0 {
+		t.Errorf("evaluateGTTOrders() want GTT 2 untouched, got [%v]", statuses[2])
+	}
+	if fmt.Sprint(statuses[3]) != fmt.Sprint([]string{GTTExpired}) {
+		t.Errorf("evaluateGTTOrders() want GTT 3 expired, got [%v]", statuses[3])
+	}
+	if len(statuses[4]) != 0 {
+		t.Errorf("evaluateGTTOrders() want GTT 4 claimed elsewhere left untouched, got [%v]", statuses[4])
+	}
+}
+
+func TestPlaceOrderFreezeQuantitySlicing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+	repo := dbmock.NewMockDBLayer(ctrl)
+	servObj := NewTradeGroup(repo, invoker, invoker)
+
+	input := PlaceOrderRequest{
+		TxnType:       "B",
+		Exchange:      "NSE",
+		Segment:       "D",
+		Product:       "M",
+		ExchangeToken: 43210,
+		Quantity:      4000,
+		Validity:      "DAY",
+		OrderType:     "MKT",
+	}
+	repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 50, TickSize: 0.05, FreezeQty: 1800}}, 1, nil).AnyTimes()
+	stored := make(map[string]string)
+	invoker.EXPECT().Set(gomock.Any(), gomock.Any(), SlicedOrderTTL).DoAndReturn(func(key string, value interface{}, ttl time.Duration) error {
+		stored[key] = value.(string)
+		return nil
+	}).AnyTimes()
+
+	uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+	rejected, _ := json.Marshal(RupeeseedNormalOrderResponse{Status: "error", ErrCode: "RS-0023", Message: "SCRIP IS BLOCKED"})
+	tests := []struct {
+		name       string
+		replies    [][]byte
+		httpStatus int
+		placed     int
+	}{
+		{name: "AllChildrenPlaced", replies: [][]byte{nil, nil, nil}, httpStatus: http.StatusOK, placed: 3},
+		// children placed before the failure are live, reply is partial success
+		{name: "LastChildFailed", replies: [][]byte{nil, nil, rejected}, httpStatus: http.StatusMultiStatus, placed: 2},
+	}
+	for _, test := range tests {
+		for i, qty := range []int{1800, 1800, 400} {
+			child := input
+			child.Quantity = qty
+			reply := test.replies[i]
+			if reply == nil {
+				reply, _ = json.Marshal(RupeeseedNormalOrderResponse{
+					Status: "success",
+					Data:   []OrderData{{Order: fmt.Sprintf("11221124203%d", i)}},
+				})
+			}
+			req := getRupeeseedOrderRequestBody(nil, child)
+			invoker.EXPECT().InvokeHttp(http.MethodPost, uri, req, rupeeseedHeaders, ApiTimeout).Return(reply, http.StatusOK, nil).Times(1)
+		}
+
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		byteData, _ := json.Marshal(input)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(byteData))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		servObj.PlaceOrder(ctx)
+
+		var response struct {
+			Status bool        `json:"status"`
+			Data   SlicedOrder `json:"data"`
+		}
+		_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+		if recorder.Code != test.httpStatus || len(response.Data.ChildOrders) != 3 || response.Data.placed() != test.placed || len(response.Data.ParentId) == 0 {
+			t.Errorf("TestPlaceOrderFreezeQuantitySlicing() failed testcase=[%s] want [%d] children placed under a parent, got [%d] [%s]", test.name, test.placed, recorder.Code, recorder.Body.String())
+			continue
+		}
+
+		// placed children stay linked to the parent id
+		var link SlicedOrder
+		_ = json.Unmarshal([]byte(stored[SlicedOrderKeyPrefix+":"+response.Data.ParentId]), &link)
+		if link.placed() != test.placed {
+			t.Errorf("TestPlaceOrderFreezeQuantitySlicing() failed testcase=[%s] want placed children stored under [%s], got [%v]", test.name, response.Data.ParentId, link)
+		}
+	}
+
+	slices := []struct {
+		quantity, freezeQty, lotSize int
+		want                         []int
+	}{
+		{quantity: 3600, freezeQty: 1800, lotSize: 50, want: []int{1800, 1800}},
+		{quantity: 1900, freezeQty: 1800, lotSize: 75, want: []int{1800, 100}},
+		{quantity: 2000, freezeQty: 1801, lotSize: 25, want: []int{1800, 200}},
+		{quantity: 900, freezeQty: 900, lotSize: 15, want: []int{900}},
+	}
+	for _, sl := range slices {
+		got := sliceQuantity(sl.quantity, sl.freezeQty, sl.lotSize)
+		if fmt.Sprint(got) != fmt.Sprint(sl.want) {
+			t.Errorf("sliceQuantity(%d, %d, %d) want [%v], got [%v]", sl.quantity, sl.freezeQty, sl.lotSize, sl.want, got)
+		}
+	}
+}
+
+func TestSquareOffPositions(t *testing.T) {
+	var (
+		dbObj       db.DBLayer
+		restCaller  utils.RestCaller
+		redisCaller utils.RedisInterface
+	)
+
+	positions, _ := json.Marshal(RupeeseedPositionBookResponse{
+		Status: "success",
+		Data: []RupeeSeedPositionBook{
+			{Symbol: "SBIN", Exchange: "NSE", Segment: "E", Product: "I", SecurityID: "3045", NetQty: 10},
+			{Symbol: "ITC", Exchange: "NSE", Segment: "E", Product: "C", SecurityID: "1660", NetQty: -5},
+			{Symbol: "TCS", Exchange: "NSE", Segment: "E", Product: "I", SecurityID: "11536", NetQty: 0},
+		},
+	})
+	// pending intraday SBIN order is cancelled before the position is squared off
+	orders, _ := json.Marshal(RupeeseedOrderBookResponse{
+		Status: "success",
+		Data: []RupeeseedOrderBook{
+			{OrderNo: "112211242025", Status: "Pending", Segment: "E", Product: "I", Exchange: "NSE", SecurityID: "3045", TxnType: "B", RemainingQuantity: 5},
+			{OrderNo: "112211242026", Status: "Traded", Segment: "E", Product: "C", Exchange: "NSE", SecurityID: "1660", TxnType: "S"},
+		},
+	})
+	cancelled, _ := json.Marshal(RupeeseedNormalOrderResponse{Status: "success", Message: "Order cancelled"})
+	placed, _ := json.Marshal(RupeeseedNormalOrderResponse{Status: "success", Data: []OrderData{{Order: "112211242021"}}})
+	sellSBIN := PlaceOrderRequest{TxnType: "S", Exchange: "NSE", Segment: "E", Product: "I", ExchangeToken: 3045, Quantity: 10, Validity: "DAY", OrderType: "MKT"}
+	buyITC := PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "C", ExchangeToken: 1660, Quantity: 5, Validity: "DAY", OrderType: "MKT"}
+
+	tests := []struct {
+		name       string
+		input      SquareOffRequest
+		setup      func(*gin.Context, SquareOffRequest)
+		wantErr    bool
+		wantCount  int
+		httpStatus int
+	}{
+		{
+			name:  "AllOpenPositions",
+			input: SquareOffRequest{},
+			setup: func(c *gin.Context, data SquareOffRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + PositionBookApi
+				invoker.EXPECT().InvokeResty(http.MethodPost, bookUri, getPositionBookRupeeseedRequestBody(c), nil, 700).Return(positions, http.StatusOK, nil).Times(1)
+				orderBookUri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderBookApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, orderBookUri, getOrderBookRupeeseedRequestBody(c), rupeeseedHeaders, ApiTimeout).Return(orders, http.StatusOK, nil).Times(1)
+				cancelUri := config.GetConfig().GetString("rupeeseed.endpoint") + CancelOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, cancelUri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(cancelled, http.StatusOK, nil).Times(1)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, sellSBIN), rupeeseedHeaders, ApiTimeout).Return(placed, http.StatusOK, nil).Times(1)
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, buyITC), rupeeseedHeaders, ApiTimeout).Return(placed, http.StatusOK, nil).Times(1)
+			},
+			wantErr:    false,
+			wantCount:  2,
+			httpStatus: http.StatusOK,
+		},
+		{
+			name:  "FilterByProduct",
+			input: SquareOffRequest{Product: "c"},
+			setup: func(c *gin.Context, data SquareOffRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + PositionBookApi
+				invoker.EXPECT().InvokeResty(http.MethodPost, bookUri, getPositionBookRupeeseedRequestBody(c), nil, 700).Return(positions, http.StatusOK, nil).Times(1)
+				orderBookUri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderBookApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, orderBookUri, getOrderBookRupeeseedRequestBody(c), rupeeseedHeaders, ApiTimeout).Return(orders, http.StatusOK, nil).Times(1)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, buyITC), rupeeseedHeaders, ApiTimeout).Return(placed, http.StatusOK, nil).Times(1)
+			},
+			wantErr:    false,
+			wantCount:  1,
+			httpStatus: http.StatusOK,
+		},
+		{
+			name:  "OnePositionFailed",
+			input: SquareOffRequest{Segment: "E"},
+			setup: func(c *gin.Context, data SquareOffRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + PositionBookApi
+				invoker.EXPECT().InvokeResty(http.MethodPost, bookUri, getPositionBookRupeeseedRequestBody(c), nil, 700).Return(positions, http.StatusOK, nil).Times(1)
+				orderBookUri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderBookApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, orderBookUri, getOrderBookRupeeseedRequestBody(c), rupeeseedHeaders, ApiTimeout).Return(orders, http.StatusOK, nil).Times(1)
+				cancelUri := config.GetConfig().GetString("rupeeseed.endpoint") + CancelOrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, cancelUri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(cancelled, http.StatusOK, nil).Times(1)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, sellSBIN), rupeeseedHeaders, ApiTimeout).Return(placed, http.StatusOK, nil).Times(1)
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, buyITC), rupeeseedHeaders, ApiTimeout).Return(nil, http.StatusInternalServerError, nil).Times(1)
+			},
+			wantErr:    false,
+			wantCount:  2,
+			httpStatus: http.StatusMultiStatus,
+		},
+		{
+			name:  "AllPositionsFailed",
+			input: SquareOffRequest{Product: "C"},
+			setup: func(c *gin.Context, data SquareOffRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + PositionBookApi
+				invoker.EXPECT().InvokeResty(http.MethodPost, bookUri, getPositionBookRupeeseedRequestBody(c), nil, 700).Return(positions, http.StatusOK, nil).Times(1)
+				orderBookUri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderBookApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, orderBookUri, getOrderBookRupeeseedRequestBody(c), rupeeseedHeaders, ApiTimeout).Return(orders, http.StatusOK, nil).Times(1)
+				uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+				invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(c, buyITC), rupeeseedHeaders, ApiTimeout).Return(nil, http.StatusInternalServerError, nil).Times(1)
+			},
+			wantErr:    true,
+			wantCount:  1,
+			httpStatus: http.StatusBadGateway,
+		},
+		{
+			name:  "NoMatchingPosition",
+			input: SquareOffRequest{Symbol: "TCS"},
+			setup: func(c *gin.Context, data SquareOffRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + PositionBookApi
+				invoker.EXPECT().InvokeResty(http.MethodPost, bookUri, getPositionBookRupeeseedRequestBody(c), nil, 700).Return(positions, http.StatusOK, nil).Times(1)
+			},
+			wantErr:    true,
+			httpStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			byteData, _ := json.Marshal(test.input)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(byteData))
+			ctx.Request.Header.Set("Content-Type", "application/json")
+			test.setup(ctx, test.input)
+			servObj := NewTradeGroup(dbObj, restCaller, redisCaller)
+			servObj.SquareOffPositions(ctx)
+			if test.wantErr != ctx.IsAborted() {
+				t.Errorf("TestSquareOffPositions() failed testcase=[%s] want contextaborted [%v], got  [%v]", test.name, test.wantErr, ctx.IsAborted())
+				return
+			}
+			if recorder.Code != test.httpStatus {
+				t.Errorf("TestSquareOffPositions() failed testcase=[%s] want http status [%d], got [%d]", test.name, test.httpStatus, recorder.Code)
+				return
+			}
+			if test.wantCount > 0 {
+				var response SquareOffResponse
+				_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+				if response.Status != (test.httpStatus == http.StatusOK) || len(response.Data) != test.wantCount {
+					t.Errorf("TestSquareOffPositions() failed testcase=[%s] want [%d] positions squared off, got [%s]", test.name, test.wantCount, recorder.Body.String())
+					return
+				}
+				if response.Data[0].Symbol == "SBIN" && fmt.Sprint(response.Data[0].CancelledOrderNos) != "[112211242025]" {
+					t.Errorf("TestSquareOffPositions() failed testcase=[%s] want open SBIN order cancelled, got [%s]", test.name, recorder.Body.String())
+					return
+				}
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+}
+
+func TestRunAutoSquareOff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	repo := dbmock.NewMockDBLayer(ctrl)
+	servObj := NewTradeGroup(repo, invoker, invoker)
+
+	// after equity cutoff & before derivative cutoff
+	now := time.Date(2023, time.March, 10, 15, 22, 0, 0, istLocation)
+	tradeDate := "2023-03-10"
+
+	// no segment is due on a weekend
+	servObj.runAutoSquareOff(time.Date(2023, time.March, 11, 15, 22, 0, 0, istLocation))
+
+	repo.EXPECT().GetSquareOffRuns(tradeDate).Return([]squareoff.Run{
+		{ID: 1, UserID: "DONE1", Segment: EquitySegment, TradeDate: tradeDate, Attempt: 1, Status: SquareOffCompleted},
+		{ID: 3, UserID: "BUSY3", Segment: EquitySegment, TradeDate: tradeDate, Attempt: 1, Status: SquareOffRunning, StartedAt: now.Add(-time.Minute)},
+	}, nil).Times(1)
+	repo.EXPECT().GetSquareOffUsers().Return([]string{"TEST2", "DONE1", "BUSY3", "TAKEN4"}, nil).Times(1)
+	// TAKEN4 is claimed by another instance first
+	repo.EXPECT().ClaimSquareOffRun(gomock.Any()).DoAndReturn(func(run squareoff.Run) (int64, bool, error) {
+		if run.UserID != "TEST2" || run.Attempt != 1 || run.Status != SquareOffRunning {
+			return 0, false, nil
+		}
+		return 2, true, nil
+	}).Times(2)
+	// TEST2 trades on paper, orders & positions left at the broker are still closed
+	paperMode, _ := json.Marshal(PaperTrading{UserID: "TEST2", Enabled: true})
+	invoker.EXPECT().Get(PaperTradingKeyPrefix+"TEST2").Return(string(paperMode), nil).AnyTimes()
+
+	book, _ := json.Marshal(RupeeseedOrderBookResponse{
+		Status: "success",
+		Data: []RupeeseedOrderBook{
+			{OrderNo: "112211242031", Status: "Pending", Segment: "E", Product: "I", Exchange: "NSE", TxnType: "B", RemainingQuantity: 5},
+			{OrderNo: "112211242032", Status: "Pending", Segment: "E", Product: "C", Exchange: "NSE", TxnType: "B"},
+			{OrderNo: "112211242033", Status: "Traded", Segment: "E", Product: "I", Exchange: "NSE", TxnType: "B"},
+			// stoploss & target legs of a bracket order are exited once together
+			{OrderNo: "112211242035", Status: "Pending", Segment: "E", Product: "B", Exchange: "NSE", TxnType: "S", LegNo: "2", AlgoOrderNo: "5001"},
+			{OrderNo: "112211242035", Status: "Pending", Segment: "E", Product: "B", Exchange: "NSE", TxnType: "S", LegNo: "3", AlgoOrderNo: "5001"},
+			{OrderNo: "112211242036", Status: "Pending", Segment: "E", Product: "V", Exchange: "NSE", TxnType: "B", LegNo: "2"},
+		},
+	})
+	// read again by square off of the positions, the cancelled order has no position to match
+	bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderBookApi
+	invoker.EXPECT().InvokeHttp(http.MethodPost, bookUri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(book, http.StatusOK, nil).Times(2)
+
+	cancelled, _ := json.Marshal(RupeeseedNormalOrderResponse{Status: "success", Message: "Order cancelled"})
+	cancelUri := config.GetConfig().GetString("rupeeseed.endpoint") + CancelOrderApi
+	invoker.EXPECT().InvokeHttp(http.MethodPost, cancelUri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(cancelled, http.StatusOK, nil).Times(1)
+	exited, _ := json.Marshal(RupeeseedNormalOrderResponse{Status: "success", Message: "Order exited"})
+	boExit := getRupeeseedBracketExitRequestBody(userContext("TEST2"), CancelOrderRequest{OrderNo: "112211242035", LegNo: 2, AlgoOrderNo: "5001", TxnType: "S", Exchange: "NSE", Segment: "E", Product: "B"})
+	boExitUri := config.GetConfig().GetString("rupeeseed.endpoint") + BoExitOrderApi
+	invoker.EXPECT().InvokeHttp(http.MethodPost, boExitUri, boExit, rupeeseedHeaders, ApiTimeout).Return(exited, http.StatusOK, nil).Times(1)
+	coExit := getRupeeseedCoverExitRequestBody(userContext("TEST2"), CancelOrderRequest{OrderNo: "112211242036", LegNo: 2, TxnType: "B", Exchange: "NSE", Segment: "E", Product: "V"})
+	coExitUri := config.GetConfig().GetString("rupeeseed.endpoint") + CoExitOrderApi
+	invoker.EXPECT().InvokeHttp(http.MethodPost, coExitUri, coExit, rupeeseedHeaders, ApiTimeout).Return(exited, http.StatusOK, nil).Times(1)
+
+	positions, _ := json.Marshal(RupeeseedPositionBookResponse{
+		Status: "success",
+		Data: []RupeeSeedPositionBook{
+			{Symbol: "SBIN", Exchange: "NSE", Segment: "E", Product: "I", SecurityID: "3045", NetQty: 10},
+			{Symbol: "INFY", Exchange: "NSE", Segment: "E", Product: "V", SecurityID: "1594", NetQty: -3},
+			{Symbol: "ITC", Exchange: "NSE", Segment: "E", Product: "C", SecurityID: "1660", NetQty: 5},
+			{Symbol: "NIFTY", Exchange: "NSE", Segment: "D", Product: "I", SecurityID: "43210", NetQty: 50},
+		},
+	})
+	positionUri := config.GetConfig().GetString("rupeeseed.endpoint") + PositionBookApi
+	invoker.EXPECT().InvokeResty(http.MethodPost, positionUri, gomock.Any(), nil, 700).Return(positions, http.StatusOK, nil).Times(1)
+
+	placed, _ := json.Marshal(RupeeseedNormalOrderResponse{Status: "success", Data: []OrderData{{Order: "112211242034"}}})
+	sellSBIN := PlaceOrderRequest{TxnType: "S", Exchange: "NSE", Segment: "E", Product: "I", ExchangeToken: 3045, Quantity: 10, Validity: "DAY", OrderType: "MKT"}
+	uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+	invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(userContext("TEST2"), sellSBIN), rupeeseedHeaders, ApiTimeout).Return(placed, http.StatusOK, nil).Times(1)
+	buyINFY := PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "V", ExchangeToken: 1594, Quantity: 3, Validity: "DAY", OrderType: "MKT"}
+	invoker.EXPECT().InvokeHttp(http.MethodPost, uri, getRupeeseedOrderRequestBody(userContext("TEST2"), buyINFY), rupeeseedHeaders, ApiTimeout).Return(placed, http.StatusOK, nil).Times(1)
+
+	var saved []squareoff.Run
+	repo.EXPECT().SaveSquareOffRun(gomock.Any()).DoAndReturn(func(run squareoff.Run) (int64, error) {
+		saved = append(saved, run)
+		return 2, nil
+	}).Times(1)
+
+	servObj.runAutoSquareOff(now)
+
+	if len(saved) != 1 || saved[0].UserID != "TEST2" || saved[0].Status != SquareOffCompleted || saved[0].ID != 2 {
+		t.Errorf("runAutoSquareOff() want run of TEST2 claimed & completed, got [%+v]", saved)
+		return
+	}
+	var actions []AutoSquareOffAction
+	_ = json.Unmarshal([]byte(saved[0].Actions), &actions)
+	if len(actions) != 5 || actions[0].Type != SquareOffCancelAction || actions[2].OrderNo != "112211242036" ||
+		actions[3].Type != SquareOffOrderAction || actions[3].Quantity != 10 || actions[4].Quantity != 3 {
+		t.Errorf("runAutoSquareOff() want intraday, bracket & cover orders closed & positions squared off, got [%s]", saved[0].Actions)
+	}
+}
+
+func TestAutoSquareOffRuns(t *testing.T) {
+	run := squareoff.Run{ID: 2, UserID: "TEST2", Segment: EquitySegment, TradeDate: "2023-03-10", Attempt: 1, Status: SquareOffCompleted,
+		Actions: `[{"type":"` + SquareOffOrderAction + `","order_no":"112211242034","status":true}]`}
+	tests := []struct {
+		name       string
+		date       string
+		storeErr   error
+		httpStatus int
+		wantRuns   int
+	}{
+		{name: "RunsOfUser", date: "2023-03-10", httpStatus: http.StatusOK, wantRuns: 1},
+		{name: "InvalidDate", date: "10-03-2023", httpStatus: http.StatusBadRequest},
+		{name: "StoreFailure", date: "2023-03-10", storeErr: errors.New("connection refused"), httpStatus: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			invoker := mock.NewMockUtils(ctrl)
+			repo := dbmock.NewMockDBLayer(ctrl)
+			// runs are read for the user only
+			repo.EXPECT().GetUserSquareOffRuns("TEST2", test.date).Return([]squareoff.Run{run}, test.storeErr).AnyTimes()
+			servObj := NewTradeGroup(repo, invoker, invoker)
+
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/?"+TradeDateParam+"="+test.date, nil)
+			ctx.Set("userId", "TEST2")
+			servObj.AutoSquareOffRuns(ctx)
+			if recorder.Code != test.httpStatus {
+				t.Errorf("TestAutoSquareOffRuns() failed testcase=[%s] want status [%d], got [%d]", test.name, test.httpStatus, recorder.Code)
+				return
+			}
+			var response AutoSquareOffRunsResponse
+			_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+			if len(response.Data) != test.wantRuns || (test.wantRuns > 0 && len(response.Data[0].Actions) != 1) {
+				t.Errorf("TestAutoSquareOffRuns() failed testcase=[%s] want [%d] runs with actions, got [%s]", test.name, test.wantRuns, recorder.Body.String())
+				return
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+}
+
+type fixedMarketData struct {
+	tick        scrip.SymbolTickData
+	instruments map[string]scrip.MasterSymbol
+}
+
+func (f fixedMarketData) SymbolTick(exchange string, exchangeToken int) (scrip.SymbolTickData, error) {
+	return f.tick, nil
+}
+
+func (f fixedMarketData) Instrument(exchange, segment string, exchangeToken int) (scrip.MasterSymbol, error) {
+	instrument, ok := f.instruments[segment]
+	if !ok {
+		return instrument, ErrScripNotFound
+	}
+	return instrument, nil
+}
+
+func TestRiskEngine(t *testing.T) {
+	engine := newDefaultRiskEngine()
+	err := engine.SetConfigured([]RiskRuleConfig{
+		{Rule: "maxQuantity", RiskScope: RiskScope{Segment: "D"}, Limit: 1800},
+		{Rule: "maxOrderValue", RiskScope: RiskScope{Segment: "E"}, Limit: 100000},
+		{Rule: "allowedOrderTypes", RiskScope: RiskScope{Product: "I"}, OrderTypes: []string{"LMT", "MKT"}},
+		{Rule: "priceDeviation", Limit: 10},
+	})
+	if err != nil {
+		t.Fatalf("SetConfigured() failed [%v]", err)
+	}
+	if err := engine.SetConfigured([]RiskRuleConfig{{Rule: "maxQuantity"}}); err == nil {
+		t.Errorf("SetConfigured() want error for rule without limit")
+	}
+
+	order := RiskOrder{Action: RiskPlace, Kind: NormalOrderKind, TxnType: "B", Exchange: "NSE", Segment: "E", Product: "C", OrderType: "LMT", Quantity: 10, Price: 100}
+	with := func(update func(*RiskOrder)) RiskOrder {
+		o := order
+		update(&o)
+		return o
+	}
+
+	tests := []struct {
+		name     string
+		order    RiskOrder
+		wantRule string
+	}{
+		{name: "Accepted", order: order},
+		{name: "LimitPriceZero", order: with(func(o *RiskOrder) { o.Price = 0 }), wantRule: "limitPrice"},
+		{name: "ModifySkipsPlacementRules", order: with(func(o *RiskOrder) { o.Action = RiskModify; o.OffMktFlag = true })},
+		{name: "BracketTargets", order: with(func(o *RiskOrder) { o.Kind = BracketOrderKind }), wantRule: "bracketTargets"},
+		{name: "DerivativeAboveMaxQuantity", order: with(func(o *RiskOrder) { o.Segment = "D"; o.Quantity = 1850 }), wantRule: "maxQuantity"},
+		{name: "EquityAboveMaxQuantityLimitOfDerivative", order: with(func(o *RiskOrder) { o.Quantity = 900 })},
+		{name: "MarketOrderValuedAtLastPrice", order: with(func(o *RiskOrder) { o.OrderType = "MKT"; o.Price = 0; o.Quantity = 1001 }), wantRule: "maxOrderValue"},
+		{name: "OrderTypeNotAllowedForIntraday", order: with(func(o *RiskOrder) { o.Product = "I"; o.OrderType = "SLM"; o.Price = 0; o.TriggerPrice = 100 }), wantRule: "allowedOrderTypes"},
+		{name: "PriceDeviation", order: with(func(o *RiskOrder) { o.Price = 115; o.PriceConfirmed = true }), wantRule: "priceDeviation"},
+		{name: "OutsidePriceBand", order: with(func(o *RiskOrder) { o.Price = 125; o.PriceConfirmed = true }), wantRule: "priceBand"},
+		{name: "TriggerOutsidePriceBand", order: with(func(o *RiskOrder) { o.OrderType = "SLM"; o.Price = 0; o.TriggerPrice = 79 }), wantRule: "priceBand"},
+		{name: "FarFromLastPrice", order: with(func(o *RiskOrder) { o.Price = 107 }), wantRule: "fatFinger"},
+		{name: "FarFromLastPriceConfirmed", order: with(func(o *RiskOrder) { o.Price = 107; o.PriceConfirmed = true })},
+		{name: "DeferredSkipsMarketPriceChecks", order: with(func(o *RiskOrder) { o.Price = 125; o.Deferred = true })},
+		{name: "QuantityNotMultipleOfLotSize", order: with(func(o *RiskOrder) { o.Segment = "D"; o.Quantity = 60 }), wantRule: "lotSize"},
+		{name: "PriceOffTickGrid", order: with(func(o *RiskOrder) { o.Price = 100.03 }), wantRule: "tickSize"},
+		{name: "TriggerPriceOffTickGrid", order: with(func(o *RiskOrder) { o.OrderType = "SL"; o.Price = 100.05; o.TriggerPrice = 100.01 }), wantRule: "tickSize"},
+		{name: "PriceOnTickGrid", order: with(func(o *RiskOrder) { o.Price = 100.15 })},
+		{name: "ModifyQuantityNotMultipleOfLotSize", order: with(func(o *RiskOrder) { o.Action = RiskModify; o.Segment = "D"; o.Quantity = 30 }), wantRule: "lotSize"},
+		{name: "InstrumentNotFound", order: with(func(o *RiskOrder) { o.Segment = "C" }), wantRule: "lotSize"},
+	}
+	market := fixedMarketData{tick: scrip.SymbolTickData{LastTradedPrice: 100, LowerCircuit: 80, UpperCircuit: 120}, instruments: map[string]scrip.MasterSymbol{
+		"E": {LotSize: 1, TickSize: 0.05},
+		"D": {LotSize: 50, TickSize: 0.05},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := engine.Evaluate(test.order, market)
+			var rejection *RiskRejection
+			if len(test.wantRule) == 0 {
+				if err != nil {
+					t.Errorf("TestRiskEngine() failed testcase=[%s] want accepted, got [%v]", test.name, err)
+				}
+				return
+			}
+			if !errors.As(err, &rejection) || rejection.Rule != test.wantRule {
+				t.Errorf("TestRiskEngine() failed testcase=[%s] want rule [%s], got [%v]", test.name, test.wantRule, err)
+			}
+		})
+	}
+
+	// configured rules are read from config again once the reload interval passes
+	stale := time.Now().Add(-RiskRulesReloadInterval)
+	engine.loadedAt = stale
+	_ = engine.Evaluate(order, market)
+	if !engine.loadedAt.After(stale) {
+		t.Errorf("Evaluate() want configured rules reloaded after [%v]", RiskRulesReloadInterval)
+	}
+}
+
+func TestRiskDataUnavailable(t *testing.T) {
+	// scrip master cannot be read, the order is not rejected by lotSize
+	order := RiskOrder{Action: RiskPlace, Kind: NormalOrderKind, TxnType: "B", Exchange: "NSE", Segment: "E", Product: "C", OrderType: "MKT", Quantity: 10}
+	err := newDefaultRiskEngine().Evaluate(order, failingMarketData{err: errors.New("connection refused")})
+	var unavailable *RiskDataUnavailable
+	var rejection *RiskRejection
+	if !errors.As(err, &unavailable) || errors.As(err, &rejection) || unavailable.Rule != "lotSize" {
+		t.Errorf("Evaluate() want RiskDataUnavailable of lotSize, got [%v]", err)
+	}
+	if status := validationStatus(err); status != http.StatusServiceUnavailable {
+		t.Errorf("validationStatus() want [%d], got [%d]", http.StatusServiceUnavailable, status)
+	}
+
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+	repo := dbmock.NewMockDBLayer(ctrl)
+	repo.EXPECT().GetMasterSymbols(gomock.Any()).Return(nil, 0, errors.New("connection refused")).AnyTimes()
+	servObj := NewTradeGroup(repo, invoker, invoker)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	byteData, _ := json.Marshal(PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "C", ExchangeToken: 1594, Quantity: 1, Validity: "DAY", OrderType: "MKT"})
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(byteData))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	servObj.PlaceOrder(ctx)
+	var response PlaceOrderEnvelope
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	if recorder.Code != http.StatusServiceUnavailable || len(response.Errors) == 0 || response.Errors[0].ErrName != RiskDataUnavailableError {
+		t.Errorf("PlaceOrder() want [%d] with %s when scrip master is down, got [%d] [%s]", http.StatusServiceUnavailable, RiskDataUnavailableError, recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestPriceChecksDataUnavailable(t *testing.T) {
+	market := failingMarketData{err: errors.New("connection refused")}
+	order := RiskOrder{Action: RiskPlace, Kind: NormalOrderKind, TxnType: "B", Exchange: "NSE", Segment: "E", Product: "C", OrderType: "LMT", Quantity: 10, Price: 100}
+	priceDeviation, _ := newPriceDeviationRule(RiskRuleConfig{Rule: "priceDeviation", Limit: 10})
+	for _, check := range []struct {
+		rule  string
+		check func(RiskOrder, MarketData) error
+	}{{"priceBand", checkPriceBand}, {"fatFinger", checkFatFinger}, {"priceDeviation", priceDeviation.Check}} {
+		var unavailable *RiskDataUnavailable
+		if err := check.check(order, market); !errors.As(err, &unavailable) {
+			t.Errorf("%s want RiskDataUnavailable when tick data cannot be read, got [%v]", check.rule, err)
+		}
+	}
+
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+	repo := dbmock.NewMockDBLayer(ctrl)
+	// instrument is on the tick grid, only the tick data read fails
+	repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+	repo.EXPECT().GetSymbolTickData("1594", "NSE").Return(scrip.SymbolTickData{}, errors.New("connection refused")).AnyTimes()
+	servObj := NewTradeGroup(repo, invoker, invoker)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	byteData, _ := json.Marshal(PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "C", ExchangeToken: 1594, Quantity: 1, Price: 100, Validity: "DAY", OrderType: "LMT"})
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(byteData))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	servObj.PlaceOrder(ctx)
+	var response PlaceOrderEnvelope
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	if recorder.Code != http.StatusServiceUnavailable || len(response.Errors) == 0 || response.Errors[0].ErrName != RiskDataUnavailableError {
+		t.Errorf("PlaceOrder() want [%d] when tick data cannot be read, got [%d] [%s]", http.StatusServiceUnavailable, recorder.Code, recorder.Body.String())
+	}
+}
+
+// market data of a database that cannot be reached
+type failingMarketData struct {
+	err error
+}
+
+func (f failingMarketData) SymbolTick(exchange string, exchangeToken int) (scrip.SymbolTickData, error) {
+	return scrip.SymbolTickData{}, f.err
+}
+
+func (f failingMarketData) Instrument(exchange, segment string, exchangeToken int) (scrip.MasterSymbol, error) {
+	return scrip.MasterSymbol{}, f.err
+}
+
+func TestPreviewOrder(t *testing.T) {
+	var (
+		dbObj       db.DBLayer
+		restCaller  utils.RestCaller
+		redisCaller utils.RedisInterface
+	)
+
+	order := func(kind string, data interface{}) PreviewOrderRequest {
+		byteData, _ := json.Marshal(data)
+		return PreviewOrderRequest{Kind: kind, Order: byteData}
+	}
+	intraday := PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "I", ExchangeToken: 3045, Quantity: 10, Validity: "DAY", OrderType: "MKT"}
+	// more than 5% away from last traded price of 100
+	farLimit := PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "I", ExchangeToken: 3045, Quantity: 10, Validity: "DAY", OrderType: "LMT", Price: 107}
+	cover := PlaceCoverOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "V", ExchangeToken: 3045, Quantity: 10, Validity: "DAY", OrderType: "LMT", Price: 100, TriggerPrice: 98}
+	positions, _ := json.Marshal(RupeeseedPositionBookResponse{
+		Status: "success",
+		Data:   []RupeeSeedPositionBook{{Symbol: "SBIN", Exchange: "NSE", Segment: "E", Product: "I", SecurityID: "3045", NetQty: 5}},
+	})
+
+	tests := []struct {
+		name       string
+		input      PreviewOrderRequest
+		setup      func(*gin.Context, PreviewOrderRequest)
+		wantErr    bool
+		wantStatus int
+		wantValid  bool
+		wantMargin float64
+	}{
+		{
+			name:  "UnknownKind",
+			input: order("AMO", intraday),
+			setup: func(c *gin.Context, data PreviewOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+			},
+			wantErr: true,
+		},
+		{
+			name:  "IntradayMarketOrder",
+			input: order(NormalOrderKind, intraday),
+			setup: func(c *gin.Context, data PreviewOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				repo.EXPECT().GetSymbolTickData("3045", "NSE").Return(scrip.SymbolTickData{LastTradedPrice: 100}, nil).Times(1)
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + PositionBookApi
+				invoker.EXPECT().InvokeResty(http.MethodPost, bookUri, gomock.Any(), nil, 700).Return(positions, http.StatusOK, nil).Times(1)
+			},
+			wantErr:    false,
+			wantValid:  true,
+			wantMargin: 200,
+		},
+		{
+			name:  "FarFromLastPriceUnconfirmed",
+			input: order(NormalOrderKind, ConfirmedPlaceOrderRequest{PlaceOrderRequest: farLimit}),
+			setup: func(c *gin.Context, data PreviewOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				repo.EXPECT().GetSymbolTickData("3045", "NSE").Return(scrip.SymbolTickData{LastTradedPrice: 100}, nil).AnyTimes()
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + PositionBookApi
+				invoker.EXPECT().InvokeResty(http.MethodPost, bookUri, gomock.Any(), nil, 700).Return(positions, http.StatusOK, nil).Times(1)
+			},
+			wantErr:    false,
+			wantValid:  false,
+			wantMargin: 214,
+		},
+		{
+			name:  "FarFromLastPriceConfirmed",
+			input: order(NormalOrderKind, ConfirmedPlaceOrderRequest{PlaceOrderRequest: farLimit, PriceConfirmation: PriceConfirmation{PriceConfirmed: true}}),
+			setup: func(c *gin.Context, data PreviewOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+				repo.EXPECT().GetSymbolTickData("3045", "NSE").Return(scrip.SymbolTickData{LastTradedPrice: 100}, nil).AnyTimes()
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + PositionBookApi
+				invoker.EXPECT().InvokeResty(http.MethodPost, bookUri, gomock.Any(), nil, 700).Return(positions, http.StatusOK, nil).Times(1)
+			},
+			wantErr:    false,
+			wantValid:  true,
+			wantMargin: 214,
+		},
+		{
+			name:  "OutsideTradingSession",
+			input: order(NormalOrderKind, intraday),
+			setup: func(c *gin.Context, data PreviewOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				marketCalendar.now = func() time.Time { return time.Date(2024, time.March, 12, 18, 0, 0, 0, istLocation) }
+				repo.EXPECT().GetSymbolTickData("3045", "NSE").Return(scrip.SymbolTickData{LastTradedPrice: 100}, nil).Times(1)
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + PositionBookApi
+				invoker.EXPECT().InvokeResty(http.MethodPost, bookUri, gomock.Any(), nil, 700).Return(positions, http.StatusOK, nil).Times(1)
+			},
+			wantErr:    false,
+			wantValid:  false,
+			wantMargin: 200,
+		},
+		{
+			name:  "TickDataUnavailable",
+			input: order(NormalOrderKind, intraday),
+			setup: func(c *gin.Context, data PreviewOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				// risk rules are not run outside the session, only valuing the order reads tick data
+				marketCalendar.now = func() time.Time { return time.Date(2024, time.March, 12, 18, 0, 0, 0, istLocation) }
+				repo.EXPECT().GetSymbolTickData("3045", "NSE").Return(scrip.SymbolTickData{}, errors.New("connection refused")).Times(1)
+			},
+			wantErr:    true,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:  "CoverOrderRejectedByRiskRule",
+			input: order(CoverOrderKind, func() PlaceCoverOrderRequest { o := cover; o.Quantity = 15; return o }()),
+			setup: func(c *gin.Context, data PreviewOrderRequest) {
+				ctrl := gomock.NewController(t)
+				invoker := mock.NewMockUtils(ctrl)
+				repo := dbmock.NewMockDBLayer(ctrl)
+				dbObj = repo
+				redisCaller = invoker
+				restCaller = invoker
+				repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 10, TickSize: 0.05}}, 1, nil).AnyTimes()
+				bookUri := config.GetConfig().GetString("rupeeseed.endpoint") + PositionBookApi
+				invoker.EXPECT().InvokeResty(http.MethodPost, bookUri, gomock.Any(), nil, 700).Return(positions, http.StatusOK, nil).Times(1)
+			},
+			wantErr:    false,
+			wantValid:  false,
+			wantMargin: 150,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(now func() time.Time) { marketCalendar.now = now }(marketCalendar.now)
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			byteData, _ := json.Marshal(test.input)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(byteData))
+			ctx.Request.Header.Set("Content-Type", "application/json")
+			test.setup(ctx, test.input)
+			servObj := NewTradeGroup(dbObj, restCaller, redisCaller)
+			servObj.PreviewOrder(ctx)
+			if test.wantErr != ctx.IsAborted() {
+				t.Errorf("TestPreviewOrder() failed testcase=[%s] want contextaborted [%v], got  [%v]", test.name, test.wantErr, ctx.IsAborted())
+				return
+			}
+			if test.wantStatus != 0 && recorder.Code != test.wantStatus {
+				t.Errorf("TestPreviewOrder() failed testcase=[%s] want status [%d], got [%d]", test.name, test.wantStatus, recorder.Code)
+				return
+			}
+			if !test.wantErr {
+				var response PreviewOrderResponse
+				_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+				if response.Data.Valid != test.wantValid || response.Data.RequiredMargin != test.wantMargin {
+					t.Errorf("TestPreviewOrder() failed testcase=[%s] want valid [%v] margin [%v], got [%s]", test.name, test.wantValid, test.wantMargin, recorder.Body.String())
+					return
+				}
+				if test.wantValid && (response.Data.Position == nil || response.Data.Position.ResultingQty != 15 || response.Data.Charges.Total <= 0) {
+					t.Errorf("TestPreviewOrder() failed testcase=[%s] want resulting position & charges, got [%s]", test.name, recorder.Body.String())
+					return
+				}
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+}
+
+func TestKillSwitch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	repo := dbmock.NewMockDBLayer(ctrl)
+	servObj := NewTradeGroup(repo, invoker, invoker)
+
+	store := make(map[string]string)
+	invoker.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(key string, value interface{}, expiration time.Duration) error {
+		store[key] = value.(string)
+		return nil
+	}).AnyTimes()
+	invoker.EXPECT().Get(gomock.Any()).DoAndReturn(func(key string) (string, error) {
+		return store[key], nil
+	}).AnyTimes()
+
+	call := func(handler gin.HandlerFunc, input interface{}) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		byteData, _ := json.Marshal(input)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(byteData))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		ctx.Set("userId", "RISKOPS")
+		handler(ctx)
+		return recorder
+	}
+	enabled, disabled := true, false
+	order := PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "D", Product: "M", ExchangeToken: 43210, Quantity: 50, Validity: "DAY", OrderType: "MKT"}
+
+	if recorder := call(servObj.SetKillSwitch, KillSwitchRequest{Scope: KillSwitchSegment, Enabled: &enabled}); recorder.Code != http.StatusBadRequest {
+		t.Errorf("SetKillSwitch() want segment kill switch without value rejected, got [%d]", recorder.Code)
+	}
+	if recorder := call(servObj.SetKillSwitch, KillSwitchRequest{Scope: KillSwitchSegment, Value: "d", Enabled: &enabled, Reason: "exchange outage"}); recorder.Code != http.StatusOK {
+		t.Errorf("SetKillSwitch() want segment kill switch engaged, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+	if recorder := call(servObj.PlaceOrder, order); recorder.Code != http.StatusForbidden {
+		t.Errorf("PlaceOrder() want order of blocked segment rejected, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+	if ks, _ := servObj.engagedKillSwitch("TEST2", "E"); ks != nil {
+		t.Errorf("engagedKillSwitch() want equity segment open, got [%+v]", ks)
+	}
+	if recorder := call(servObj.SetKillSwitch, KillSwitchRequest{Scope: KillSwitchSegment, Value: "D", Enabled: &disabled}); recorder.Code != http.StatusOK {
+		t.Errorf("SetKillSwitch() want segment kill switch released, got [%d]", recorder.Code)
+	}
+	if ks, _ := servObj.engagedKillSwitch("TEST2", "D"); ks != nil {
+		t.Errorf("engagedKillSwitch() want derivative segment open after release, got [%+v]", ks)
+	}
+	if recorder := call(servObj.SetKillSwitch, KillSwitchRequest{Scope: KillSwitchGlobal, Enabled: &enabled}); recorder.Code != http.StatusOK {
+		t.Errorf("SetKillSwitch() want global kill switch engaged, got [%d]", recorder.Code)
+	}
+	if ks, _ := servObj.engagedKillSwitch("TEST2", "E"); ks == nil || ks.Scope != KillSwitchGlobal {
+		t.Errorf("engagedKillSwitch() want global kill switch, got [%+v]", ks)
+	}
+
+	// kill switches that cannot be read block new orders
+	unreadable := mock.NewMockUtils(ctrl)
+	unreadable.EXPECT().Get(gomock.Any()).Return("", errors.New("redis: connection refused")).AnyTimes()
+	servObj = NewTradeGroup(repo, unreadable, unreadable)
+	if recorder := call(servObj.PlaceOrder, order); recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("PlaceOrder() want order blocked while kill switch is unreadable, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestMarketCalendar(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.March, day, hour, minute, 0, 0, istLocation)
+	}
+	calendar := NewMarketCalendar(defaultTradingSessions)
+
+	tests := []struct {
+		name      string
+		segment   string
+		now       time.Time
+		wantPhase MarketPhase
+		wantErr   bool
+	}{
+		{name: "EquityPreOpen", segment: "E", now: at(12, 9, 5), wantPhase: PreOpenPhase},
+		{name: "EquityBetweenPreOpenAndOpen", segment: "E", now: at(12, 9, 10), wantPhase: ClosedPhase},
+		{name: "EquityOpen", segment: "E", now: at(12, 9, 15), wantPhase: OpenPhase},
+		{name: "EquityAtClose", segment: "E", now: at(12, 15, 30), wantPhase: ClosedPhase},
+		{name: "EquityAfterMarket", segment: "E", now: at(12, 18, 0), wantPhase: AfterMarketPhase},
+		{name: "EquityEarlyMorning", segment: "E", now: at(12, 7, 0), wantPhase: AfterMarketPhase},
+		{name: "DerivativeNoPreOpen", segment: "D", now: at(12, 9, 12), wantPhase: ClosedPhase},
+		{name: "CommodityEvening", segment: "M", now: at(12, 21, 0), wantPhase: OpenPhase},
+		{name: "Weekend", segment: "E", now: at(16, 11, 0), wantPhase: AfterMarketPhase},
+		{name: "UnknownSegment", segment: "A", now: at(12, 11, 0), wantPhase: ClosedPhase, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			phase, err := calendar.Phase("NSE", tt.segment, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Phase() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if phase != tt.wantPhase {
+				t.Errorf("Phase() = %v, want %v", phase, tt.wantPhase)
+			}
+		})
+	}
+
+	next, err := calendar.NextOpen("NSE", "E", at(15, 16, 0))
+	if err != nil || !next.Equal(at(18, 9, 15)) {
+		t.Errorf("NextOpen() on friday evening = %v [%v], want monday open", next, err)
+	}
+
+	defer func(now func() time.Time) { marketCalendar.now = now }(marketCalendar.now)
+	marketCalendar.now = func() time.Time { return at(12, 18, 0) }
+	request := PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "C", ExchangeToken: 1594, Quantity: 1, Validity: "DAY", OrderType: "MKT"}
+	if err := applyTradingSession(&request); err == nil || request.OffMktFlag {
+		t.Errorf("applyTradingSession() after market want rejection without autoAMO, got [%v]", err)
+	}
+
+	amo := RiskOrder{Action: RiskPlace, Kind: NormalOrderKind, Exchange: "NSE", Segment: "E", OffMktFlag: true}
+	for flag, wantErr := range map[int]bool{0: true, AMOPreOpen: false, AMOMarketOpen: false, AMOPostOpen: false, 4: true} {
+		amo.OffMktOrderTimeFlag = flag
+		if err := checkOffMarketOrder(amo, nil); (err != nil) != wantErr {
+			t.Errorf("checkOffMarketOrder() flag %d error = %v, wantErr %v", flag, err, wantErr)
+		}
+	}
+	amo.Segment, amo.OffMktOrderTimeFlag = "D", AMOPreOpen
+	if err := checkOffMarketOrder(amo, nil); err == nil {
+		t.Errorf("checkOffMarketOrder() want error for pre-open AMO of derivative")
+	}
+	marketCalendar.now = func() time.Time { return at(12, 11, 0) }
+	amo.Segment, amo.OffMktOrderTimeFlag = "E", AMOMarketOpen
+	if err := checkOffMarketOrder(amo, nil); err == nil {
+		t.Errorf("checkOffMarketOrder() want error for AMO during market hours")
+	}
+}
+
+func TestEvaluateExposure(t *testing.T) {
+	positions := []OrderPositionBook{
+		{Symbol: "SBIN", Exchange: "NSE", Segment: "E", Product: "C", SecurityID: "3045", NetQty: 80, LastTradedPrice: 100},
+		{Symbol: "INFY", Exchange: "NSE", Segment: "E", Product: "I", SecurityID: "1594", NetQty: -20, LastTradedPrice: 1500},
+	}
+	orders := []BrokerOrder{
+		{OrderBook: OrderBook{Exchange: "NSE", Segment: "E", SecurityID: "3045", TxnType: "B", Status: "Pending", RemainingQuantity: 10, Price: 100}},
+		{OrderBook: OrderBook{Exchange: "NSE", Segment: "E", SecurityID: "3045", TxnType: "S", Status: "Transit", RemainingQuantity: 5}},
+		{OrderBook: OrderBook{Exchange: "NSE", Segment: "E", SecurityID: "3045", TxnType: "B", Status: "Traded", RemainingQuantity: 0}},
+	}
+	order := RiskOrder{Action: RiskPlace, Kind: NormalOrderKind, TxnType: "B", Exchange: "NSE", Segment: "E", ExchangeToken: 3045, Quantity: 10}
+	with := func(update func(*RiskOrder)) RiskOrder {
+		o := order
+		update(&o)
+		return o
+	}
+
+	tests := []struct {
+		name     string
+		order    RiskOrder
+		limits   ExposureLimits
+		wantRule string
+	}{
+		{name: "NoLimits", order: order},
+		{name: "WithinNetQty", order: order, limits: ExposureLimits{MaxNetQty: 100}},
+		{name: "NetQtyWithOpenBuyOrders", order: with(func(o *RiskOrder) { o.Quantity = 11 }), limits: ExposureLimits{MaxNetQty: 100}, wantRule: "maxNetQty"},
+		{name: "ReducingOrderAboveNetQty", order: with(func(o *RiskOrder) { o.TxnType = "S"; o.Quantity = 50 }), limits: ExposureLimits{MaxNetQty: 10}},
+		{name: "ShortBeyondNetQty", order: with(func(o *RiskOrder) { o.TxnType = "S"; o.Quantity = 200 }), limits: ExposureLimits{MaxNetQty: 100}, wantRule: "maxNetQty"},
+		{name: "WithinGrossExposure", order: order, limits: ExposureLimits{MaxGrossExposure: 40500}},
+		{name: "AboveGrossExposure", order: order, limits: ExposureLimits{MaxGrossExposure: 40000}, wantRule: "maxGrossExposure"},
+		{name: "OpenOrdersLimit", order: with(func(o *RiskOrder) { o.TxnType = "S" }), limits: ExposureLimits{MaxOpenOrders: 2}, wantRule: "maxOpenOrders"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := evaluateExposure(tt.order, 100, tt.limits, positions, orders)
+			var rejection *RiskRejection
+			if len(tt.wantRule) == 0 {
+				if err != nil {
+					t.Errorf("evaluateExposure() unexpected error [%v]", err)
+				}
+				return
+			}
+			if !errors.As(err, &rejection) || rejection.Rule != tt.wantRule {
+				t.Errorf("evaluateExposure() = %v, want rule %s", err, tt.wantRule)
+			}
+		})
+	}
+
+	// a leg accepted earlier in the basket counts as an open order for the next leg
+	limits := ExposureLimits{MaxNetQty: 100}
+	basket := append(orders, pendingExposureOrder(order, 100))
+	if err := evaluateExposure(order, 100, limits, positions, basket); err == nil {
+		t.Errorf("evaluateExposure() want second leg above net quantity with the first leg open rejected")
+	}
+}
+
+func TestExposureBooksUnavailable(t *testing.T) {
+	// positionBook fails fast while its circuit is open, the order is not at fault
+	defer func() {
+		circuitBreakers.Lock()
+		delete(circuitBreakers.breakers, PositionBookApi)
+		circuitBreakers.Unlock()
+	}()
+	for i := 0; i < CircuitFailureThreshold; i++ {
+		circuitBreakerFor(PositionBookApi).record(false, time.Now())
+	}
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	servObj := NewTradeGroup(dbmock.NewMockDBLayer(ctrl), invoker, invoker)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	_, _, er := servObj.exposureBooks(ctx)
+	if er == nil || er.Status != http.StatusServiceUnavailable || er.RetryAfter <= 0 {
+		t.Fatalf("exposureBooks() want the 503 of the broker with Retry-After, got [%+v]", er)
+	}
+	var err error = er
+	if status := validationStatus(err); status != http.StatusServiceUnavailable {
+		t.Errorf("validationStatus() want [%d], got [%d]", http.StatusServiceUnavailable, status)
+	}
+	if details := validationDetails(http.StatusServiceUnavailable, err); details.ErrName != e.ErrorInfo["VendorConnectionFailure"].ErrName {
+		t.Errorf("validationDetails() want error of the broker, got [%+v]", details)
+	}
+	setValidationRetryAfter(ctx, err)
+	if recorder.Header().Get("Retry-After") == "" {
+		t.Errorf("setValidationRetryAfter() want Retry-After of the broker")
+	}
+}
+
+func TestOrderEnumValidation(t *testing.T) {
+	order := PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "C", ExchangeToken: 1594, Quantity: 1, Validity: "DAY", OrderType: "MKT"}
+	with := func(update func(*PlaceOrderRequest)) PlaceOrderRequest {
+		o := order
+		update(&o)
+		return o
+	}
+
+	tests := []struct {
+		name    string
+		input   interface{}
+		wantErr bool
+		wantTag string
+	}{
+		{name: "ValidOrder", input: order},
+		{name: "InvalidTxnType", input: with(func(o *PlaceOrderRequest) { o.TxnType = "D" }), wantErr: true, wantTag: "order_txntype"},
+		{name: "InvalidExchange", input: with(func(o *PlaceOrderRequest) { o.Exchange = "NYSE" }), wantErr: true, wantTag: "order_exchange"},
+		{name: "InvalidSegment", input: with(func(o *PlaceOrderRequest) { o.Segment = "A" }), wantErr: true, wantTag: "order_segment"},
+		{name: "InvalidProduct", input: with(func(o *PlaceOrderRequest) { o.Product = "X" }), wantErr: true, wantTag: "order_product"},
+		{name: "InvalidValidity", input: with(func(o *PlaceOrderRequest) { o.Validity = "DA1Y" }), wantErr: true, wantTag: "order_validity"},
+		{name: "InvalidOrderType", input: with(func(o *PlaceOrderRequest) { o.OrderType = "MLLKT" }), wantErr: true, wantTag: "order_ordertype"},
+		{name: "BasketLeg", input: BasketOrderRequest{Legs: []PlaceOrderRequest{order, with(func(o *PlaceOrderRequest) { o.TxnType = "b" })}}, wantErr: true, wantTag: "order_txntype"},
+		{name: "CancelOrderSegment", input: CancelOrderRequest{OrderNo: "1", TxnType: "S", Exchange: "NSE", Segment: "F", Product: "I"}, wantErr: true, wantTag: "order_segment"},
+		{name: "ConvertPositionExchange", input: ConvertPositionRequest{Exchange: "NYSE", ExchangeToken: "1594", Segment: "E", Quantity: 1, PositionFrom: "I", PositionTo: "C"}, wantErr: true, wantTag: "order_exchange"},
+		{name: "SquareOffAllPositions", input: SquareOffRequest{}},
+		{name: "SquareOffFilterIgnoringCase", input: SquareOffRequest{Segment: "e", Product: "c"}},
+		{name: "SquareOffProduct", input: SquareOffRequest{Segment: "E", Product: "X"}, wantErr: true, wantTag: "order_product"},
+		{name: "GTTOrderProduct", input: GTTOrderRequest{TriggerPrice: 100, Order: with(func(o *PlaceOrderRequest) { o.Product = "X" })}, wantErr: true, wantTag: "order_product"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := orderValidator.Struct(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateStruct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+			var fieldErrors validator.ValidationErrors
+			if !errors.As(err, &fieldErrors) || len(fieldErrors) != 1 || fieldErrors[0].Tag() != tt.wantTag {
+				t.Errorf("ValidateStruct() = %v, want single %s error", err, tt.wantTag)
+			}
+			if message := bindingErrorMessage(err); !strings.Contains(message, "must be one of") {
+				t.Errorf("bindingErrorMessage() = %q", message)
+			}
+		})
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	repo := dbmock.NewMockDBLayer(ctrl)
+	servObj := NewTradeGroup(repo, invoker, invoker)
+	invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+	repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+
+	order := PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "C", ExchangeToken: 1594, Quantity: 1, Validity: "DAY", OrderType: "LMT"}
+	tests := []struct {
+		name    string
+		handler func(*trade) gin.HandlerFunc
+		input   interface{}
+		want    []FieldError
+	}{
+		{
+			name:    "BindingEnum",
+			handler: func(s *trade) gin.HandlerFunc { return s.PlaceOrder },
+			input:   PlaceOrderRequest{TxnType: "D", Exchange: "NSE", Segment: "E", Product: "C", ExchangeToken: 1594, Quantity: 1, Validity: "DAY", OrderType: "MKT"},
+			want:    []FieldError{{Field: "txn_type", Code: "order_txntype", Message: "txn_type must be one of B|S", Params: map[string]string{"allowed": "B|S"}}},
+		},
+		{
+			name:    "RiskRule",
+			handler: func(s *trade) gin.HandlerFunc { return s.PlaceOrder },
+			input:   order,
+			want:    []FieldError{{Field: "price", Code: "required", Message: "Price cannot be zero with limit order"}},
+		},
+		{
+			name:    "BasketLeg",
+			handler: func(s *trade) gin.HandlerFunc { return s.PlaceBasketOrder },
+			input:   BasketOrderRequest{Legs: []PlaceOrderRequest{{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "C", ExchangeToken: 1594, Quantity: 1, Validity: "DAY", OrderType: "MKT"}, order}},
+			want:    []FieldError{{Field: "legs[1].price", Code: "required", Message: "Price cannot be zero with limit order"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			byteData, _ := json.Marshal(tt.input)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(byteData))
+			ctx.Request.Header.Set("Content-Type", "application/json")
+			ctx.Set("userId", "TEST2")
+			tt.handler(servObj)(ctx)
+
+			var body struct {
+				Status      bool         `json:"status"`
+				FieldErrors []FieldError `json:"field_errors"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid response body [%s]", recorder.Body.String())
+			}
+			if recorder.Code != http.StatusBadRequest || !reflect.DeepEqual(body.FieldErrors, tt.want) {
+				t.Errorf("%s got [%d] %+v, want %+v", tt.name, recorder.Code, body.FieldErrors, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOrderModification(t *testing.T) {
+	current := BrokerOrder{OrderBook: OrderBook{OrderNo: "1001", Status: "Part-traded", TxnType: "B", Exchange: "NSE", Segment: "E", Product: "I", SecurityID: "3045", Quantity: 100, TradedQty: 40}}
+	request := ModifyOrderRequest{OrderNo: "1001", TxnType: "B", Exchange: "NSE", Segment: "E", Product: "I", ExchangeToken: 3045, Qty: 80, Price: 101, Validity: "DAY", OrderType: "LMT"}
+	with := func(update func(*ModifyOrderRequest)) ModifyOrderRequest {
+		r := request
+		update(&r)
+		return r
+	}
+
+	tests := []struct {
+		name      string
+		current   BrokerOrder
+		request   ModifyOrderRequest
+		wantField string
+		wantCode  string
+	}{
+		{name: "Accepted", current: current, request: request},
+		{name: "Traded", current: BrokerOrder{OrderBook: OrderBook{OrderNo: "1001", Status: "Traded"}}, request: request, wantField: "order_no", wantCode: "notModifiable"},
+		{name: "Cancelled", current: BrokerOrder{OrderBook: OrderBook{OrderNo: "1001", Status: "Cancelled"}}, request: request, wantField: "order_no", wantCode: "notModifiable"},
+		{name: "BelowTradedQty", current: current, request: with(func(r *ModifyOrderRequest) { r.Qty = 30 }), wantField: "qty", wantCode: "minTradedQty"},
+		{name: "EqualTradedQty", current: current, request: with(func(r *ModifyOrderRequest) { r.Qty = 40 }), wantField: "qty", wantCode: "minTradedQty"},
+		{name: "TxnTypeChanged", current: current, request: with(func(r *ModifyOrderRequest) { r.TxnType = "S" }), wantField: "txn_type", wantCode: "immutable"},
+		{name: "ExchangeChanged", current: current, request: with(func(r *ModifyOrderRequest) { r.Exchange = "BSE" }), wantField: "exchange", wantCode: "immutable"},
+		{name: "InstrumentChanged", current: current, request: with(func(r *ModifyOrderRequest) { r.ExchangeToken = 1594 }), wantField: ExchangeTokenField, wantCode: "immutable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOrderModification(tt.current, tt.request)
+			var fe *FieldError
+			if len(tt.wantCode) == 0 {
+				if err != nil {
+					t.Errorf("checkOrderModification() unexpected error [%v]", err)
+				}
+				return
+			}
+			if !errors.As(err, &fe) || fe.Field != tt.wantField || fe.Code != tt.wantCode {
+				t.Errorf("checkOrderModification() = %v, want %s %s", err, tt.wantField, tt.wantCode)
+			}
+		})
+	}
+
+	order := RiskOrder{Action: RiskModify, Kind: NormalOrderKind, TxnType: "B", Exchange: "NSE", Segment: "E", OrderType: "LMT", Quantity: 10, Price: 100, TriggerPrice: 99}
+	engine := newDefaultRiskEngine()
+	engine.loadedAt = time.Now()
+	market := fixedMarketData{instruments: map[string]scrip.MasterSymbol{"E": {LotSize: 1, TickSize: 0.05}}}
+	var rejection *RiskRejection
+	if err := engine.Evaluate(order, market); !errors.As(err, &rejection) || rejection.Rule != "triggerPrice" {
+		t.Errorf("Evaluate() = %v, want trigger price rejected on limit order modification", err)
+	}
+
+	// only the price of a stop loss order is sent, its trigger price is taken from the order in OrderBook
+	stopLoss := BrokerOrder{OrderBook: OrderBook{OrderNo: "1002", Status: "Pending", TxnType: "B", Exchange: "NSE", Segment: "E", Product: "I", SecurityID: "3045", Quantity: 10, OrderType: "SL", Validity: "DAY", Price: 100, TriggerPrice: 99}}
+	merged := mergeOrderModification(stopLoss, ModifyOrderRequest{OrderNo: "1002", Price: 98})
+	if merged.TriggerPrice != 99 || merged.OrderType != SL || merged.Qty != 10 || merged.ExchangeToken != 3045 {
+		t.Fatalf("mergeOrderModification() = %+v", merged)
+	}
+	var fe *FieldError
+	if err := engine.Evaluate(modifyRiskOrder(NormalOrderKind, merged), market); !errors.As(err, &fe) || fe.Code != "lteField" {
+		t.Errorf("Evaluate() = %v, want trigger price above the new limit price rejected", err)
+	}
+}
+
+// fakeBroker serves books from memory, calls it does not override panic
+type fakeBroker struct {
+	Broker
+	orders    []BrokerOrder
+	positions []OrderPositionBook
+}
+
+func (f *fakeBroker) OrderBook(c *gin.Context) ([]BrokerOrder, *BrokerError) {
+	return f.orders, nil
+}
+
+func (f *fakeBroker) PositionBook(c *gin.Context) ([]OrderPositionBook, *BrokerError) {
+	return f.positions, nil
+}
+
+func TestBroker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	repo := dbmock.NewMockDBLayer(ctrl)
+	servObj := NewTradeGroup(repo, invoker, invoker)
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if _, ok := servObj.broker(ctx).(*rupeeseedBroker); !ok {
+		t.Fatalf("broker() want rupeeseed when broker.name is not set, got [%T]", servObj.broker(ctx))
+	}
+
+	fake := &fakeBroker{
+		orders: []BrokerOrder{
+			{OrderBook: OrderBook{OrderNo: "1001", Status: "Pending", Exchange: "NSE", Segment: "E", SecurityID: "3045"}},
+			{OrderBook: OrderBook{OrderNo: "1002", Status: "Traded", Exchange: "NSE", Segment: "D", SecurityID: "35001"}},
+		},
+		positions: []OrderPositionBook{{Symbol: "SBIN", Exchange: "NSE", Segment: "E", SecurityID: "3045", NetQty: 10, BuyAvg: 100, LastTradedPrice: 105}},
+	}
+	rupeeseed := brokerFactories[RupeeseedBroker]
+	registerBroker(RupeeseedBroker, func(s *trade) Broker { return fake })
+	defer registerBroker(RupeeseedBroker, rupeeseed)
+
+	call := func(handler gin.HandlerFunc, query string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		handler(ctx)
+		return recorder
+	}
+
+	// handlers read books of the configured broker, nothing is sent to rupeeseed
+	var orderBook OrderBookResponse
+	recorder := call(servObj.OrderBook, Segment+"=d")
+	_ = json.Unmarshal(recorder.Body.Bytes(), &orderBook)
+	if recorder.Code != http.StatusOK || len(orderBook.Data) != 1 || orderBook.Data[0].OrderNo != "1002" || orderBook.Data[0].StreamSymbol != "35001_NSE" {
+		t.Errorf("OrderBook() want filtered order of broker, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+
+	var positionBook PositionBookResponse
+	recorder = call(servObj.PositionBook, "")
+	_ = json.Unmarshal(recorder.Body.Bytes(), &positionBook)
+	if recorder.Code != http.StatusOK || len(positionBook.Data.OrderPosition) != 1 || positionBook.Data.TotalProfitLoss != 50 {
+		t.Errorf("PositionBook() want position of broker with profit 50, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestMatchingBook(t *testing.T) {
+	book := NewMatchingBook()
+	book.SetPrice("NSE", "3045", 500)
+	market := MatchingOrder{TxnType: BUY, Exchange: "NSE", Segment: "E", Product: "I", SecurityID: "3045", Quantity: 10, Validity: DAY, OrderType: MKT}
+	if row, err := book.Enter("1", market); err != nil || row.Status != Traded || row.TradedPrice != 500 {
+		t.Fatalf("Enter() want market order traded at 500, got [%+v] [%v]", row, err)
+	}
+	limit := market
+	limit.TxnType, limit.OrderType, limit.Price = SELL, LMT, 520
+	if row, err := book.Enter("2", limit); err != nil || row.Status != Pending {
+		t.Fatalf("Enter() want limit order pending, got [%+v] [%v]", row, err)
+	}
+	if _, err := book.Enter("3", MatchingOrder{TxnType: "X", Quantity: 1}); err == nil {
+		t.Errorf("Enter() want invalid transaction type rejected")
+	}
+
+	// limit order trades at the last traded price once it is better than the limit
+	book.SetPrice("NSE", "3045", 521)
+	if positions := book.PositionRows(); len(positions) != 1 || positions[0].NetQty != 0 || positions[0].RealisedProfit != 210 {
+		t.Fatalf("PositionRows() want closed position with profit 210, got [%+v]", positions)
+	}
+
+	// bracket legs are added when the main leg trades, the target cancels the stoploss
+	bracket := market
+	bracket.Product, bracket.Quantity, bracket.ProfitValue, bracket.StoplossValue = "B", 5, 10, 5
+	if _, err := book.Enter("4", bracket); err != nil {
+		t.Fatalf("Enter() want bracket order accepted, got [%v]", err)
+	}
+	book.SetPrice("NSE", "3045", 531)
+	legs := book.OrderRows()[2:]
+	if len(legs) != 3 || legs[0].Status != Traded || legs[1].Status != Traded || legs[1].Price != 531 || legs[2].Status != Cancelled {
+		t.Errorf("OrderRows() want traded main & target legs with cancelled stoploss, got [%+v]", legs)
+	}
+	if _, err := book.Exit("4"); !errors.Is(err, ErrMatchingOrderNotOpen) {
+		t.Errorf("Exit() want closed bracket order not open, got [%v]", err)
+	}
+
+	// converting more than the open position is rejected
+	book.SetPrice("NSE", "3045", 500)
+	if _, err := book.Enter("5", market); err != nil {
+		t.Fatalf("Enter() want market order accepted, got [%v]", err)
+	}
+	if err := book.Convert("NSE", "3045", BUY, "I", "C", 4); err != nil {
+		t.Errorf("Convert() want accepted, got [%v]", err)
+	}
+	if err := book.Convert("NSE", "3045", BUY, "I", "C", 20); err == nil {
+		t.Errorf("Convert() want rejection above open quantity")
+	}
+	held := map[string]int{}
+	for _, position := range book.PositionRows() {
+		held[position.Product] = position.NetQty
+	}
+	if held["I"] != 6 || held["C"] != 4 || held["B"] != 0 {
+		t.Errorf("PositionRows() want 6 intraday & 4 delivery, got [%v]", held)
+	}
+}
+
+func TestRupeeseedReadRetry(t *testing.T) {
+	defer func(policy retryPolicy) { readRetryPolicy = policy }(readRetryPolicy)
+	readRetryPolicy.backoff = time.Millisecond
+
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	servObj := NewTradeGroup(dbmock.NewMockDBLayer(ctrl), invoker, invoker)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderBookApi
+	book, _ := json.Marshal(RupeeseedOrderBookResponse{Status: Success, Data: []RupeeseedOrderBook{{OrderNo: "1001", Status: Pending}}})
+
+	// OrderBook is retried past a single bad gateway
+	gomock.InOrder(
+		invoker.EXPECT().InvokeHttp(http.MethodPost, uri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(nil, http.StatusBadGateway, nil),
+		invoker.EXPECT().InvokeHttp(http.MethodPost, uri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(book, http.StatusOK, nil),
+	)
+	if orders, er := servObj.broker(ctx).OrderBook(ctx); er != nil || len(orders) != 1 {
+		t.Errorf("OrderBook() want bad gateway retried, got [%+v] [%v]", orders, er)
+	}
+
+	// and fails when every attempt is a bad gateway
+	invoker.EXPECT().InvokeHttp(http.MethodPost, uri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(nil, http.StatusBadGateway, nil).Times(readRetryPolicy.attempts)
+	if _, er := servObj.broker(ctx).OrderBook(ctx); er == nil {
+		t.Errorf("OrderBook() want failure when every attempt is a bad gateway")
+	}
+
+	// every attempt is decoded afresh, nothing of a failed attempt is left in the reply
+	rupeeseedErrorCodes["RS-0503"] = rupeeseedErrorCode{Error: "InternalServerError", Status: http.StatusServiceUnavailable, Retryable: true}
+	defer delete(rupeeseedErrorCodes, "RS-0503")
+	busy, _ := json.Marshal(RupeeseedOrderBookResponse{Status: "error", ErrorCode: "RS-0503", Message: "SYSTEM BUSY", Data: []RupeeseedOrderBook{{OrderNo: "1001"}}})
+	gomock.InOrder(
+		invoker.EXPECT().InvokeHttp(http.MethodPost, uri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(busy, http.StatusOK, nil),
+		invoker.EXPECT().InvokeHttp(http.MethodPost, uri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return([]byte(`{"status":"success"}`), http.StatusOK, nil),
+	)
+	var obj RupeeseedOrderBookResponse
+	request := rupeeseedRequest{api: OrderBookApi, body: getOrderBookRupeeseedRequestBody(ctx), retry: readRetryPolicy}
+	er := (&rupeeseedBroker{rest: invoker}).call(request, &obj, func() (string, string, string) { return obj.Status, obj.ErrorCode, obj.Message })
+	if er != nil || len(obj.Message) != 0 || len(obj.ErrorCode) != 0 || len(obj.Data) != 0 {
+		t.Errorf("call() want reply of the last attempt only, got [%+v] [%v]", obj, er)
+	}
+}
+
+func TestPaperTrading(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	repo := dbmock.NewMockDBLayer(ctrl)
+	servObj := NewTradeGroup(repo, invoker, invoker)
+
+	// only the live books are read from rupeeseed when paper trading is switched on, orders of a paper user never reach it
+	livePositions := []RupeeSeedPositionBook{}
+	liveOrders, _ := json.Marshal(RupeeseedOrderBookResponse{Status: "success"})
+	invoker.EXPECT().InvokeHttp(http.MethodPost, config.GetConfig().GetString("rupeeseed.endpoint")+OrderBookApi, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(liveOrders, http.StatusOK, nil).Times(2)
+	invoker.EXPECT().InvokeResty(http.MethodPost, config.GetConfig().GetString("rupeeseed.endpoint")+PositionBookApi, gomock.Any(), nil, 700).DoAndReturn(func(method, uri string, body interface{}, headers map[string]string, timeout int) ([]byte, int, error) {
+		byteData, _ := json.Marshal(RupeeseedPositionBookResponse{Status: "success", Data: livePositions})
+		return byteData, http.StatusOK, nil
+	}).Times(2)
+	store := make(map[string]string)
+	invoker.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(key string, value interface{}, expiration time.Duration) error {
+		store[key] = value.(string)
+		return nil
+	}).AnyTimes()
+	invoker.EXPECT().Get(gomock.Any()).DoAndReturn(func(key string) (string, error) {
+		if key == PaperTradingKeyPrefix+"DOWN" {
+			return "", errors.New("connection refused")
+		}
+		return store[key], nil
+	}).AnyTimes()
+	ltp := 200.0
+	repo.EXPECT().GetSymbolTickData("3045", "NSE").DoAndReturn(func(token, exchange string) (scrip.SymbolTickData, error) {
+		return scrip.SymbolTickData{LastTradedPrice: ltp}, nil
+	}).AnyTimes()
+	// books saved by version as the db does, conflicts are saves another instance makes in between
+	books := make(map[string]paper.Book)
+	conflicts := 0
+	repo.EXPECT().GetPaperBook(gomock.Any()).DoAndReturn(func(userId string) (paper.Book, error) {
+		return books[userId], nil
+	}).AnyTimes()
+	repo.EXPECT().SavePaperBookIfVersion(gomock.Any(), gomock.Any()).DoAndReturn(func(book paper.Book, version int64) (bool, error) {
+		if conflicts > 0 {
+			conflicts--
+			stored := books[book.UserID]
+			stored.Version++
+			books[book.UserID] = stored
+			return false, nil
+		}
+		if books[book.UserID].Version != version {
+			return false, nil
+		}
+		books[book.UserID] = book
+		return true, nil
+	}).AnyTimes()
+
+	call := func(handler gin.HandlerFunc, userId string, input interface{}) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		byteData, _ := json.Marshal(input)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(byteData))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		ctx.Set("userId", userId)
+		handler(ctx)
+		return recorder
+	}
+	enabled := true
+	if recorder := call(servObj.SetPaperTrading, "PAPER1", PaperTradingRequest{Enabled: &enabled}); recorder.Code != http.StatusOK {
+		t.Fatalf("SetPaperTrading() want 200, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+	// a user with a live position is kept on the live broker
+	livePositions = []RupeeSeedPositionBook{{Symbol: "SBIN", Exchange: "NSE", Segment: "E", Product: "I", SecurityID: "3045", NetQty: 5}}
+	if recorder := call(servObj.SetPaperTrading, "LIVE1", PaperTradingRequest{Enabled: &enabled}); recorder.Code != http.StatusConflict {
+		t.Errorf("SetPaperTrading() want 409 with an open live position, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+	if _, ok := servObj.broker(userContext("LIVE1")).(*paperBroker); ok {
+		t.Errorf("SetPaperTrading() want refused user trading live")
+	}
+	var status PaperTradingResponse
+	recorder := call(servObj.PaperTradingStatus, "PAPER1", nil)
+	_ = json.Unmarshal(recorder.Body.Bytes(), &status)
+	if !status.Data.Enabled {
+		t.Errorf("PaperTradingStatus() want enabled, got [%s]", recorder.Body.String())
+	}
+
+	ctx := userContext("PAPER1")
+	broker := servObj.broker(ctx)
+	if _, ok := broker.(*paperBroker); !ok {
+		t.Fatalf("broker() want paper broker for paper user, got [%T]", broker)
+	}
+	if _, ok := servObj.broker(userContext("DOWN")).(unavailableBroker); !ok {
+		t.Errorf("broker() want orders refused when trading mode cannot be read")
+	}
+	if _, er := servObj.broker(userContext("DOWN")).OrderBook(userContext("DOWN")); er == nil || brokerErrorStatus(er) != http.StatusServiceUnavailable || er.Err.ErrName != TradingModeUnavailableError {
+		t.Errorf("OrderBook() want 503 when trading mode cannot be read, got [%v]", er)
+	}
+
+	market := PlaceOrderRequest{TxnType: BUY, Exchange: "NSE", Segment: "E", Product: "I", ExchangeToken: 3045, Quantity: 10, Validity: DAY, OrderType: MKT}
+	if ack, er := broker.PlaceOrder(ctx, market); er != nil || fmt.Sprint(ack.OrderNos) != "[PAPER1-1]" {
+		t.Fatalf("PlaceOrder() want market order PAPER1-1 filled, got [%+v] [%v]", ack, er)
+	}
+	// a book saved by another instance in between is read again & the order placed on it
+	conflicts = 1
+	limit := market
+	limit.TxnType, limit.OrderType, limit.Price = SELL, LMT, 210
+	if ack, er := broker.PlaceOrder(ctx, limit); er != nil || fmt.Sprint(ack.OrderNos) != "[PAPER1-2]" {
+		t.Fatalf("PlaceOrder() want limit order PAPER1-2 accepted after conflict, got [%+v] [%v]", ack, er)
+	}
+	conflicts = paperBookSaveAttempts
+	if _, er := broker.PlaceOrder(ctx, limit); er == nil || er.Status != http.StatusConflict || er.Err.ErrName != e.ErrorInfo["BadRequest"].ErrName {
+		t.Errorf("PlaceOrder() want 409 when the book keeps changing concurrently, got [%v]", er)
+	}
+	if stored := books["PAPER1"]; stored.LastOrderNo != 2 {
+		t.Errorf("GetPaperBook() want order numbers counted in the saved book, got [%d]", stored.LastOrderNo)
+	}
+
+	// pending limit order fills as the last traded price of tick data reaches it
+	var positionBook PositionBookResponse
+	recorder = call(servObj.PositionBook, "PAPER1", nil)
+	_ = json.Unmarshal(recorder.Body.Bytes(), &positionBook)
+	if recorder.Code != http.StatusOK || len(positionBook.Data.OrderPosition) != 1 || positionBook.Data.OrderPosition[0].NetQty != 10 {
+		t.Fatalf("PositionBook() want open paper position of 10, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+	ltp = 212
+	positionBook = PositionBookResponse{}
+	recorder = call(servObj.PositionBook, "PAPER1", nil)
+	_ = json.Unmarshal(recorder.Body.Bytes(), &positionBook)
+	if len(positionBook.Data.OrderPosition) != 1 || positionBook.Data.OrderPosition[0].NetQty != 0 || positionBook.Data.OrderPosition[0].RealisedProfit != 120 {
+		t.Errorf("PositionBook() want closed paper position with profit 120, got [%s]", recorder.Body.String())
+	}
+
+	var orderBook OrderBookResponse
+	recorder = call(servObj.OrderBook, "PAPER1", nil)
+	_ = json.Unmarshal(recorder.Body.Bytes(), &orderBook)
+	if recorder.Code != http.StatusOK || len(orderBook.Data) != 2 || orderBook.Data[0].Status != Traded || orderBook.Data[0].TxnType != SELL {
+		t.Errorf("OrderBook() want traded paper orders latest first, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+	if orders, _ := broker.OrderBook(userContext("PAPER2")); len(orders) != 0 {
+		t.Errorf("OrderBook() want paper books kept per user, got [%+v]", orders)
+	}
+	if ack, er := broker.PlaceOrder(userContext("PAPER2"), market); er != nil || fmt.Sprint(ack.OrderNos) != "[PAPER2-1]" {
+		t.Errorf("PlaceOrder() want order numbers of another user not colliding, got [%+v] [%v]", ack, er)
+	}
+}
+
+func TestRupeeseedFailure(t *testing.T) {
+	tests := []struct {
+		name          string
+		errCode       string
+		message       string
+		wantErr       string
+		wantStatus    int
+		wantMessage   string
+		wantRetryable bool
+		wantCounted   bool
+	}{
+		{name: "CatalogueCode", errCode: "RS-0023", message: "SCRIP IS BLOCKED", wantErr: "BadRequest", wantStatus: http.StatusBadRequest, wantMessage: ":SCRIP IS BLOCKED"},
+		{name: "OMSError", errCode: "RS-0022", message: "ORDER NOT PROCESSED", wantErr: "VendorOMSError", wantStatus: http.StatusBadGateway, wantMessage: rupeeseedErrorCodes["RS-0022"].Message},
+		{name: "RetryableCode", errCode: "RS-0503", message: "SYSTEM BUSY", wantErr: "InternalServerError", wantStatus: http.StatusServiceUnavailable, wantMessage: ":SYSTEM BUSY", wantRetryable: true},
+		{name: "UnknownCode", errCode: "RS-9999", message: "SOMETHING NEW", wantErr: "VendorApiFailure", wantStatus: http.StatusBadGateway, wantMessage: ":SOMETHING NEW", wantCounted: true},
+	}
+	// code of a degraded vendor as an entry of the catalogue
+	rupeeseedErrorCodes["RS-0503"] = rupeeseedErrorCode{Error: "InternalServerError", Status: http.StatusServiceUnavailable, Retryable: true}
+	defer delete(rupeeseedErrorCodes, "RS-0503")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := VendorUnknownErrorCode + ":" + OrderApi + ":" + test.errCode
+			vendorAnomalies.Lock()
+			before := vendorAnomalies.counts[key]
+			vendorAnomalies.Unlock()
+
+			er, retryable := rupeeseedFailure(OrderApi, test.errCode, test.message)
+			if er.Err.ErrName != e.ErrorInfo[test.wantErr].ErrName || !strings.HasSuffix(er.Err.Message, test.wantMessage) || retryable != test.wantRetryable {
+				t.Errorf("rupeeseedFailure() want [%s] with message [%s], got [%+v] retryable [%v]", test.wantErr, test.wantMessage, er, retryable)
+			}
+			if status := brokerErrorStatus(er); status != test.wantStatus {
+				t.Errorf("brokerErrorStatus() want [%d], got [%d]", test.wantStatus, status)
+			}
+			vendorAnomalies.Lock()
+			counted := vendorAnomalies.counts[key] - before
+			vendorAnomalies.Unlock()
+			if (counted == 1) != test.wantCounted {
+				t.Errorf("rupeeseedFailure() want counted [%v], got [%d]", test.wantCounted, counted)
+			}
+			fmt.Println("Test case passed :", test.name)
+		})
+	}
+
+	// replies that cannot be decoded are counted & listed by VendorAnomalies
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	servObj := NewTradeGroup(dbmock.NewMockDBLayer(ctrl), invoker, invoker)
+	uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderBookApi
+	invoker.EXPECT().InvokeHttp(http.MethodPost, uri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return([]byte("<html>Service Unavailable</html>"), http.StatusOK, nil).Times(1)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if _, er := servObj.broker(ctx).OrderBook(ctx); er == nil || er.Err.ErrName != e.ErrorInfo["JsonUnmarshalError"].ErrName {
+		t.Errorf("OrderBook() want JsonUnmarshalError on html reply, got [%v]", er)
+	}
+
+	recorder := httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(recorder)
+	servObj.VendorAnomalies(ctx)
+	var response VendorAnomaliesResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	counts := make(map[string]int64)
+	for _, anomaly := range response.Data {
+		counts[anomaly.Key] = anomaly.Count
+	}
+	if counts[VendorUnexpectedBody+":"+OrderBookApi+":"] == 0 || counts[VendorUnknownErrorCode+":"+OrderApi+":RS-9999"] == 0 {
+		t.Errorf("VendorAnomalies() want unexpected body & unknown code counted, got [%s]", recorder.Body.String())
+	}
+}
+
+func TestRupeeseedErrorCatalogue(t *testing.T) {
+	for errCode, status := range e.RupeeseedErrors {
+		if _, ok := rupeeseedErrorCodes[errCode]; !ok {
+			t.Errorf("rupeeseedErrorCodes missing %s of RupeeseedErrors with status [%d]", errCode, status)
+		}
+	}
+	for errCode, code := range rupeeseedErrorCodes {
+		if _, ok := e.ErrorInfo[code.Error]; !ok || code.Status < http.StatusBadRequest {
+			t.Errorf("rupeeseedErrorCodes %s want ErrorInfo key & error status, got [%+v]", errCode, code)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, time.March, 12, 10, 30, 0, 0, istLocation)
+	breaker := &circuitBreaker{endpoint: "test", state: CircuitClosed}
+	for i := 0; i < CircuitFailureThreshold; i++ {
+		if !breaker.allow(now) {
+			t.Fatalf("allow() want calls let through before %d failures, refused at [%d]", CircuitFailureThreshold, i)
+		}
+		breaker.record(false, now)
+	}
+	if breaker.allow(now.Add(CircuitOpenDuration - time.Second)) {
+		t.Errorf("allow() want open circuit to fail fast")
+	}
+
+	// a single probe is let through once the circuit has been open long enough
+	probeAt := now.Add(CircuitOpenDuration)
+	if !breaker.allow(probeAt) || breaker.allow(probeAt) {
+		t.Errorf("allow() want a single probe of half open circuit")
+	}
+	if status := breaker.status(); status.State != CircuitHalfOpen || !status.Probing {
+		t.Errorf("status() want half open circuit probing, got [%+v]", status)
+	}
+	breaker.record(false, probeAt)
+	if breaker.allow(probeAt.Add(time.Second)) {
+		t.Errorf("allow() want failed probe to open the circuit again")
+	}
+	probeAt = probeAt.Add(CircuitOpenDuration)
+	if !breaker.allow(probeAt) {
+		t.Fatalf("allow() want probe after circuit reopened")
+	}
+	breaker.record(true, probeAt)
+	if status := breaker.status(); status.State != CircuitClosed || status.Failures != 0 || !breaker.allow(probeAt) {
+		t.Errorf("record() want successful probe to close the circuit, got [%+v]", status)
+	}
+
+	// order placement is sent once and fails fast while the circuit is open
+	defer func() {
+		circuitBreakers.Lock()
+		delete(circuitBreakers.breakers, OrderApi)
+		circuitBreakers.Unlock()
+	}()
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	servObj := NewTradeGroup(dbmock.NewMockDBLayer(ctrl), invoker, invoker)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	order := PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "I", ExchangeToken: 3045, Quantity: 10, Validity: "DAY", OrderType: "MKT"}
+	uri := config.GetConfig().GetString("rupeeseed.endpoint") + OrderApi
+	invoker.EXPECT().InvokeHttp(http.MethodPost, uri, gomock.Any(), rupeeseedHeaders, ApiTimeout).Return(nil, 0, errors.New("i/o timeout")).Times(1)
+	if _, er := servObj.broker(ctx).PlaceOrder(ctx, order); er == nil {
+		t.Errorf("PlaceOrder() want failure on timeout")
+	}
+	for i := 1; i < CircuitFailureThreshold; i++ {
+		circuitBreakerFor(OrderApi).record(false, time.Now())
+	}
+	if _, er := servObj.broker(ctx).PlaceOrder(ctx, order); er == nil || er.Err.ErrName != e.ErrorInfo["VendorConnectionFailure"].ErrName || er.Status != http.StatusServiceUnavailable || er.RetryAfter <= 0 {
+		t.Errorf("PlaceOrder() want 503 with Retry-After without calling rupeeseed, got [%+v]", er)
+	}
+	opened := &circuitBreaker{endpoint: "test", state: CircuitOpen, openedAt: now}
+	if retryAfter := opened.retryAfter(now.Add(CircuitOpenDuration - 10*time.Second)); retryAfter != 10*time.Second {
+		t.Errorf("retryAfter() want time left of the open circuit, got [%v]", retryAfter)
+	}
+
+	recorder := httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(recorder)
+	servObj.CircuitBreakers(ctx)
+	var response CircuitBreakersResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	reported := false
+	for _, status := range response.Data {
+		if status.Endpoint == OrderApi {
+			reported = status.State == CircuitOpen && status.Failures == CircuitFailureThreshold && status.OpenedAt != nil
+		}
+	}
+	if !reported {
+		t.Errorf("CircuitBreakers() want open circuit of OrderApi, got [%s]", recorder.Body.String())
+	}
+}
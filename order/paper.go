@@ -0,0 +1,529 @@
+package trade
+
+import (
+	"encoding/json"
+	db "equity-trading/pkg/db"
+	"equity-trading/pkg/db/paper"
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const PaperBroker = "paper"
+
+// times a call of a paper user is run on a book saved concurrently by another call before it fails
+const paperBookSaveAttempts = 3
+
+// error name of calls refused as the trading mode of the user could not be read
+const TradingModeUnavailableError = "TradingModeUnavailable"
+
+func init() {
+	registerBroker(PaperBroker, func(s *trade) Broker { return &paperBroker{dbObj: s.dbObj} })
+}
+
+/*
+book of a paper trading user as read from the db, order numbers are the
+user id followed by a number counted in the book so they never collide
+across users or instances
+*/
+type paperBook struct {
+	*MatchingBook
+	userId      string
+	lastOrderNo int64
+}
+
+func (b *paperBook) nextOrderNo() string {
+	b.lastOrderNo++
+	return fmt.Sprintf("%s-%d", b.userId, b.lastOrderNo)
+}
+
+/*
+switches paper trading of the user on or off, orders of a paper user
+are filled at last traded prices on a book kept in the db and never
+reach rupeeseed, the flag is stored in redis so every instance routes
+the user the same way, switching on is refused with 409 while the user
+has open orders or positions with the live broker
+*/
+func (s *trade) SetPaperTrading(c *gin.Context) {
+	var (
+		request  PaperTradingRequest
+		response PaperTradingResponse
+	)
+	//  validating the request payload via gin framework
+	if err := bindOrderJSON(c, &request); err != nil {
+		logger.Log.Error("Invalid arguement received for paper trading", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+
+	paper := PaperTrading{
+		UserID:    c.GetString("userId"),
+		Enabled:   *request.Enabled,
+		UpdatedAt: time.Now(),
+	}
+	// orders & positions at the broker would be out of reach of the user once calls go to the paper book
+	if paper.Enabled {
+		reason, er := s.liveTradingOpen(c)
+		if er != nil {
+			response.Errors = append(response.Errors, er.Err)
+			setRetryAfter(c, er)
+			c.JSON(brokerErrorStatus(er), response)
+			c.Abort()
+			return
+		}
+		if len(reason) != 0 {
+			logger.Log.Info("paper trading refused", zap.String("userId", paper.UserID), zap.String("reason", reason))
+			response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(reason))
+			c.JSON(http.StatusConflict, response)
+			c.Abort()
+			return
+		}
+	}
+	byteData, err := json.Marshal(paper)
+	if err != nil {
+		logger.Log.Error("Failed to marshal paper trading", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+	if err := s.redisCaller.Set(PaperTradingKeyPrefix+paper.UserID, string(byteData), 0); err != nil {
+		logger.Log.Error("Failed to store paper trading", zap.Error(err), zap.String("userId", paper.UserID))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+	logger.Log.Info("paper trading updated", zap.String("userId", paper.UserID), zap.Bool("enabled", paper.Enabled))
+
+	response.Data = paper
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+/*
+reason paper trading cannot be switched on while the user has open
+orders or positions with the live broker, empty if there are none
+*/
+func (s *trade) liveTradingOpen(c *gin.Context) (string, *BrokerError) {
+	broker := s.liveBroker()
+	orders, er := broker.OrderBook(c)
+	if er != nil {
+		logger.Log.Error("paper trading: failed to fetch orderBook", zap.Error(er))
+		return "", er
+	}
+	for _, order := range orders {
+		if orderStatus, _ := ParseOrderStatus(order.Status); orderStatus.Section() == Open {
+			return ":open orders with the broker must be cancelled before paper trading", nil
+		}
+	}
+	positions, er := broker.PositionBook(c)
+	if er != nil {
+		logger.Log.Error("paper trading: failed to fetch positionBook", zap.Error(er))
+		return "", er
+	}
+	for _, position := range positions {
+		if position.NetQty != 0 {
+			return ":open positions with the broker must be squared off before paper trading", nil
+		}
+	}
+	return "", nil
+}
+
+// returns whether orders of the user are paper traded
+func (s *trade) PaperTradingStatus(c *gin.Context) {
+	var response PaperTradingResponse
+	userId := c.GetString("userId")
+	enabled, err := s.paperTrading(userId)
+	if err != nil {
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+	response.Data = PaperTrading{UserID: userId, Enabled: enabled}
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+// paper trading flag of the user, users without the flag trade live
+func (s *trade) paperTrading(userId string) (bool, error) {
+	value, err := s.redisCaller.Get(PaperTradingKeyPrefix + userId)
+	if err != nil {
+		logger.Log.Error("paper trading: failed to read redis", zap.Error(err), zap.String("userId", userId))
+		return false, err
+	}
+	if len(value) == 0 {
+		return false, nil
+	}
+	var paper PaperTrading
+	if err := json.Unmarshal([]byte(value), &paper); err != nil {
+		logger.Log.Error("paper trading: failed to unmarshal", zap.Error(err), zap.String("userId", userId))
+		return false, err
+	}
+	return paper.Enabled, nil
+}
+
+/*
+paperBroker fills orders of the user against last traded prices of
+GetSymbolTickData with the matching model of MatchingBook, prices of
+instruments held are refreshed on every call so pending orders fill
+and positions are marked as the market moves, books are kept in the db
+so any instance serves the user
+*/
+type paperBroker struct {
+	dbObj db.DBLayer
+}
+
+func (p *paperBroker) PlaceOrder(c *gin.Context, request PlaceOrderRequest) (OrderAck, *BrokerError) {
+	return p.enter(c, MatchingOrder{
+		TxnType:      request.TxnType,
+		Exchange:     request.Exchange,
+		Segment:      request.Segment,
+		Product:      request.Product,
+		SecurityID:   strconv.Itoa(request.ExchangeToken),
+		Quantity:     request.Quantity,
+		Price:        request.Price,
+		TriggerPrice: request.TriggerPrice,
+		OrderType:    request.OrderType,
+		Validity:     request.Validity,
+	})
+}
+
+func (p *paperBroker) PlaceBracketOrder(c *gin.Context, request PlaceBracketOrderRequest) (OrderAck, *BrokerError) {
+	return p.enter(c, MatchingOrder{
+		TxnType:       request.TxnType,
+		Exchange:      request.Exchange,
+		Segment:       request.Segment,
+		Product:       request.Product,
+		SecurityID:    strconv.Itoa(request.ExchangeToken),
+		Quantity:      request.Quantity,
+		Price:         request.Price,
+		OrderType:     request.OrderType,
+		Validity:      request.Validity,
+		ProfitValue:   request.ProfitValue,
+		StoplossValue: request.StoplossValue,
+	})
+}
+
+func (p *paperBroker) PlaceCoverOrder(c *gin.Context, request PlaceCoverOrderRequest) (OrderAck, *BrokerError) {
+	return p.enter(c, MatchingOrder{
+		TxnType:      request.TxnType,
+		Exchange:     request.Exchange,
+		Segment:      request.Segment,
+		Product:      request.Product,
+		SecurityID:   strconv.Itoa(request.ExchangeToken),
+		Quantity:     request.Quantity,
+		Price:        request.Price,
+		OrderType:    request.OrderType,
+		Validity:     request.Validity,
+		CoverTrigger: request.TriggerPrice,
+	})
+}
+
+// accepts an order priced at the last traded price of its instrument
+func (p *paperBroker) enter(c *gin.Context, order MatchingOrder) (OrderAck, *BrokerError) {
+	ltp, er := p.lastTradedPrice(order.Exchange, order.SecurityID)
+	if er != nil {
+		return OrderAck{}, er
+	}
+	return p.ack(c, "Order submitted successfully", func(book *paperBook) (RupeeseedOrderBook, error) {
+		book.SetPrice(order.Exchange, order.SecurityID, ltp)
+		return book.Enter(book.nextOrderNo(), order)
+	})
+}
+
+func (p *paperBroker) ModifyOrder(c *gin.Context, kind string, request ModifyOrderRequest) (OrderAck, *BrokerError) {
+	return p.ack(c, "Order modified successfully", func(book *paperBook) (RupeeseedOrderBook, error) {
+		p.refresh(book.MatchingBook)
+		return book.Modify(request.OrderNo, request.SerialNo, request.Qty, request.Price, request.TriggerPrice, request.OrderType, request.Validity)
+	})
+}
+
+func (p *paperBroker) CancelOrder(c *gin.Context, request CancelOrderRequest) (OrderAck, *BrokerError) {
+	return p.ack(c, "Order cancelled successfully", func(book *paperBook) (RupeeseedOrderBook, error) {
+		return book.Cancel(request.OrderNo, request.SerialNo)
+	})
+}
+
+func (p *paperBroker) ExitOrder(c *gin.Context, kind string, request CancelOrderRequest) (OrderAck, *BrokerError) {
+	return p.ack(c, "Order exited successfully", func(book *paperBook) (RupeeseedOrderBook, error) {
+		p.refresh(book.MatchingBook)
+		return book.Exit(request.OrderNo)
+	})
+}
+
+func (p *paperBroker) OrderBook(c *gin.Context) ([]BrokerOrder, *BrokerError) {
+	rows, er := p.orderRows(c, true)
+	if er != nil {
+		return nil, er
+	}
+	// latest order first as in OrderBook of rupeeseed
+	orders := make([]BrokerOrder, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		orders = append(orders, brokerOrder(rows[i]))
+	}
+	return orders, nil
+}
+
+// every leg of the order at its current state, paper orders keep no history of changes
+func (p *paperBroker) OrderHistory(c *gin.Context, orderNo string) ([]BrokerOrderEvent, *BrokerError) {
+	rows, er := p.orderRows(c, false)
+	if er != nil {
+		return nil, er
+	}
+	events := make([]BrokerOrderEvent, 0)
+	for _, row := range rows {
+		if row.OrderNo != orderNo {
+			continue
+		}
+		events = append(events, BrokerOrderEvent{
+			OrderNo:           row.OrderNo,
+			SerialNo:          row.SerialNo,
+			Status:            row.Status,
+			Quantity:          row.Quantity,
+			TradedQty:         row.TradedQty,
+			RemainingQuantity: row.RemainingQuantity,
+			Price:             row.Price,
+			TriggerPrice:      row.TriggerPrice,
+			ErrorCode:         row.ErrorCode,
+			LastUpdatedTime:   row.LastUpdatedTime,
+		})
+	}
+	return events, nil
+}
+
+// a fill per traded order, paper orders trade in full at a single price
+func (p *paperBroker) TradeBook(c *gin.Context) ([]BrokerTrade, *BrokerError) {
+	rows, er := p.orderRows(c, true)
+	if er != nil {
+		return nil, er
+	}
+	trades := make([]BrokerTrade, 0)
+	for _, row := range rows {
+		if row.TradedQty == 0 {
+			continue
+		}
+		var fill BrokerTrade
+		fill.OrderNo = row.OrderNo
+		fill.ExchTradeID = fmt.Sprintf("%s-%d", row.OrderNo, row.SerialNo)
+		fill.FillQty = row.TradedQty
+		fill.FillPrice = row.TradedPrice
+		fill.TradeTime = row.LastUpdatedTime
+		fill.Symbol = row.Symbol
+		fill.DisplayName = row.DisplayName
+		fill.Exchange = row.Exchange
+		fill.Segment = row.Segment
+		fill.SecurityID = row.SecurityID
+		fill.TxnType = row.TxnType
+		fill.Product = row.Product
+		trades = append(trades, fill)
+	}
+	return trades, nil
+}
+
+func (p *paperBroker) PositionBook(c *gin.Context) ([]OrderPositionBook, *BrokerError) {
+	var rows []RupeeSeedPositionBook
+	er := p.update(c.GetString("userId"), func(book *paperBook) {
+		p.refresh(book.MatchingBook)
+		rows = book.PositionRows()
+	})
+	if er != nil {
+		return nil, er
+	}
+	positions := make([]OrderPositionBook, 0, len(rows))
+	for _, row := range rows {
+		positions = append(positions, brokerPosition(row))
+	}
+	return positions, nil
+}
+
+// converting more than the open quantity is VendorOMSError as with rupeeseed, replied as a bad request
+func (p *paperBroker) ConvertPosition(c *gin.Context, request ConvertPositionRequest) (OrderAck, *BrokerError) {
+	var err error
+	er := p.update(c.GetString("userId"), func(book *paperBook) {
+		err = book.Convert(request.Exchange, request.ExchangeToken, request.PositionType, request.PositionFrom, request.PositionTo, request.Quantity)
+	})
+	if er != nil {
+		return OrderAck{}, er
+	}
+	if err != nil {
+		return OrderAck{Message: err.Error()}, brokerError(http.StatusBadRequest, e.ErrorInfo["VendorOMSError"].GetErrorDetails(""))
+	}
+	return OrderAck{Message: "Position converted successfully"}, nil
+}
+
+// runs an order entry on the book of the user, rejections of the matching model are BadRequest
+func (p *paperBroker) ack(c *gin.Context, message string, entry func(book *paperBook) (RupeeseedOrderBook, error)) (OrderAck, *BrokerError) {
+	var (
+		row RupeeseedOrderBook
+		err error
+	)
+	if er := p.update(c.GetString("userId"), func(book *paperBook) { row, err = entry(book) }); er != nil {
+		return OrderAck{}, er
+	}
+	if err != nil {
+		return OrderAck{Message: err.Error()}, brokerError(http.StatusBadRequest, e.ErrorInfo["BadRequest"].GetErrorDetails(":"+err.Error()))
+	}
+	return OrderAck{
+		OrderNos: []string{row.OrderNo},
+		Message:  message + ". Your Order Ref No. " + row.OrderNo,
+		Data:     []paperOrderNo{{OrderNo: row.OrderNo}},
+	}, nil
+}
+
+// OrderBook rows of the user in the order placed, refreshed to current prices if asked
+func (p *paperBroker) orderRows(c *gin.Context, refresh bool) ([]RupeeseedOrderBook, *BrokerError) {
+	var rows []RupeeseedOrderBook
+	er := p.update(c.GetString("userId"), func(book *paperBook) {
+		if refresh {
+			p.refresh(book.MatchingBook)
+		}
+		rows = book.OrderRows()
+	})
+	return rows, er
+}
+
+/*
+runs fn on the book of the user read from the db & saves the book if fn
+changed it, a user without a book starts with an empty one, the book is
+saved only if no other call saved it since it was read, fn is run again
+on the saved book otherwise
+*/
+func (p *paperBroker) update(userId string, fn func(book *paperBook)) *BrokerError {
+	for attempt := 1; ; attempt++ {
+		stored, err := p.dbObj.GetPaperBook(userId)
+		if err != nil {
+			logger.Log.Error("paper trading: failed to read book", zap.Error(err), zap.String("userId", userId))
+			return brokerError(http.StatusInternalServerError, e.ErrorInfo["InternalServerError"].GetErrorDetails(":unable to read paper book"))
+		}
+		book := &paperBook{MatchingBook: NewMatchingBook(), userId: userId, lastOrderNo: stored.LastOrderNo}
+		if len(stored.Book) != 0 {
+			if err := json.Unmarshal([]byte(stored.Book), book.MatchingBook); err != nil {
+				logger.Log.Error("paper trading: failed to unmarshal book", zap.Error(err), zap.String("userId", userId))
+				return brokerError(http.StatusInternalServerError, e.ErrorInfo["InternalServerError"].GetErrorDetails(":unable to read paper book"))
+			}
+		}
+		before, _ := json.Marshal(book.MatchingBook)
+
+		fn(book)
+		after, err := json.Marshal(book.MatchingBook)
+		if err != nil {
+			logger.Log.Error("paper trading: failed to marshal book", zap.Error(err), zap.String("userId", userId))
+			return brokerError(http.StatusInternalServerError, e.ErrorInfo["InternalServerError"].GetErrorDetails(":unable to save paper book"))
+		}
+		if string(after) == string(before) && book.lastOrderNo == stored.LastOrderNo {
+			return nil
+		}
+
+		saved, err := p.dbObj.SavePaperBookIfVersion(paper.Book{
+			UserID:      userId,
+			Version:     stored.Version + 1,
+			LastOrderNo: book.lastOrderNo,
+			Book:        string(after),
+			UpdatedAt:   time.Now(),
+		}, stored.Version)
+		if err != nil {
+			logger.Log.Error("paper trading: failed to save book", zap.Error(err), zap.String("userId", userId))
+			return brokerError(http.StatusInternalServerError, e.ErrorInfo["InternalServerError"].GetErrorDetails(":unable to save paper book"))
+		}
+		if saved {
+			return nil
+		}
+		if attempt == paperBookSaveAttempts {
+			logger.Log.Warn("paper trading: book saved concurrently, giving up", zap.String("userId", userId), zap.Int("attempts", attempt))
+			return brokerError(http.StatusConflict, e.ErrorInfo["BadRequest"].GetErrorDetails(":paper book changed concurrently, try again"))
+		}
+	}
+}
+
+func (p *paperBroker) lastTradedPrice(exchange, securityID string) (float64, *BrokerError) {
+	tick, err := p.dbObj.GetSymbolTickData(securityID, exchange)
+	if err != nil {
+		logger.Log.Error("paper trading: failed to fetch tick data", zap.Error(err), zap.String("exchange", exchange), zap.String("securityId", securityID))
+		return 0.0, brokerError(http.StatusInternalServerError, e.ErrorInfo["InternalServerError"].GetErrorDetails(":unable to fetch last traded price"))
+	}
+	if tick.LastTradedPrice <= 0.0 {
+		return 0.0, brokerError(http.StatusBadRequest, e.ErrorInfo["BadRequest"].GetErrorDetails(":last traded price not available for the instrument"))
+	}
+	return tick.LastTradedPrice, nil
+}
+
+// prices instruments held in the book, an instrument failing to price keeps its last price
+func (p *paperBroker) refresh(book *MatchingBook) {
+	for _, instrument := range book.instruments() {
+		ltp, er := p.lastTradedPrice(instrument.exchange, instrument.securityID)
+		if er != nil {
+			continue
+		}
+		book.SetPrice(instrument.exchange, instrument.securityID, ltp)
+	}
+}
+
+// order number in data of order entry replies
+type paperOrderNo struct {
+	OrderNo string `json:"order_no"`
+}
+
+/*
+unavailableBroker is used when the trading mode of the user cannot
+be read, every call fails so orders of a paper user never reach rupeeseed
+*/
+type unavailableBroker struct{}
+
+func (unavailableBroker) unavailable() *BrokerError {
+	return brokerError(http.StatusServiceUnavailable, namedErrorDetails(TradingModeUnavailableError, "InternalServerError", ":unable to determine trading mode of the user"))
+}
+
+func (b unavailableBroker) PlaceOrder(c *gin.Context, request PlaceOrderRequest) (OrderAck, *BrokerError) {
+	return OrderAck{}, b.unavailable()
+}
+
+func (b unavailableBroker) PlaceBracketOrder(c *gin.Context, request PlaceBracketOrderRequest) (OrderAck, *BrokerError) {
+	return OrderAck{}, b.unavailable()
+}
+
+func (b unavailableBroker) PlaceCoverOrder(c *gin.Context, request PlaceCoverOrderRequest) (OrderAck, *BrokerError) {
+	return OrderAck{}, b.unavailable()
+}
+
+func (b unavailableBroker) ModifyOrder(c *gin.Context, kind string, request ModifyOrderRequest) (OrderAck, *BrokerError) {
+	return OrderAck{}, b.unavailable()
+}
+
+func (b unavailableBroker) CancelOrder(c *gin.Context, request CancelOrderRequest) (OrderAck, *BrokerError) {
+	return OrderAck{}, b.unavailable()
+}
+
+func (b unavailableBroker) ExitOrder(c *gin.Context, kind string, request CancelOrderRequest) (OrderAck, *BrokerError) {
+	return OrderAck{}, b.unavailable()
+}
+
+func (b unavailableBroker) OrderBook(c *gin.Context) ([]BrokerOrder, *BrokerError) {
+	return nil, b.unavailable()
+}
+
+func (b unavailableBroker) OrderHistory(c *gin.Context, orderNo string) ([]BrokerOrderEvent, *BrokerError) {
+	return nil, b.unavailable()
+}
+
+func (b unavailableBroker) TradeBook(c *gin.Context) ([]BrokerTrade, *BrokerError) {
+	return nil, b.unavailable()
+}
+
+func (b unavailableBroker) PositionBook(c *gin.Context) ([]OrderPositionBook, *BrokerError) {
+	return nil, b.unavailable()
+}
+
+func (b unavailableBroker) ConvertPosition(c *gin.Context, request ConvertPositionRequest) (OrderAck, *BrokerError) {
+	return OrderAck{}, b.unavailable()
+}
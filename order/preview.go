@@ -0,0 +1,327 @@
+package trade
+
+import (
+	"encoding/json"
+	config "equity-trading/pkg/config"
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+/*
+dry run of normal, bracket or cover order, nothing is sent to the broker
+except reading PositionBook & OrderBook for exposure limits and
+PositionBook for the resulting position
+
+steps within api
+  - decode order as per kind & run the checks of its placement api i.e
+    trading session, risk engine & exposure limits
+  - value the order at limit/trigger price or last traded price for market order
+  - calculate required margin of every product the order can be placed with
+  - estimate charges & resulting position of the instrument
+*/
+func (s *trade) PreviewOrder(c *gin.Context) {
+	var (
+		request  PreviewOrderRequest
+		response PreviewOrderResponse
+	)
+	//  validating the request payload via gin framework
+	if err := bindOrderJSON(c, &request); err != nil {
+		logger.Log.Error("Invalid arguement received", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+	run, err := s.dryRunOrder(c, request)
+	if err != nil {
+		logger.Log.Error("Invalid order received for preview", zap.String("kind", request.Kind), zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+
+	// data the checks could not fetch leaves the preview undecided, it is not a rejection of the order
+	if status := validationStatus(run.rejection); status != http.StatusBadRequest {
+		logger.Log.Error("preview: unable to check the order", zap.Error(run.rejection))
+		response.Errors = append(response.Errors, validationDetails(status, run.rejection))
+		setValidationRetryAfter(c, run.rejection)
+		c.JSON(status, response)
+		c.Abort()
+		return
+	}
+
+	order := run.order
+	preview := OrderPreview{Valid: true}
+	if run.rejection != nil {
+		preview.Valid = false
+		preview.Reason = strings.TrimPrefix(run.rejection.Error(), ":")
+		preview.FieldErrors = fieldErrorsOf(run.rejection)
+		var riskRejection *RiskRejection
+		if errors.As(run.rejection, &riskRejection) {
+			preview.Rule = riskRejection.Rule
+			preview.Reason = riskRejection.Reason
+		}
+	}
+
+	price, err := s.previewPrice(order)
+	if err != nil {
+		logger.Log.Error("preview: unable to value the order", zap.Error(err))
+		status := validationStatus(err)
+		response.Errors = append(response.Errors, validationDetails(status, err))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(status, response)
+		c.Abort()
+		return
+	}
+	preview.Price = price
+	preview.OrderValue = roundAmount(float64(order.Quantity) * price)
+	preview.Margins = orderMargins(order, price)
+	preview.RequiredMargin = preview.Margins[marginProduct(order)]
+	preview.Charges = orderCharges(order, preview.OrderValue)
+	preview.Position = s.previewPosition(c, order)
+
+	response.Data = preview
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+// order of a preview request after the checks of its placement api
+type dryRun struct {
+	// order as seen by risk rules, converted to AMO if the trading session would convert it
+	order RiskOrder
+	// rejection by trading session, risk rules or exposure limits, nil if the order would be sent
+	rejection error
+}
+
+/*
+decodes the order of preview request as per its kind and runs the
+checks its placement api runs before the order is sent to the broker,
+error is returned only if the order cannot be decoded
+*/
+func (s *trade) dryRunOrder(c *gin.Context, request PreviewOrderRequest) (dryRun, error) {
+	switch request.Kind {
+	case NormalOrderKind:
+		var order ConfirmedPlaceOrderRequest
+		if err := decodeAndValidate(request.Order, &order); err != nil {
+			return dryRun{}, err
+		}
+		placement, _, rejection := s.prepareNormalOrder(c, order.PlaceOrderRequest, order.PriceConfirmed)
+		if rejection == nil {
+			order.PlaceOrderRequest = placement.request
+		}
+		risk := normalRiskOrder(order.PlaceOrderRequest)
+		risk.PriceConfirmed = order.PriceConfirmed
+		return dryRun{order: risk, rejection: rejection}, nil
+	case BracketOrderKind:
+		var order ConfirmedBracketOrderRequest
+		if err := decodeAndValidate(request.Order, &order); err != nil {
+			return dryRun{}, err
+		}
+		rejection := applyBracketTradingSession(&order.PlaceBracketOrderRequest)
+		if rejection == nil {
+			rejection = s.bracketOrderValidation(c, order)
+		}
+		risk := bracketRiskOrder(order.PlaceBracketOrderRequest)
+		risk.PriceConfirmed = order.PriceConfirmed
+		return dryRun{order: risk, rejection: rejection}, nil
+	case CoverOrderKind:
+		var order ConfirmedCoverOrderRequest
+		if err := decodeAndValidate(request.Order, &order); err != nil {
+			return dryRun{}, err
+		}
+		rejection := applyCoverTradingSession(&order.PlaceCoverOrderRequest)
+		if rejection == nil {
+			rejection = s.coverOrderValidation(c, order)
+		}
+		risk := coverRiskOrder(order.PlaceCoverOrderRequest)
+		risk.PriceConfirmed = order.PriceConfirmed
+		return dryRun{order: risk, rejection: rejection}, nil
+	}
+	return dryRun{}, fmt.Errorf("unknown order kind %q", request.Kind)
+}
+
+// decodes with the binding validation handlers get from bindOrderJSON
+func decodeAndValidate(data json.RawMessage, obj interface{}) error {
+	return orderBinding.BindBody(data, obj)
+}
+
+// limit price, trigger price of stop loss market order or last traded price of market order
+func (s *trade) previewPrice(order RiskOrder) (float64, error) {
+	if order.Price > 0.0 {
+		return order.Price, nil
+	}
+	if order.OrderType == SLM && order.TriggerPrice > 0.0 {
+		return order.TriggerPrice, nil
+	}
+	tick, err := s.SymbolTick(order.Exchange, order.ExchangeToken)
+	if err != nil {
+		return 0, &RiskDataUnavailable{Data: "last traded price of the instrument", Err: err}
+	}
+	if tick.LastTradedPrice <= 0.0 {
+		return 0, errors.New(":last traded price not available to value the order")
+	}
+	return tick.LastTradedPrice, nil
+}
+
+// margin key of the product of the order
+func marginProduct(order RiskOrder) string {
+	switch {
+	case order.Kind == BracketOrderKind:
+		return "bo"
+	case order.Kind == CoverOrderKind:
+		return "co"
+	case order.Product == IntradayProductValue:
+		return "intraday"
+	}
+	return "delivery"
+}
+
+/*
+required margin of the order for every product it can be placed with,
+percentages are read from margin.* config & default to exchange minimums
+
+	delivery | full value of equity buy, zero for sell from holdings
+	intraday | margin.intradayPercent of value
+	bo       | stoploss risk, at least margin.bracketMinPercent of value
+	co       | risk till trigger price, at least margin.coverMinPercent of value
+
+derivative orders are margined at margin.derivativePercent of value
+*/
+func orderMargins(order RiskOrder, price float64) map[string]float64 {
+	value := float64(order.Quantity) * price
+	margins := make(map[string]float64)
+
+	switch order.Kind {
+	case BracketOrderKind:
+		risk := order.StoplossValue * float64(order.Quantity)
+		margins["bo"] = roundAmount(math.Max(risk, value*marginPercent("margin.bracketMinPercent", DefaultBracketMinMarginPercent)/100))
+	case CoverOrderKind:
+		risk := math.Abs(price-order.TriggerPrice) * float64(order.Quantity)
+		margins["co"] = roundAmount(math.Max(risk, value*marginPercent("margin.coverMinPercent", DefaultCoverMinMarginPercent)/100))
+	default:
+		if order.Segment != EquitySegment {
+			margin := roundAmount(value * marginPercent("margin.derivativePercent", DefaultDerivativeMarginPercent) / 100)
+			margins["delivery"], margins["intraday"] = margin, margin
+			break
+		}
+		if order.TxnType == BUY {
+			margins["delivery"] = roundAmount(value)
+		} else {
+			margins["delivery"] = 0
+		}
+		margins["intraday"] = roundAmount(value * marginPercent("margin.intradayPercent", DefaultIntradayMarginPercent) / 100)
+	}
+	return margins
+}
+
+func marginPercent(key string, fallback float64) float64 {
+	if percent := config.GetConfig().GetFloat64(key); percent > 0.0 {
+		return percent
+	}
+	return fallback
+}
+
+// statutory rates in percent of order value for one side of the trade
+type chargeRates struct {
+	sttBuy, sttSell, exchange, stampBuy float64
+}
+
+var (
+	equityDeliveryRates = chargeRates{sttBuy: 0.1, sttSell: 0.1, exchange: 0.00345, stampBuy: 0.015}
+	equityIntradayRates = chargeRates{sttSell: 0.025, exchange: 0.00345, stampBuy: 0.003}
+	derivativeRates     = chargeRates{sttSell: 0.0125, exchange: 0.002, stampBuy: 0.002}
+)
+
+const (
+	SebiFeesPercent         = 0.0001
+	GSTPercent              = 18.0
+	BrokeragePercent        = 0.03
+	DefaultMaxBrokerage     = 20.0
+	MaxBrokerageConfig      = "charges.maxBrokerage"
+	DeliveryBrokerageConfig = "charges.deliveryBrokerage"
+)
+
+/*
+estimated charges of the order, brokerage is BrokeragePercent of value capped
+at charges.maxBrokerage, delivery brokerage is charged only if
+charges.deliveryBrokerage is set
+*/
+func orderCharges(order RiskOrder, value float64) OrderCharges {
+	rates := derivativeRates
+	delivery := order.Segment == EquitySegment && marginProduct(order) == "delivery"
+	if delivery {
+		rates = equityDeliveryRates
+	} else if order.Segment == EquitySegment {
+		rates = equityIntradayRates
+	}
+
+	var charges OrderCharges
+	maxBrokerage := config.GetConfig().GetFloat64(MaxBrokerageConfig)
+	if maxBrokerage <= 0.0 {
+		maxBrokerage = DefaultMaxBrokerage
+	}
+	if !delivery || config.GetConfig().GetBool(DeliveryBrokerageConfig) {
+		charges.Brokerage = math.Min(value*BrokeragePercent/100, maxBrokerage)
+	}
+	if order.TxnType == BUY {
+		charges.STT = value * rates.sttBuy / 100
+		charges.StampDuty = value * rates.stampBuy / 100
+	} else {
+		charges.STT = value * rates.sttSell / 100
+	}
+	charges.ExchangeCharges = value * rates.exchange / 100
+	charges.SebiFees = value * SebiFeesPercent / 100
+	charges.GST = (charges.Brokerage + charges.ExchangeCharges + charges.SebiFees) * GSTPercent / 100
+
+	charges.Brokerage = roundAmount(charges.Brokerage)
+	charges.STT = roundAmount(charges.STT)
+	charges.StampDuty = roundAmount(charges.StampDuty)
+	charges.ExchangeCharges = roundAmount(charges.ExchangeCharges)
+	charges.SebiFees = roundAmount(charges.SebiFees)
+	charges.GST = roundAmount(charges.GST)
+	charges.Total = roundAmount(charges.Brokerage + charges.STT + charges.StampDuty + charges.ExchangeCharges + charges.SebiFees + charges.GST)
+	return charges
+}
+
+/*
+net position of the instrument & product after the order is traded,
+nil if PositionBook could not be read
+*/
+func (s *trade) previewPosition(c *gin.Context, order RiskOrder) *PreviewPosition {
+	position := &PreviewPosition{
+		Exchange:   order.Exchange,
+		SecurityID: fmt.Sprintf("%d", order.ExchangeToken),
+		Product:    order.Product,
+	}
+	positions, err := s.broker(c).PositionBook(c)
+	if err != nil {
+		logger.Log.Warn("preview: failed to fetch positionBook", zap.Error(err))
+		return nil
+	}
+	for _, p := range positions {
+		if p.Exchange == position.Exchange && p.SecurityID == position.SecurityID && p.Product == position.Product {
+			position.CurrentQty = p.NetQty
+			break
+		}
+	}
+	position.ResultingQty = position.CurrentQty + order.Quantity
+	if order.TxnType == SELL {
+		position.ResultingQty = position.CurrentQty - order.Quantity
+	}
+	return position
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
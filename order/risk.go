@@ -0,0 +1,654 @@
+package trade
+
+import (
+	"encoding/json"
+	config "equity-trading/pkg/config"
+	"equity-trading/pkg/db/scrip"
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	NormalOrderKind  = "NORMAL"
+	BracketOrderKind = "BRACKET"
+	CoverOrderKind   = "COVER"
+
+	RiskPlace  = "PLACE"
+	RiskModify = "MODIFY"
+
+	// config key holding the configured risk rules
+	RiskRulesConfig = "risk.rules"
+	// configured risk rules are read again from config after this interval
+	RiskRulesReloadInterval = time.Minute
+
+	// request field confirming a price far from last traded price
+	PriceConfirmedField = "price_confirmed"
+	// percent from last traded price beyond which price needs confirmation
+	DefaultFatFingerPercent = 5.0
+	// error name of orders risk rules could not check as market or scrip master data was unavailable
+	RiskDataUnavailableError = "RiskDataUnavailable"
+)
+
+// RiskOrder is the order placed or modified as seen by risk rules
+type RiskOrder struct {
+	Action              string
+	Kind                string
+	TxnType             string
+	Exchange            string
+	Segment             string
+	Product             string
+	OrderType           string
+	Validity            string
+	ExchangeToken       int
+	Quantity            int
+	Price               float64
+	TriggerPrice        float64
+	ProfitValue         float64
+	StoplossValue       float64
+	OffMktFlag          bool
+	OffMktOrderTimeFlag int
+	// user confirmed a price far from last traded price
+	PriceConfirmed bool
+	// order is placed later i.e GTT, market price checks do not apply now
+	Deferred bool
+}
+
+// MarketData gives market prices & scrip master data to rules which need them
+type MarketData interface {
+	SymbolTick(exchange string, exchangeToken int) (scrip.SymbolTickData, error)
+	Instrument(exchange, segment string, exchangeToken int) (scrip.MasterSymbol, error)
+}
+
+// cachedMarketData fetches market data at most once within an evaluation
+type cachedMarketData struct {
+	market     MarketData
+	tick       *scrip.SymbolTickData
+	tickErr    error
+	instrument *scrip.MasterSymbol
+	instErr    error
+}
+
+func (m *cachedMarketData) SymbolTick(exchange string, exchangeToken int) (scrip.SymbolTickData, error) {
+	if m.tick == nil && m.tickErr == nil {
+		tick, err := m.market.SymbolTick(exchange, exchangeToken)
+		m.tick, m.tickErr = &tick, err
+	}
+	return *m.tick, m.tickErr
+}
+
+func (m *cachedMarketData) Instrument(exchange, segment string, exchangeToken int) (scrip.MasterSymbol, error) {
+	if m.instrument == nil && m.instErr == nil {
+		instrument, err := m.market.Instrument(exchange, segment, exchangeToken)
+		m.instrument, m.instErr = &instrument, err
+	}
+	return *m.instrument, m.instErr
+}
+
+/*
+RiskRule checks a single constraint of an order, Check returns
+the reason of rejection in the ":reason" format of error details
+*/
+type RiskRule interface {
+	Name() string
+	Check(order RiskOrder, market MarketData) error
+}
+
+// RiskScope limits a rule to action, kind, segment & product, empty value matches any
+type RiskScope struct {
+	Action  string `json:"action"`
+	Kind    string `json:"kind"`
+	Segment string `json:"segment"`
+	Product string `json:"product"`
+}
+
+func (r RiskScope) matches(order RiskOrder) bool {
+	return scopeValueMatches(r.Action, order.Action) &&
+		scopeValueMatches(r.Kind, order.Kind) &&
+		scopeValueMatches(r.Segment, order.Segment) &&
+		scopeValueMatches(r.Product, order.Product)
+}
+
+func scopeValueMatches(scope, value string) bool {
+	return len(scope) == 0 || strings.EqualFold(scope, value)
+}
+
+// RiskRejection reports the rule which rejected the order
+type RiskRejection struct {
+	Rule   string
+	Reason string
+	Cause  error
+}
+
+func (r *RiskRejection) Error() string {
+	return fmt.Sprintf("%s (rule %s)", r.Reason, r.Rule)
+}
+
+func (r *RiskRejection) Unwrap() error {
+	return r.Cause
+}
+
+/*
+RiskDataUnavailable reports market or scrip master data a rule could
+not fetch, the order is not at fault so Evaluate returns it as is
+instead of a RiskRejection
+*/
+type RiskDataUnavailable struct {
+	Rule string
+	// data the rule needed i.e instrument from scrip master
+	Data string
+	Err  error
+}
+
+func (r *RiskDataUnavailable) Error() string {
+	return fmt.Sprintf(":unable to fetch %s (rule %s), %v", r.Data, r.Rule, r.Err)
+}
+
+func (r *RiskDataUnavailable) Unwrap() error {
+	return r.Err
+}
+
+/*
+http status of a failed validation, data rules could not fetch & books
+the broker could not return are not the client's fault
+*/
+func validationStatus(err error) int {
+	var (
+		unavailable *RiskDataUnavailable
+		er          *BrokerError
+	)
+	switch {
+	case errors.As(err, &unavailable):
+		return http.StatusServiceUnavailable
+	case errors.As(err, &er):
+		return brokerErrorStatus(er)
+	}
+	return http.StatusBadRequest
+}
+
+// error details of a validation failed with status, causes of unavailable data are only logged
+func validationDetails(status int, err error) e.Error {
+	var (
+		unavailable *RiskDataUnavailable
+		er          *BrokerError
+	)
+	switch {
+	case errors.As(err, &er):
+		return er.Err
+	case errors.As(err, &unavailable):
+		return namedErrorDetails(RiskDataUnavailableError, "InternalServerError", fmt.Sprintf(":unable to fetch %s to check the order, please try after some time", unavailable.Data))
+	case status >= http.StatusInternalServerError:
+		return e.ErrorInfo["InternalServerError"].GetErrorDetails("")
+	}
+	return e.ErrorInfo["BadRequest"].GetErrorDetails(err.Error())
+}
+
+// sets Retry-After of the reply when the validation failed on a broker call
+func setValidationRetryAfter(c *gin.Context, err error) {
+	var er *BrokerError
+	if errors.As(err, &er) {
+		setRetryAfter(c, er)
+	}
+}
+
+type scopedRule struct {
+	scope RiskScope
+	rule  RiskRule
+}
+
+/*
+RiskEngine evaluates every rule in scope of the order in registration
+order, built in rules are registered in code & configured rules are
+loaded from config so limits can change without a release, configured
+rules are reloaded every reloadEvery so a config change reaches running
+instances
+*/
+type RiskEngine struct {
+	mu          sync.RWMutex
+	builtIn     []scopedRule
+	configured  []scopedRule
+	loadedAt    time.Time
+	reloadEvery time.Duration
+}
+
+func NewRiskEngine() *RiskEngine {
+	return &RiskEngine{reloadEvery: RiskRulesReloadInterval}
+}
+
+// Register adds a built in rule for orders in scope
+func (r *RiskEngine) Register(scope RiskScope, rule RiskRule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.builtIn = append(r.builtIn, scopedRule{scope: scope, rule: rule})
+}
+
+/*
+Evaluate returns *RiskRejection of the first rule rejecting the order,
+built in rules run before configured rules, a rule failing for want of
+market data returns *RiskDataUnavailable as is
+*/
+func (r *RiskEngine) Evaluate(order RiskOrder, market MarketData) error {
+	r.reloadConfigured(time.Now())
+
+	r.mu.RLock()
+	rules := make([]scopedRule, 0, len(r.builtIn)+len(r.configured))
+	rules = append(rules, r.builtIn...)
+	rules = append(rules, r.configured...)
+	r.mu.RUnlock()
+
+	market = &cachedMarketData{market: market}
+	for _, scoped := range rules {
+		if !scoped.scope.matches(order) {
+			continue
+		}
+		if err := scoped.rule.Check(order, market); err != nil {
+			var unavailable *RiskDataUnavailable
+			if errors.As(err, &unavailable) {
+				unavailable.Rule = scoped.rule.Name()
+				return unavailable
+			}
+			return &RiskRejection{Rule: scoped.rule.Name(), Reason: err.Error(), Cause: err}
+		}
+	}
+	return nil
+}
+
+/*
+reloads configured rules once reloadEvery has passed since the last
+load, a single caller reloads while others evaluate with the rules held,
+a failed load is retried only after reloadEvery
+*/
+func (r *RiskEngine) reloadConfigured(now time.Time) {
+	r.mu.RLock()
+	stale := now.Sub(r.loadedAt) >= r.reloadEvery
+	r.mu.RUnlock()
+	if !stale {
+		return
+	}
+	r.mu.Lock()
+	if now.Sub(r.loadedAt) < r.reloadEvery {
+		r.mu.Unlock()
+		return
+	}
+	r.loadedAt = now
+	r.mu.Unlock()
+
+	if err := r.LoadConfigured(); err != nil {
+		logger.Log.Error("risk engine: failed to load configured rules, previous rules kept", zap.Error(err))
+	}
+}
+
+/*
+LoadConfigured replaces configured rules with rules under risk.rules,
+previous rules are kept if any configured rule is invalid & rules are
+removed when risk.rules is removed from config
+
+	risk:
+	  rules:
+	    - rule: maxQuantity
+	      segment: D
+	      limit: 1800
+*/
+func (r *RiskEngine) LoadConfigured() error {
+	raw := config.GetConfig().Get(RiskRulesConfig)
+	if raw == nil {
+		return r.SetConfigured(nil)
+	}
+	byteData, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	var configs []RiskRuleConfig
+	if err := json.Unmarshal(byteData, &configs); err != nil {
+		return err
+	}
+	return r.SetConfigured(configs)
+}
+
+// SetConfigured replaces configured rules, previous rules are kept if any config is invalid
+func (r *RiskEngine) SetConfigured(configs []RiskRuleConfig) error {
+	rules := make([]scopedRule, 0, len(configs))
+	for _, cfg := range configs {
+		factory, ok := riskRuleFactories[cfg.Rule]
+		if !ok {
+			return fmt.Errorf("unknown risk rule %q", cfg.Rule)
+		}
+		rule, err := factory(cfg)
+		if err != nil {
+			return fmt.Errorf("risk rule %q: %w", cfg.Rule, err)
+		}
+		rules = append(rules, scopedRule{scope: cfg.RiskScope, rule: rule})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configured = rules
+	r.loadedAt = time.Now()
+	return nil
+}
+
+// RiskRuleConfig is one configured rule with its scope & limits
+type RiskRuleConfig struct {
+	Rule string `json:"rule"`
+	RiskScope
+	Limit      float64  `json:"limit"`
+	OrderTypes []string `json:"order_types"`
+}
+
+// creates a configurable rule from its config
+type RiskRuleFactory func(RiskRuleConfig) (RiskRule, error)
+
+var riskRuleFactories = map[string]RiskRuleFactory{
+	"maxQuantity":       newMaxQuantityRule,
+	"maxOrderValue":     newMaxOrderValueRule,
+	"allowedOrderTypes": newAllowedOrderTypesRule,
+	"priceDeviation":    newPriceDeviationRule,
+}
+
+// RegisterRiskRuleFactory makes a new rule available to risk.rules config
+func RegisterRiskRuleFactory(name string, factory RiskRuleFactory) {
+	riskRuleFactories[name] = factory
+}
+
+// riskRuleFunc adapts a check func to RiskRule
+type riskRuleFunc struct {
+	name  string
+	check func(RiskOrder, MarketData) error
+}
+
+func (r riskRuleFunc) Name() string { return r.name }
+
+func (r riskRuleFunc) Check(order RiskOrder, market MarketData) error {
+	return r.check(order, market)
+}
+
+func newMaxQuantityRule(cfg RiskRuleConfig) (RiskRule, error) {
+	if cfg.Limit <= 0 {
+		return nil, errors.New("limit must be greater than zero")
+	}
+	return riskRuleFunc{name: "maxQuantity", check: func(order RiskOrder, _ MarketData) error {
+		if float64(order.Quantity) > cfg.Limit {
+			limit := strconv.Itoa(int(cfg.Limit))
+			return fieldError(QuantityField, "max", "Quantity cannot be greater than "+limit, "max", limit)
+		}
+		return nil
+	}}, nil
+}
+
+// order value is quantity at limit price, market orders are valued at last traded price
+func newMaxOrderValueRule(cfg RiskRuleConfig) (RiskRule, error) {
+	if cfg.Limit <= 0 {
+		return nil, errors.New("limit must be greater than zero")
+	}
+	return riskRuleFunc{name: "maxOrderValue", check: func(order RiskOrder, market MarketData) error {
+		price := order.Price
+		if price <= 0.0 {
+			tick, err := market.SymbolTick(order.Exchange, order.ExchangeToken)
+			if err != nil || tick.LastTradedPrice <= 0.0 {
+				return errors.New(":last traded price not available to value the order")
+			}
+			price = tick.LastTradedPrice
+		}
+		if float64(order.Quantity)*price > cfg.Limit {
+			limit := fmt.Sprintf("%.2f", cfg.Limit)
+			return fieldError(QuantityField, "maxOrderValue", "Order value cannot be greater than "+limit, "max", limit)
+		}
+		return nil
+	}}, nil
+}
+
+func newAllowedOrderTypesRule(cfg RiskRuleConfig) (RiskRule, error) {
+	if len(cfg.OrderTypes) == 0 {
+		return nil, errors.New("order_types cannot be empty")
+	}
+	return riskRuleFunc{name: "allowedOrderTypes", check: func(order RiskOrder, _ MarketData) error {
+		for _, orderType := range cfg.OrderTypes {
+			if strings.EqualFold(orderType, order.OrderType) {
+				return nil
+			}
+		}
+		allowed := strings.Join(cfg.OrderTypes, "|")
+		return fieldError(OrderTypeField, "notAllowed", fmt.Sprintf("OrderType %s is not allowed, allowed values are %s", order.OrderType, allowed), "allowed", allowed)
+	}}, nil
+}
+
+// limit & trigger price cannot deviate more than limit percent from last traded price
+func newPriceDeviationRule(cfg RiskRuleConfig) (RiskRule, error) {
+	if cfg.Limit <= 0 {
+		return nil, errors.New("limit must be greater than zero")
+	}
+	return riskRuleFunc{name: "priceDeviation", check: func(order RiskOrder, market MarketData) error {
+		if order.Deferred || (order.Price <= 0.0 && order.TriggerPrice <= 0.0) {
+			return nil
+		}
+		tick, err := market.SymbolTick(order.Exchange, order.ExchangeToken)
+		if err != nil {
+			return &RiskDataUnavailable{Data: "last traded price of the instrument", Err: err}
+		}
+		ltp := tick.LastTradedPrice
+		if ltp <= 0.0 {
+			return errors.New(":last traded price not available to check price deviation")
+		}
+		for _, price := range []float64{order.Price, order.TriggerPrice} {
+			if price > 0.0 && math.Abs(price-ltp)*100/ltp > cfg.Limit {
+				return fieldError(PriceField, "priceDeviation", fmt.Sprintf("Price cannot deviate more than %.2f%% from last traded price %.2f", cfg.Limit, ltp),
+					"percent", fmt.Sprintf("%.2f", cfg.Limit), "lastTradedPrice", fmt.Sprintf("%.2f", ltp))
+			}
+		}
+		return nil
+	}}, nil
+}
+
+// engine used by every placement & modify handler
+var riskEngine = newDefaultRiskEngine()
+
+/*
+registers the business constraints of placing & modifying
+normal, bracket & cover orders as built in rules
+*/
+func newDefaultRiskEngine() *RiskEngine {
+	engine := NewRiskEngine()
+	engine.Register(RiskScope{}, riskRuleFunc{name: "limitPrice", check: checkLimitPrice})
+	engine.Register(RiskScope{Kind: NormalOrderKind}, riskRuleFunc{name: "triggerOrder", check: checkTriggerOrder})
+	engine.Register(RiskScope{Action: RiskModify, Kind: NormalOrderKind}, riskRuleFunc{name: "triggerPrice", check: checkTriggerPrice})
+	engine.Register(RiskScope{Action: RiskPlace, Kind: BracketOrderKind}, riskRuleFunc{name: "bracketTargets", check: checkBracketTargets})
+	engine.Register(RiskScope{Kind: CoverOrderKind}, riskRuleFunc{name: "coverTrigger", check: checkCoverTrigger})
+	engine.Register(RiskScope{Action: RiskPlace}, riskRuleFunc{name: "offMarketOrder", check: checkOffMarketOrder})
+	engine.Register(RiskScope{}, riskRuleFunc{name: "lotSize", check: checkLotSize})
+	engine.Register(RiskScope{}, riskRuleFunc{name: "tickSize", check: checkTickSize})
+	engine.Register(RiskScope{}, riskRuleFunc{name: "priceBand", check: checkPriceBand})
+	engine.Register(RiskScope{}, riskRuleFunc{name: "fatFinger", check: checkFatFinger})
+	return engine
+}
+
+func checkLimitPrice(order RiskOrder, _ MarketData) error {
+	if order.OrderType == LMT && order.Price <= 0.0 {
+		return fieldError(PriceField, "required", "Price cannot be zero with limit order")
+	}
+	return nil
+}
+
+func checkTriggerOrder(order RiskOrder, _ MarketData) error {
+	if order.OrderType == SL {
+		if order.Price <= 0.0 {
+			return fieldError(PriceField, "required", "Price cannot be zero with trigger limit order")
+		}
+		if order.TriggerPrice <= 0.0 {
+			return fieldError(TriggerPriceField, "required", "Trigger Price cannot be zero with limit order")
+		}
+		if order.Validity == IOC {
+			return fieldError(ValidityField, "notAllowed", "Validity cannot be IOC with Trigger order") // NOTE this is SL/SLM orderType with Rupeeseed API
+		}
+		if order.TxnType == BUY && order.TriggerPrice > order.Price {
+			return fieldError(TriggerPriceField, "lteField", "Trigger Price cannot be greater than limit buy price", "field", PriceField)
+		}
+		if order.TxnType == SELL && order.TriggerPrice < order.Price {
+			return fieldError(TriggerPriceField, "gteField", "Trigger Price cannot be less than limit buy price", "field", PriceField)
+		}
+	} else if order.OrderType == SLM {
+		if order.TriggerPrice <= 0.0 {
+			return fieldError(TriggerPriceField, "required", "Trigger Price cannot be zero with limit order")
+		} else if order.Validity == IOC {
+			return fieldError(ValidityField, "notAllowed", "Validity cannot be IOC with Trigger order") // NOTE this is SL/SLM orderType with Rupeeseed API
+		}
+	}
+	return nil
+}
+
+// trigger price is meaningful only for stop loss orders, it is rejected with limit & market orders
+func checkTriggerPrice(order RiskOrder, _ MarketData) error {
+	if order.TriggerPrice > 0.0 && order.OrderType != SL && order.OrderType != SLM {
+		return fieldError(TriggerPriceField, "notAllowed", fmt.Sprintf("Trigger Price is not allowed with %s order", order.OrderType), "orderType", order.OrderType)
+	}
+	return nil
+}
+
+func checkBracketTargets(order RiskOrder, _ MarketData) error {
+	if order.ProfitValue <= 0.0 && order.StoplossValue <= 0.0 {
+		return fieldError("", "required", "ProfitValue, StoplossValue cannot be zero", "fields", ProfitValueField+"|"+StoplossValueField)
+	}
+	if order.ProfitValue <= 0.0 {
+		return fieldError(ProfitValueField, "required", "ProfitValue cannot be zero")
+	}
+	if order.StoplossValue <= 0.0 {
+		return fieldError(StoplossValueField, "required", "StoplossValue cannot be zero")
+	}
+	return nil
+}
+
+func checkCoverTrigger(order RiskOrder, _ MarketData) error {
+	if order.TriggerPrice <= 0.0 {
+		return fieldError(TriggerPriceField, "required", "Trigger Price cannot be zero")
+	}
+	return nil
+}
+
+// quantity must be a multiple of lot size of the instrument
+func checkLotSize(order RiskOrder, market MarketData) error {
+	instrument, err := market.Instrument(order.Exchange, order.Segment, order.ExchangeToken)
+	if err != nil {
+		return instrumentError(err)
+	}
+	if instrument.LotSize > 1 && order.Quantity%instrument.LotSize != 0 {
+		lotSize := strconv.Itoa(instrument.LotSize)
+		return fieldError(QuantityField, "lotSize", "Quantity must be a multiple of lot size "+lotSize, "lotSize", lotSize)
+	}
+	return nil
+}
+
+// limit & trigger price must be on the tick grid of the instrument
+func checkTickSize(order RiskOrder, market MarketData) error {
+	instrument, err := market.Instrument(order.Exchange, order.Segment, order.ExchangeToken)
+	if err != nil {
+		return instrumentError(err)
+	}
+	if instrument.TickSize <= 0.0 {
+		return nil
+	}
+	tickSize := strconv.FormatFloat(instrument.TickSize, 'g', -1, 64)
+	if order.Price > 0.0 && !onTickGrid(order.Price, instrument.TickSize) {
+		return fieldError(PriceField, "tickSize", "Price must be a multiple of tick size "+tickSize, "tickSize", tickSize)
+	}
+	if order.TriggerPrice > 0.0 && !onTickGrid(order.TriggerPrice, instrument.TickSize) {
+		return fieldError(TriggerPriceField, "tickSize", "Trigger Price must be a multiple of tick size "+tickSize, "tickSize", tickSize)
+	}
+	return nil
+}
+
+func onTickGrid(price, tickSize float64) bool {
+	ticks := math.Round(price / tickSize)
+	return math.Abs(ticks*tickSize-price) < 1e-6
+}
+
+// limit & trigger price must be within the daily price band of the instrument
+func checkPriceBand(order RiskOrder, market MarketData) error {
+	if order.Deferred || (order.Price <= 0.0 && order.TriggerPrice <= 0.0) {
+		return nil
+	}
+	tick, err := market.SymbolTick(order.Exchange, order.ExchangeToken)
+	if err != nil {
+		return &RiskDataUnavailable{Data: "price band of the instrument", Err: err}
+	}
+	if tick.LowerCircuit <= 0.0 || tick.UpperCircuit <= 0.0 {
+		return nil
+	}
+	lower, upper := fmt.Sprintf("%.2f", tick.LowerCircuit), fmt.Sprintf("%.2f", tick.UpperCircuit)
+	if order.Price > 0.0 && (order.Price < tick.LowerCircuit || order.Price > tick.UpperCircuit) {
+		return fieldError(PriceField, "priceBand", "Price must be within price band "+lower+" - "+upper, "lower", lower, "upper", upper)
+	}
+	if order.TriggerPrice > 0.0 && (order.TriggerPrice < tick.LowerCircuit || order.TriggerPrice > tick.UpperCircuit) {
+		return fieldError(TriggerPriceField, "priceBand", "Trigger Price must be within price band "+lower+" - "+upper, "lower", lower, "upper", upper)
+	}
+	return nil
+}
+
+/*
+limit & trigger price farther than risk.fatFingerPercent from last
+traded price is placed only when user confirmed it with price_confirmed
+*/
+func checkFatFinger(order RiskOrder, market MarketData) error {
+	if order.Deferred || order.PriceConfirmed || (order.Price <= 0.0 && order.TriggerPrice <= 0.0) {
+		return nil
+	}
+	tick, err := market.SymbolTick(order.Exchange, order.ExchangeToken)
+	if err != nil {
+		return &RiskDataUnavailable{Data: "last traded price of the instrument", Err: err}
+	}
+	ltp := tick.LastTradedPrice
+	if ltp <= 0.0 {
+		return nil
+	}
+	percent := config.GetConfig().GetFloat64("risk.fatFingerPercent")
+	if percent <= 0.0 {
+		percent = DefaultFatFingerPercent
+	}
+	for i, price := range []float64{order.Price, order.TriggerPrice} {
+		if price > 0.0 && math.Abs(price-ltp)*100/ltp > percent {
+			field := PriceField
+			if i == 1 {
+				field = TriggerPriceField
+			}
+			return fieldError(field, "fatFinger", fmt.Sprintf("Price %.2f is more than %.2f%% away from last traded price %.2f, resend with %s true to confirm", price, percent, ltp, PriceConfirmedField),
+				"percent", fmt.Sprintf("%.2f", percent), "lastTradedPrice", fmt.Sprintf("%.2f", ltp), "confirmField", PriceConfirmedField)
+		}
+	}
+	return nil
+}
+
+func instrumentError(err error) error {
+	if errors.Is(err, ErrScripNotFound) {
+		return fieldError(ExchangeTokenField, "notFound", "ExchangeToken not found in scrip master")
+	}
+	return &RiskDataUnavailable{Data: "instrument from scrip master", Err: err}
+}
+
+// SymbolTick of the instrument for rules checking price against market
+func (s *trade) SymbolTick(exchange string, exchangeToken int) (scrip.SymbolTickData, error) {
+	return s.dbObj.GetSymbolTickData(fmt.Sprintf("%d", exchangeToken), exchange)
+}
+
+// Instrument from scrip master for rules checking lot & tick size
+func (s *trade) Instrument(exchange, segment string, exchangeToken int) (scrip.MasterSymbol, error) {
+	symbols, _, err := s.dbObj.GetMasterSymbols(scrip.GetMasterSymbolsParams{
+		Exchange:      exchange,
+		Segment:       segment,
+		ExchangeToken: fmt.Sprintf("%d", exchangeToken),
+	})
+	if err != nil {
+		return scrip.MasterSymbol{}, err
+	}
+	if len(symbols) == 0 {
+		return scrip.MasterSymbol{}, ErrScripNotFound
+	}
+	return symbols[0], nil
+}
@@ -0,0 +1,673 @@
+package trade
+
+import (
+	"encoding/json"
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"equity-trading/pkg/utils"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+/*
+rupeeseedBroker routes orders to rupeeseed, any error from invoking
+rupeeseed api is converted to BrokerError with Error struct from
+("equity-trading/pkg/errors") package & the message of rupeeseed for rejected orders
+*/
+type rupeeseedBroker struct {
+	rest utils.RestCaller
+}
+
+func (r *rupeeseedBroker) PlaceOrder(c *gin.Context, request PlaceOrderRequest) (OrderAck, *BrokerError) {
+	return r.orderEntry(OrderApi, getRupeeseedOrderRequestBody(c, request))
+}
+
+func (r *rupeeseedBroker) PlaceBracketOrder(c *gin.Context, request PlaceBracketOrderRequest) (OrderAck, *BrokerError) {
+	return r.orderEntry(BracketOrderApi, getRupeseedBracketRequestBody(c, request))
+}
+
+func (r *rupeeseedBroker) PlaceCoverOrder(c *gin.Context, request PlaceCoverOrderRequest) (OrderAck, *BrokerError) {
+	return r.orderEntry(CoverOrderApi, getRupeseedCoverRequestBody(c, request))
+}
+
+func (r *rupeeseedBroker) ModifyOrder(c *gin.Context, kind string, request ModifyOrderRequest) (OrderAck, *BrokerError) {
+	api := ModifyOrderApi
+	switch kind {
+	case BracketOrderKind:
+		api = BoModifyOrderAPI
+	case CoverOrderKind:
+		api = CoModifyOrderApi
+	}
+	return r.orderEntry(api, parseVendorRequestBody(c, request))
+}
+
+func (r *rupeeseedBroker) CancelOrder(c *gin.Context, request CancelOrderRequest) (OrderAck, *BrokerError) {
+	return r.orderEntry(CancelOrderApi, getRupeeseedCancelOrderRequestBody(c, request))
+}
+
+func (r *rupeeseedBroker) ExitOrder(c *gin.Context, kind string, request CancelOrderRequest) (OrderAck, *BrokerError) {
+	if kind == CoverOrderKind {
+		return r.orderEntry(CoExitOrderApi, getRupeeseedCoverExitRequestBody(c, request))
+	}
+	return r.orderEntry(BoExitOrderApi, getRupeeseedBracketExitRequestBody(c, request))
+}
+
+/*
+calls an order entry api of rupeeseed i.e OrderEntry, BoOrderEntry,
+CoOrderEntry, modify, cancel & exit, every one of them replies with
+order numbers in data
+*/
+func (r *rupeeseedBroker) orderEntry(api string, requestBody interface{}) (OrderAck, *BrokerError) {
+	var (
+		ack OrderAck
+		obj RupeeseedNormalOrderResponse
+	)
+	er := r.call(rupeeseedRequest{api: api, body: requestBody}, &obj, func() (string, string, string) {
+		return obj.Status, obj.ErrCode, obj.Message
+	})
+	ack.Message = obj.Message
+	if er != nil {
+		return ack, er
+	}
+	ack.Data = obj.Data
+	ack.OrderNos = orderNumbers(obj.Data)
+	return ack, nil
+}
+
+// a request to a rupeeseed api
+type rupeeseedRequest struct {
+	api  string
+	body interface{}
+	// sent through InvokeResty without headers when set, InvokeHttp otherwise
+	restyTimeout int
+	// sent once when not set
+	retry retryPolicy
+}
+
+/*
+calls a rupeeseed api and decodes the reply into response, a pointer
+to the response struct decoded afresh on every attempt, reply returns
+status, error code & message of the decoded response, failures of the
+call are returned as BrokerError with the error code classified through
+the catalogue of rupeeseed error codes
+
+retryable failures are sent again as per retry policy of the request,
+calls fail fast without reaching rupeeseed while circuit of the api is open
+*/
+func (r *rupeeseedBroker) call(request rupeeseedRequest, response interface{}, reply func() (string, string, string)) *BrokerError {
+	backoff := request.retry.backoff
+	for attempt := 1; ; attempt++ {
+		er, retryable := r.attempt(request, response, reply)
+		if er == nil || !retryable || attempt >= request.retry.attempts {
+			return er
+		}
+		logger.Log.Warn("retrying rupeeseed api", zap.String("api", request.api), zap.Int("attempt", attempt), zap.Duration("backoff", backoff))
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// sends request once, retryable when the failure may not repeat
+func (r *rupeeseedBroker) attempt(request rupeeseedRequest, response interface{}, reply func() (string, string, string)) (*BrokerError, bool) {
+	var (
+		body   []byte
+		status int
+		err    error
+	)
+
+	// every attempt decodes into a fresh value so fields of an earlier attempt never leak into the reply
+	target := reflect.ValueOf(response).Elem()
+	target.Set(reflect.Zero(target.Type()))
+
+	uri := rupeeseedObj.EndPoint + request.api
+	breaker := circuitBreakerFor(request.api)
+	if now := time.Now(); !breaker.allow(now) {
+		logger.Log.Error("rupeeseed circuit open, failing fast", zap.String("api:", uri))
+		er := brokerError(http.StatusServiceUnavailable, e.ErrorInfo["VendorConnectionFailure"].GetErrorDetails(":rupeeseed is unavailable, please try after some time"))
+		er.RetryAfter = breaker.retryAfter(now)
+		return er, false
+	}
+	st := time.Now()
+	if request.restyTimeout > 0 {
+		body, status, err = r.rest.InvokeResty(http.MethodPost, uri, request.body, nil, request.restyTimeout)
+	} else {
+		body, status, err = r.rest.InvokeHttp(http.MethodPost, uri, request.body, rupeeseedHeaders, ApiTimeout)
+	}
+	breaker.record(err == nil && status < http.StatusInternalServerError, time.Now())
+	logger.Log.Info("api details", zap.Any("latency", time.Since(st)), zap.Any("status", status), zap.Error(err), zap.Any("data", string(body)))
+	if err != nil {
+		logger.Log.Error("rupeeseed api failure", zap.Error(err), zap.String("api:", uri))
+		return brokerError(http.StatusBadGateway, e.ErrorInfo["VendorApiFailure"].GetErrorDetails("")), true
+	} else if status != http.StatusOK {
+		logger.Log.Error("rupeeseed api failure, unexpected http status", zap.Int("status", status), zap.String("api:", uri))
+		return brokerError(http.StatusBadGateway, e.ErrorInfo["VendorConnectionFailure"].GetErrorDetails("")), status >= http.StatusInternalServerError
+	}
+	decoded := reflect.New(target.Type())
+	if err = json.Unmarshal(body, decoded.Interface()); err != nil {
+		logger.Log.Error("Failed to unmarshal rupeeseed output", zap.Error(err), zap.String("api:", uri), zap.Any("recevied", string(body)))
+		countVendorAnomaly(VendorUnexpectedBody, request.api, "")
+		return brokerError(http.StatusBadGateway, e.ErrorInfo["JsonUnmarshalError"].GetErrorDetails("")), false
+	}
+	target.Set(decoded.Elem())
+
+	replyStatus, errCode, message := reply()
+	if replyStatus == Success {
+		return nil, false
+	}
+	if len(replyStatus) == 0 && len(errCode) == 0 {
+		logger.Log.Error("rupeeseed reply without status", zap.String("api:", uri), zap.Any("recevied", string(body)))
+		countVendorAnomaly(VendorUnexpectedBody, request.api, "")
+		return brokerError(http.StatusBadGateway, e.ErrorInfo["VendorApiFailure"].GetErrorDetails("")), false
+	}
+	er, retryable := rupeeseedFailure(request.api, errCode, message)
+	logger.Log.Error("rupeeseed api failure", zap.String("api:", uri), zap.String("msg", message), zap.String("errorCode", errCode), zap.Bool("retryable", retryable))
+	return er, retryable
+}
+func (r *rupeeseedBroker) OrderBook(c *gin.Context) ([]BrokerOrder, *BrokerError) {
+	var obj RupeeseedOrderBookResponse
+	request := rupeeseedRequest{api: OrderBookApi, body: getOrderBookRupeeseedRequestBody(c), retry: readRetryPolicy}
+	if er := r.call(request, &obj, func() (string, string, string) { return obj.Status, obj.ErrorCode, obj.Message }); er != nil {
+		return nil, er
+	}
+
+	orders := make([]BrokerOrder, 0, len(obj.Data))
+	for _, rOrderBook := range obj.Data {
+		orders = append(orders, brokerOrder(rOrderBook))
+	}
+	return orders, nil
+}
+
+// order of OrderBook from a rupeeseed OrderBook row
+func brokerOrder(rOrderBook RupeeseedOrderBook) BrokerOrder {
+	var order BrokerOrder
+	order.GoodTillDaysDate = rOrderBook.GoodTillDaysDate
+	order.Symbol = rOrderBook.Symbol
+	order.DqQtyRem = rOrderBook.DqQtyRem
+	order.DiscQuantity = rOrderBook.DiscQuantity
+	order.Price = rOrderBook.Price
+	order.Segment = rOrderBook.Segment
+	order.LotSize = rOrderBook.LotSize
+	order.OrderType = rOrderBook.OrderType
+	order.SecurityID = rOrderBook.SecurityID
+	order.ExpiryFlag = rOrderBook.ExpiryFlag
+	order.DisplayName = rOrderBook.DisplayName
+	order.ProductName = rOrderBook.ProductName
+	order.LastUpdatedTime = rOrderBook.LastUpdatedTime
+	order.TriggerPrice = rOrderBook.TriggerPrice
+	order.ExchOrderTime = rOrderBook.ExchOrderTime
+	order.Exchange = rOrderBook.Exchange
+	order.ErrorCode = rOrderBook.ErrorCode
+	order.SerialNo = rOrderBook.SerialNo
+	order.Status = rOrderBook.Status
+	order.OrderNo = rOrderBook.OrderNo
+	order.RemainingQuantity = rOrderBook.RemainingQuantity
+	order.ParticipantType = rOrderBook.ParticipantType
+	order.Product = rOrderBook.Product
+	order.OrderDateTime = rOrderBook.OrderDateTime
+	order.Quantity = rOrderBook.Quantity
+	order.ExpiryDate = rOrderBook.ExpiryDate
+	order.ExchOrderNo = rOrderBook.ExchOrderNo
+	order.TradedPrice = rOrderBook.TradedPrice
+	order.TxnType = rOrderBook.TxnType
+	order.RemQtyTotQty = rOrderBook.RemQtyTotQty
+	order.Validity = rOrderBook.Validity
+	order.AvgTradedPrice = rOrderBook.AvgTradedPrice
+	order.TradedQty = rOrderBook.TradedQty
+	order.GroupId = rOrderBook.GroupId
+	order.OptType = rOrderBook.OptType
+	order.LegNo, _ = strconv.Atoi(rOrderBook.LegNo)
+	order.AlgoOrderNo = rOrderBook.AlgoOrderNo
+	return order
+}
+
+func (r *rupeeseedBroker) OrderHistory(c *gin.Context, orderNo string) ([]BrokerOrderEvent, *BrokerError) {
+	var obj RupeeseedOrderHistoryResponse
+	request := rupeeseedRequest{api: OrderHistoryApi, body: getOrderHistoryRupeeseedRequestBody(c, orderNo), retry: readRetryPolicy}
+	if er := r.call(request, &obj, func() (string, string, string) { return obj.Status, obj.ErrorCode, obj.Message }); er != nil {
+		return nil, er
+	}
+
+	events := make([]BrokerOrderEvent, 0, len(obj.Data))
+	for _, h := range obj.Data {
+		events = append(events, BrokerOrderEvent(h))
+	}
+	return events, nil
+}
+func (r *rupeeseedBroker) TradeBook(c *gin.Context) ([]BrokerTrade, *BrokerError) {
+	var obj RupeeseedTradeBookResponse
+	request := rupeeseedRequest{api: TradeBookApi, body: getTradeBookRupeeseedRequestBody(c), retry: readRetryPolicy}
+	if er := r.call(request, &obj, func() (string, string, string) { return obj.Status, obj.ErrorCode, obj.Message }); er != nil {
+		return nil, er
+	}
+
+	trades := make([]BrokerTrade, 0, len(obj.Data))
+	for _, rTrade := range obj.Data {
+		var fill BrokerTrade
+		fill.OrderNo = rTrade.OrderNo
+		fill.ExchOrderNo = rTrade.ExchOrderNo
+		fill.ExchTradeID = rTrade.ExchTradeID
+		fill.FillQty = rTrade.TradedQty
+		fill.FillPrice = rTrade.TradedPrice
+		fill.TradeTime = rTrade.TradeDateTime
+		fill.Symbol = rTrade.Symbol
+		fill.DisplayName = rTrade.DisplayName
+		fill.Exchange = rTrade.Exchange
+		fill.Segment = rTrade.Segment
+		fill.SecurityID = rTrade.SecurityID
+		fill.TxnType = rTrade.TxnType
+		fill.Product = rTrade.Product
+		fill.OptType = rTrade.OptType
+		trades = append(trades, fill)
+	}
+	return trades, nil
+}
+
+func (r *rupeeseedBroker) PositionBook(c *gin.Context) ([]OrderPositionBook, *BrokerError) {
+	var obj RupeeseedPositionBookResponse
+	request := rupeeseedRequest{api: PositionBookApi, body: getPositionBookRupeeseedRequestBody(c), restyTimeout: 700, retry: readRetryPolicy}
+	if er := r.call(request, &obj, func() (string, string, string) { return obj.Status, obj.ErrorCode, obj.Message }); er != nil {
+		return nil, er
+	}
+
+	positions := make([]OrderPositionBook, 0, len(obj.Data))
+	for _, rPosition := range obj.Data {
+		positions = append(positions, brokerPosition(rPosition))
+	}
+	return positions, nil
+}
+
+// position of PositionBook from a rupeeseed NetPosition row
+func brokerPosition(rPosition RupeeSeedPositionBook) OrderPositionBook {
+	var position OrderPositionBook
+	position.Symbol = rPosition.Symbol
+	position.NetQty = rPosition.NetQty
+	position.SellAvg = rPosition.SellAvg
+	position.BuyAvg = rPosition.BuyAvg
+	position.GrossVal = rPosition.GrossVal
+	position.TotSellQty = rPosition.TotSellQty
+	position.LastTradedPrice = rPosition.LastTradedPrice
+	position.Segment = rPosition.Segment
+	position.GrossQty = rPosition.GrossQty
+	position.LotSize = rPosition.LotSize
+	position.TotSellVal = rPosition.TotSellVal
+	position.Product = rPosition.Product
+	position.NetAvg = rPosition.NetAvg
+	position.ExpiryDate = rPosition.ExpiryDate
+	position.TotSellValDay = rPosition.TotSellValDay
+	position.TotBuyVal = rPosition.TotBuyVal
+	position.NetVal = rPosition.NetVal
+	position.DisplayName = rPosition.DisplayName
+	position.TotBuyQty = rPosition.TotBuyQty
+	position.Exchange = rPosition.Exchange
+	position.SecurityID = rPosition.SecurityID
+	position.RealisedProfit = rPosition.RealisedProfit
+	return position
+}
+
+// converts product type of an open position, message of rupeeseed is returned in ack
+func (r *rupeeseedBroker) ConvertPosition(c *gin.Context, request ConvertPositionRequest) (OrderAck, *BrokerError) {
+	var (
+		ack OrderAck
+		obj RuppeeseedConvertPositionResponse
+	)
+	requestBody := getRupeeseedConvertPositionRequestBody(c, request)
+	logger.Log.Info("created convert position request", zap.Any("request", requestBody))
+	er := r.call(rupeeseedRequest{api: ConvertPositionApi, body: requestBody, restyTimeout: 1000}, &obj, func() (string, string, string) {
+		return obj.Status, obj.ErrorCode, obj.Message
+	})
+	ack.Message = obj.Message
+	return ack, er
+}
+
+/*
+creating request body for calling rupeeseed api
+for normal order through func PlaceOrder
+*/
+func getRupeeseedOrderRequestBody(c *gin.Context, req PlaceOrderRequest) RupeeseedNormalOrderRequest {
+	temp := RupeeseedNormalOrderRequest{}
+	//userId := c.GetString("userId")
+	userId := "TEST2" // only for testing
+	temp.EntityId = userId
+	temp.Source = Source
+	temp.Data.ClientId = userId
+	temp.Data.UserId = userId
+	temp.Data.TxnType = req.TxnType
+	temp.Data.Exchange = req.Exchange
+	temp.Data.Segment = req.Segment
+	temp.Data.Product = req.Product
+	temp.Data.ExchangeToken = fmt.Sprintf("%d", req.ExchangeToken)
+	temp.Data.Qty = fmt.Sprintf("%d", req.Quantity)
+	temp.Data.Price = fmt.Sprintf("%f", req.Price)
+	temp.Data.Valdity = req.Validity
+	temp.Data.OrderType = req.OrderType
+	if req.DisclosedQty > 0 {
+		temp.Data.DisclosedQty = fmt.Sprintf("%d", req.Quantity)
+	}
+	if req.TriggerPrice > 0.0 {
+		temp.Data.TriggerPrice = fmt.Sprintf("%f", req.TriggerPrice)
+	}
+	temp.Data.OffMktFlag = "false"
+	if req.OffMktFlag {
+		temp.Data.OffMktFlag = "true"
+		if req.OffMktOrderTimeFlag > 0 {
+			temp.Data.EncashFlag = req.OffMktOrderTimeFlag
+		}
+	}
+
+	return temp
+}
+
+/*
+function prepares a request in Ruppeeseed API format from custom input request
+
+input
+
+	*gin.Context - for fetching client
+	ConvertPositionRequest - cutom request to use to create Ruppeeseed API request
+
+output
+
+	RuppeeseedConvertPositionRequest - Ruppeeseed API request
+*/
+func getRupeeseedConvertPositionRequestBody(c *gin.Context, req ConvertPositionRequest) RuppeeseedConvertPositionRequest {
+	temp := RuppeeseedConvertPositionRequest{}
+	//userId := c.GetString("userId")
+	userId := "TEST2" // only for testing
+	temp.EntityID = userId
+	temp.Source = Source
+	temp.Data.ClientID = userId
+	temp.Data.UserID = userId
+	temp.Data.Exchange = req.Exchange
+	temp.Data.SecurityID = req.ExchangeToken
+	temp.Data.Segment = req.Segment
+	temp.Data.Quantity = req.Quantity
+	temp.Data.MktType = RuppeeSeedMarketType
+	temp.Data.UserType = UserTypeClient
+	temp.Data.TxnType = req.PositionType
+	temp.Data.ProductFrom = req.PositionFrom
+	temp.Data.ProductTo = req.PositionTo
+
+	return temp
+}
+
+/*
+creating request body for calling rupeeseed api
+for OrderBook through func OrderBook
+*/
+func getOrderBookRupeeseedRequestBody(c *gin.Context) RupeeseedOrderBookRequest {
+	temp := RupeeseedOrderBookRequest{}
+	userId := "TEST2" // only for testing
+	//userId := c.GetString("userId")
+	temp.EntityId = userId
+	temp.Source = Source
+	temp.Data.ClientId = userId
+	temp.Data.UserId = userId
+
+	return temp
+}
+
+/*
+creating request body for calling rupeeseed api
+for OrderHistory through func OrderHistory
+*/
+func getOrderHistoryRupeeseedRequestBody(c *gin.Context, orderNo string) RupeeseedOrderHistoryRequest {
+	temp := RupeeseedOrderHistoryRequest{}
+	userId := "TEST2" // only for testing
+	//userId := c.GetString("userId")
+	temp.EntityId = userId
+	temp.Source = Source
+	temp.Data.ClientId = userId
+	temp.Data.UserId = userId
+	temp.Data.OrderNo = orderNo
+
+	return temp
+}
+
+/*
+creating request body for calling rupeeseed api
+for TradeBook through func TradeBook
+*/
+func getTradeBookRupeeseedRequestBody(c *gin.Context) RupeeseedTradeBookRequest {
+	temp := RupeeseedTradeBookRequest{}
+	userId := "TEST2" // only for testing
+	//userId := c.GetString("userId")
+	temp.EntityId = userId
+	temp.Source = Source
+	temp.Data.ClientId = userId
+	temp.Data.UserId = userId
+
+	return temp
+}
+
+/*
+creating request body for calling rupeeseed api
+for NetPosition through func PositionBook
+*/
+func getPositionBookRupeeseedRequestBody(c *gin.Context) RupeeseedPositionBookRequest {
+	temp := RupeeseedPositionBookRequest{}
+	userId := "TEST2" // only for testing
+	//userId := c.GetString("userId")
+	temp.EntityId = userId
+	temp.Source = Source
+	temp.Data.ClientId = userId
+	temp.Data.UserId = userId
+	temp.Data.InteropFlag = "IP"
+
+	return temp
+}
+
+/*
+creating request body for calling rupeeseed api
+for modifying normal order, bracket order,
+cover order through func ModifyOrder,BracketOrderModify, CoverOrderModify
+*/
+func parseVendorRequestBody(c *gin.Context, req ModifyOrderRequest) VendorRequest {
+	temp := VendorRequest{}
+	//userId := c.GetString("userId")
+	userId := "TEST2" // only for testing
+	temp.EntityId = userId
+	temp.Source = Source
+	temp.Data.ClientId = userId
+	temp.Data.UserId = userId
+	temp.Data.TxnType = req.TxnType
+	temp.Data.Exchange = req.Exchange
+	temp.Data.Segment = req.Segment
+	temp.Data.Product = req.Product
+	temp.Data.ExchangeToken = fmt.Sprintf("%d", req.ExchangeToken)
+	temp.Data.Qty = fmt.Sprintf("%d", req.Qty)
+	temp.Data.Price = fmt.Sprintf("%f", req.Price)
+	temp.Data.Validity = req.Validity
+	temp.Data.OrderType = req.OrderType
+	temp.Data.DisclosedQty = fmt.Sprintf("%d", req.Qty)
+	temp.Data.TriggerPrice = fmt.Sprintf("%f", req.TriggerPrice)
+	temp.Data.OffMktFlag = fmt.Sprintf("%v", req.OffMktFlag)
+
+	//For Order Modify API
+	temp.Data.OrderNo = req.OrderNo
+	temp.Data.GroupId = fmt.Sprintf("%d", req.GroupId)
+	temp.Data.SerialNo = fmt.Sprintf("%d", req.SerialNo)
+
+	//For Cover Order
+	if req.Product == CoProductValue {
+		temp.Data.LegNo = fmt.Sprintf("%f", req.LegNo)
+	}
+
+	//For Bracket Order
+	if req.Product == BoProductValue {
+		temp.Data.LegNo = fmt.Sprintf("%d", int(req.LegNo))
+		temp.Data.AlgoOrderNo = req.AlgoOrderNo
+	}
+	return temp
+}
+
+/*
+creating request body for calling rupeeseed api
+for BoOrderEntry through func PlaceBracketOrder
+*/
+func getRupeseedBracketRequestBody(c *gin.Context, req PlaceBracketOrderRequest) RupeseedBracketOrderRequest {
+	userId := "TEST2" // only for testing
+	temp := RupeseedBracketOrderRequest{}
+	//temp.EntityID = c.GetString("entityId")
+	temp.EntityID = userId
+	temp.Source = Source
+	//temp.Data.ClientID = c.GetString("clientId")
+	temp.Data.ClientID = userId
+	temp.Data.TxnType = req.TxnType
+	temp.Data.Exchange = req.Exchange
+	temp.Data.Segment = req.Segment
+	temp.Data.Product = req.Product
+	temp.Data.Exchange = req.Exchange
+	temp.Data.Quantity = fmt.Sprintf("%d", req.Quantity)
+	temp.Data.Price = fmt.Sprintf("%f", req.Price)
+	temp.Data.Validity = req.Validity
+	temp.Data.OrderType = req.OrderType
+	temp.Data.ProfitValue = fmt.Sprintf("%f", req.ProfitValue)
+	temp.Data.StoplossValue = fmt.Sprintf("%f", req.StoplossValue)
+	temp.Data.ExchangeToken = fmt.Sprintf("%d", req.ExchangeToken)
+	if req.ProfitValue > 0.0 {
+		temp.Data.ProfitValue = fmt.Sprintf("%f", req.ProfitValue)
+	}
+	if req.StoplossValue > 0.0 {
+		temp.Data.StoplossValue = fmt.Sprintf("%f", req.StoplossValue)
+	}
+	if req.OffMktFlag {
+		temp.Data.OffMktFlag = "true"
+	} else {
+		temp.Data.OffMktFlag = "false"
+	}
+
+	return temp
+}
+
+/*
+creating request body for calling rupeeseed api
+for CoOrderEntry through func PlaceCoverOrder
+*/
+func getRupeseedCoverRequestBody(c *gin.Context, req PlaceCoverOrderRequest) RupeseedCoverOrderRequest {
+	userId := "TEST2" // only for testing
+	temp := RupeseedCoverOrderRequest{}
+	//temp.EntityID = c.GetString("entityId")
+	temp.EntityID = userId
+	temp.Source = Source
+	//temp.Data.ClientID = c.GetString("clientId")
+	temp.Data.ClientID = userId
+	temp.Data.TxnType = req.TxnType
+	temp.Data.Exchange = req.Exchange
+	temp.Data.Segment = req.Segment
+	temp.Data.Product = req.Product
+	temp.Data.Exchange = req.Exchange
+	temp.Data.Quantity = fmt.Sprintf("%d", req.Quantity)
+	temp.Data.Price = fmt.Sprintf("%f", req.Price)
+	temp.Data.Validity = req.Validity
+	temp.Data.OrderType = req.OrderType
+	temp.Data.TriggerPrice = fmt.Sprintf("%f", req.TriggerPrice)
+	temp.Data.ExchangeToken = fmt.Sprintf("%d", req.ExchangeToken)
+	if req.OffMktFlag {
+		temp.Data.OffMktFlag = "true"
+	} else {
+		temp.Data.OffMktFlag = "false"
+	}
+
+	return temp
+}
+
+/*
+creating request body for calling rupeeseed api
+for cancelling normal order through func CancelOrder
+*/
+func getRupeeseedCancelOrderRequestBody(c *gin.Context, req CancelOrderRequest) RupeeseedCancelOrderRequest {
+	temp := RupeeseedCancelOrderRequest{}
+	//userId := c.GetString("userId")
+	userId := "TEST2" // only for testing
+	temp.EntityId = userId
+	temp.Source = Source
+	temp.Data.ClientId = userId
+	temp.Data.UserId = userId
+	temp.Data.OrderNo = req.OrderNo
+	temp.Data.SerialNo = fmt.Sprintf("%d", req.SerialNo)
+	temp.Data.GroupId = fmt.Sprintf("%d", req.GroupId)
+	temp.Data.TxnType = req.TxnType
+	temp.Data.Exchange = req.Exchange
+	temp.Data.Segment = req.Segment
+	temp.Data.Product = req.Product
+
+	return temp
+}
+
+/*
+creating request body for calling rupeeseed api
+for BoOrderExit through func BracketOrderExit
+*/
+func getRupeeseedBracketExitRequestBody(c *gin.Context, req CancelOrderRequest) RupeeseedBracketExitRequest {
+	userId := "TEST2" // only for testing
+	temp := RupeeseedBracketExitRequest{}
+	//temp.EntityID = c.GetString("entityId")
+	temp.EntityID = userId
+	temp.Source = Source
+	//temp.Data.ClientID = c.GetString("clientId")
+	temp.Data.ClientID = userId
+	temp.Data.OrderNo = req.OrderNo
+	temp.Data.SerialNo = fmt.Sprintf("%d", req.SerialNo)
+	temp.Data.GroupId = fmt.Sprintf("%d", req.GroupId)
+	temp.Data.LegNo = fmt.Sprintf("%d", req.LegNo)
+	temp.Data.AlgoOrderNo = req.AlgoOrderNo
+	temp.Data.TxnType = req.TxnType
+	temp.Data.Exchange = req.Exchange
+	temp.Data.Segment = req.Segment
+	temp.Data.Product = req.Product
+
+	return temp
+}
+
+/*
+creating request body for calling rupeeseed api
+for CoOrderExit through func CoverOrderExit
+*/
+func getRupeeseedCoverExitRequestBody(c *gin.Context, req CancelOrderRequest) RupeeseedCoverExitRequest {
+	userId := "TEST2" // only for testing
+	temp := RupeeseedCoverExitRequest{}
+	//temp.EntityID = c.GetString("entityId")
+	temp.EntityID = userId
+	temp.Source = Source
+	//temp.Data.ClientID = c.GetString("clientId")
+	temp.Data.ClientID = userId
+	temp.Data.OrderNo = req.OrderNo
+	temp.Data.SerialNo = fmt.Sprintf("%d", req.SerialNo)
+	temp.Data.GroupId = fmt.Sprintf("%d", req.GroupId)
+	temp.Data.LegNo = fmt.Sprintf("%d", req.LegNo)
+	temp.Data.TxnType = req.TxnType
+	temp.Data.Exchange = req.Exchange
+	temp.Data.Segment = req.Segment
+	temp.Data.Product = req.Product
+
+	return temp
+}
+
+/*
+order numbers from the data of rupeeseed order entry response
+i.e [{"order_no": "112211242008"}]
+*/
+func orderNumbers(data interface{}) []string {
+	var (
+		orders []struct {
+			OrderNo string `json:"order_no"`
+		}
+		numbers []string
+	)
+	byteData, err := json.Marshal(data)
+	if err != nil || json.Unmarshal(byteData, &orders) != nil {
+		return numbers
+	}
+	for _, order := range orders {
+		if len(order.OrderNo) != 0 {
+			numbers = append(numbers, order.OrderNo)
+		}
+	}
+	return numbers
+}
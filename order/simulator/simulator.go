@@ -0,0 +1,440 @@
+package simulator
+
+import (
+	trade "e/order"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// order numbers of the simulator follow this seed
+const orderNoSeed = 112211240000
+
+/*
+Simulator serves the rupeeseed apis used by the service from memory for
+local development & integration tests, requests & responses are the
+rupeeseed types so the service runs against it unchanged with
+rupeeseed.endpoint pointing to the simulator, orders are filled with
+the matching model of trade.MatchingBook
+
+	OrderApi, ModifyOrderApi, BoModifyOrderAPI, CoModifyOrderApi, CancelOrderApi
+	BracketOrderApi, CoverOrderApi, BoExitOrderApi, CoExitOrderApi
+	OrderBookApi, PositionBookApi, ConvertPositionApi
+*/
+type Simulator struct {
+	mu          sync.Mutex
+	router      *gin.Engine
+	books       map[string]*trade.MatchingBook
+	prices      map[string]float64
+	faults      map[string][]Fault
+	lastOrderNo int64
+}
+
+/*
+Fault is a failure served for the next Times calls of an api instead
+of processing the request, a non 200 HTTPStatus replies with the status
+& an empty body, otherwise an error response with ErrorCode & Message
+i.e RS-0023 "SCRIP IS BLOCKED"
+*/
+type Fault struct {
+	HTTPStatus int
+	ErrorCode  string
+	Message    string
+	Times      int
+}
+
+// order number in data of order entry replies
+type orderNoData struct {
+	OrderNo string `json:"order_no"`
+}
+
+func New() *Simulator {
+	sim := &Simulator{
+		books:       make(map[string]*trade.MatchingBook),
+		prices:      make(map[string]float64),
+		faults:      make(map[string][]Fault),
+		lastOrderNo: orderNoSeed,
+	}
+	router := gin.New()
+	router.POST(trade.OrderApi, sim.placeOrder)
+	router.POST(trade.BracketOrderApi, sim.placeBracketOrder)
+	router.POST(trade.CoverOrderApi, sim.placeCoverOrder)
+	router.POST(trade.ModifyOrderApi, sim.modifyOrder(trade.ModifyOrderApi))
+	router.POST(trade.BoModifyOrderAPI, sim.modifyOrder(trade.BoModifyOrderAPI))
+	router.POST(trade.CoModifyOrderApi, sim.modifyOrder(trade.CoModifyOrderApi))
+	router.POST(trade.CancelOrderApi, sim.cancelOrder)
+	router.POST(trade.BoExitOrderApi, sim.exitBracketOrder)
+	router.POST(trade.CoExitOrderApi, sim.exitCoverOrder)
+	router.POST(trade.OrderBookApi, sim.orderBook)
+	router.POST(trade.PositionBookApi, sim.positionBook)
+	router.POST(trade.ConvertPositionApi, sim.convertPosition)
+	sim.router = router
+	return sim
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// ListenAndServe runs the simulator at addr i.e ":8090"
+func (s *Simulator) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+/*
+SetPrice sets the last traded price of an instrument for every client,
+pending orders of the instrument are matched again and positions are
+marked to the price
+*/
+func (s *Simulator) SetPrice(exchange, securityID string, price float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prices[exchange+":"+securityID] = price
+	for _, book := range s.books {
+		book.SetPrice(exchange, securityID, price)
+	}
+}
+
+// InjectFault queues fault for api, faults of an api are served in the order injected
+func (s *Simulator) InjectFault(api string, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fault.Times <= 0 {
+		fault.Times = 1
+	}
+	s.faults[api] = append(s.faults[api], fault)
+}
+
+// Orders returns OrderBook rows of the client in the order placed
+func (s *Simulator) Orders(clientID string) []trade.RupeeseedOrderBook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.book(clientID).OrderRows()
+}
+
+// Positions returns PositionBook rows of the client ordered by instrument & product
+func (s *Simulator) Positions(clientID string) []trade.RupeeSeedPositionBook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.book(clientID).PositionRows()
+}
+
+// book of the client priced at the prices set so far, expects the lock to be held
+func (s *Simulator) book(clientID string) *trade.MatchingBook {
+	book, ok := s.books[clientID]
+	if !ok {
+		book = trade.NewMatchingBook()
+		for key, price := range s.prices {
+			book.Prices[key] = price
+		}
+		s.books[clientID] = book
+	}
+	return book
+}
+
+func (s *Simulator) nextOrderNo() string {
+	s.lastOrderNo++
+	return strconv.FormatInt(s.lastOrderNo, 10)
+}
+
+// serves the next fault injected for api, true if the request is answered
+func (s *Simulator) fault(c *gin.Context, api string, reply func(errorCode, message string) interface{}) bool {
+	queue := s.faults[api]
+	if len(queue) == 0 {
+		return false
+	}
+	fault := queue[0]
+	if queue[0].Times--; queue[0].Times == 0 {
+		s.faults[api] = queue[1:]
+	}
+	if fault.HTTPStatus != 0 && fault.HTTPStatus != http.StatusOK {
+		c.Status(fault.HTTPStatus)
+		return true
+	}
+	c.JSON(http.StatusOK, reply(fault.ErrorCode, fault.Message))
+	return true
+}
+
+func orderEntryError(errorCode, message string) interface{} {
+	return trade.RupeeseedNormalOrderResponse{Status: "error", ErrCode: errorCode, Message: message}
+}
+
+// reply of order entry apis with the order number
+func orderEntryReply(c *gin.Context, message string, order trade.RupeeseedOrderBook, err error) {
+	if err != nil {
+		c.JSON(http.StatusOK, orderEntryError("", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, trade.RupeeseedNormalOrderResponse{
+		Status:  trade.Success,
+		Message: message + ". Your Order Ref No. " + order.OrderNo,
+		Data:    []orderNoData{{OrderNo: order.OrderNo}},
+	})
+}
+
+func (s *Simulator) placeOrder(c *gin.Context) {
+	var request trade.RupeeseedNormalOrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, orderEntryError("", "INVALID REQUEST"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fault(c, trade.OrderApi, orderEntryError) {
+		return
+	}
+
+	d := request.Data
+	order, err := matchingOrder(d.TxnType, d.Exchange, d.Segment, d.Product, d.ExchangeToken, d.Qty, d.Price, d.TriggerPrice, d.OrderType, d.Valdity)
+	if err != nil {
+		c.JSON(http.StatusOK, orderEntryError("", err.Error()))
+		return
+	}
+	row, err := s.book(d.ClientId).Enter(s.nextOrderNo(), order)
+	orderEntryReply(c, "Order submitted successfully", row, err)
+}
+
+func (s *Simulator) placeBracketOrder(c *gin.Context) {
+	var request trade.RupeseedBracketOrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, orderEntryError("", "INVALID REQUEST"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fault(c, trade.BracketOrderApi, orderEntryError) {
+		return
+	}
+
+	d := request.Data
+	order, err := matchingOrder(d.TxnType, d.Exchange, d.Segment, d.Product, d.ExchangeToken, d.Quantity, d.Price, "", d.OrderType, d.Validity)
+	if err == nil {
+		order.ProfitValue, err = parsePrice(d.ProfitValue)
+	}
+	if err == nil {
+		order.StoplossValue, err = parsePrice(d.StoplossValue)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, orderEntryError("", err.Error()))
+		return
+	}
+	row, err := s.book(d.ClientID).Enter(s.nextOrderNo(), order)
+	orderEntryReply(c, "Order submitted successfully", row, err)
+}
+
+func (s *Simulator) placeCoverOrder(c *gin.Context) {
+	var request trade.RupeseedCoverOrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, orderEntryError("", "INVALID REQUEST"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fault(c, trade.CoverOrderApi, orderEntryError) {
+		return
+	}
+
+	d := request.Data
+	order, err := matchingOrder(d.TxnType, d.Exchange, d.Segment, d.Product, d.ExchangeToken, d.Quantity, d.Price, "", d.OrderType, d.Validity)
+	if err == nil {
+		order.CoverTrigger, err = parsePrice(d.TriggerPrice)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, orderEntryError("", err.Error()))
+		return
+	}
+	row, err := s.book(d.ClientID).Enter(s.nextOrderNo(), order)
+	orderEntryReply(c, "Order submitted successfully", row, err)
+}
+
+func (s *Simulator) modifyOrder(api string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request trade.VendorRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, orderEntryError("", "INVALID REQUEST"))
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.fault(c, api, orderEntryError) {
+			return
+		}
+
+		d := request.Data
+		serialNo, _ := strconv.Atoi(d.SerialNo)
+		qty, err := strconv.Atoi(d.Qty)
+		if err != nil {
+			c.JSON(http.StatusOK, orderEntryError("", "INVALID QUANTITY"))
+			return
+		}
+		price, err := parsePrice(d.Price)
+		if err != nil {
+			c.JSON(http.StatusOK, orderEntryError("", err.Error()))
+			return
+		}
+		triggerPrice, err := parsePrice(d.TriggerPrice)
+		if err != nil {
+			c.JSON(http.StatusOK, orderEntryError("", err.Error()))
+			return
+		}
+		row, err := s.book(d.ClientId).Modify(d.OrderNo, serialNo, qty, price, triggerPrice, d.OrderType, d.Validity)
+		orderEntryReply(c, "Order modified successfully", row, err)
+	}
+}
+
+func (s *Simulator) cancelOrder(c *gin.Context) {
+	var request trade.RupeeseedCancelOrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, orderEntryError("", "INVALID REQUEST"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fault(c, trade.CancelOrderApi, orderEntryError) {
+		return
+	}
+
+	d := request.Data
+	serialNo, _ := strconv.Atoi(d.SerialNo)
+	row, err := s.book(d.ClientId).Cancel(d.OrderNo, serialNo)
+	orderEntryReply(c, "Order cancelled successfully", row, err)
+}
+
+func (s *Simulator) exitBracketOrder(c *gin.Context) {
+	var request trade.RupeeseedBracketExitRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, orderEntryError("", "INVALID REQUEST"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fault(c, trade.BoExitOrderApi, orderEntryError) {
+		return
+	}
+
+	row, err := s.book(request.Data.ClientID).Exit(request.Data.OrderNo)
+	orderEntryReply(c, "Order exited successfully", row, err)
+}
+
+func (s *Simulator) exitCoverOrder(c *gin.Context) {
+	var request trade.RupeeseedCoverExitRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, orderEntryError("", "INVALID REQUEST"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fault(c, trade.CoExitOrderApi, orderEntryError) {
+		return
+	}
+
+	row, err := s.book(request.Data.ClientID).Exit(request.Data.OrderNo)
+	orderEntryReply(c, "Order exited successfully", row, err)
+}
+
+func (s *Simulator) orderBook(c *gin.Context) {
+	var request trade.RupeeseedOrderBookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, trade.RupeeseedOrderBookResponse{Status: "error", Message: "INVALID REQUEST"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fault(c, trade.OrderBookApi, func(errorCode, message string) interface{} {
+		return trade.RupeeseedOrderBookResponse{Status: "error", ErrorCode: errorCode, Message: message}
+	}) {
+		return
+	}
+
+	response := trade.RupeeseedOrderBookResponse{Status: trade.Success, Data: make([]trade.RupeeseedOrderBook, 0)}
+	// latest order first as in rupeeseed OrderBook
+	orders := s.book(request.Data.ClientId).OrderRows()
+	for i := len(orders) - 1; i >= 0; i-- {
+		response.Data = append(response.Data, orders[i])
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (s *Simulator) positionBook(c *gin.Context) {
+	var request trade.RupeeseedPositionBookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, trade.RupeeseedPositionBookResponse{Status: "error", Message: "INVALID REQUEST"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fault(c, trade.PositionBookApi, func(errorCode, message string) interface{} {
+		return trade.RupeeseedPositionBookResponse{Status: "error", ErrorCode: errorCode, Message: message}
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, trade.RupeeseedPositionBookResponse{Status: trade.Success, Data: s.book(request.Data.ClientId).PositionRows()})
+}
+
+func (s *Simulator) convertPosition(c *gin.Context) {
+	var request trade.RuppeeseedConvertPositionRequest
+	reply := func(errorCode, message string) interface{} {
+		return trade.RuppeeseedConvertPositionResponse{Status: "error", ErrorCode: errorCode, Message: message}
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, reply("", "INVALID REQUEST"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fault(c, trade.ConvertPositionApi, reply) {
+		return
+	}
+
+	d := request.Data
+	if err := s.book(d.ClientID).Convert(d.Exchange, d.SecurityID, d.TxnType, d.ProductFrom, d.ProductTo, d.Quantity); err != nil {
+		c.JSON(http.StatusOK, reply("RS-0022", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, trade.RuppeeseedConvertPositionResponse{Status: trade.Success, Message: "Position converted successfully"})
+}
+
+// order of an entry request, quantity & prices are sent as strings
+func matchingOrder(txnType, exchange, segment, product, token, qty, price, triggerPrice, orderType, validity string) (trade.MatchingOrder, error) {
+	quantity, err := strconv.Atoi(qty)
+	if err != nil {
+		return trade.MatchingOrder{}, fmt.Errorf("INVALID QUANTITY")
+	}
+	limit, err := parsePrice(price)
+	if err != nil {
+		return trade.MatchingOrder{}, err
+	}
+	trigger, err := parsePrice(triggerPrice)
+	if err != nil {
+		return trade.MatchingOrder{}, err
+	}
+	return trade.MatchingOrder{
+		TxnType:      txnType,
+		Exchange:     exchange,
+		Segment:      segment,
+		Product:      product,
+		SecurityID:   token,
+		Quantity:     quantity,
+		Price:        limit,
+		TriggerPrice: trigger,
+		OrderType:    orderType,
+		Validity:     validity,
+	}, nil
+}
+
+// prices are sent as "%f", empty for prices not applicable to the order
+func parsePrice(value string) (float64, error) {
+	if len(strings.TrimSpace(value)) == 0 {
+		return 0.0, nil
+	}
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price < 0.0 {
+		return 0.0, fmt.Errorf("INVALID PRICE")
+	}
+	return price, nil
+}
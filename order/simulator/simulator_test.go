@@ -0,0 +1,81 @@
+package simulator
+
+import (
+	"bytes"
+	trade "e/order"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	m.Run()
+}
+
+func TestSimulator(t *testing.T) {
+	sim := New()
+	post := func(api string, body, response interface{}) int {
+		payload, _ := json.Marshal(body)
+		recorder := httptest.NewRecorder()
+		sim.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, api, bytes.NewReader(payload)))
+		if response != nil && recorder.Body.Len() != 0 {
+			_ = json.Unmarshal(recorder.Body.Bytes(), response)
+		}
+		return recorder.Code
+	}
+
+	sim.SetPrice("NSE", "3045", 500)
+	var order trade.RupeeseedNormalOrderRequest
+	order.Data.ClientId = "C1"
+	order.Data.TxnType = trade.BUY
+	order.Data.Exchange = "NSE"
+	order.Data.Segment = "E"
+	order.Data.Product = "I"
+	order.Data.ExchangeToken = "3045"
+	order.Data.Qty = "10"
+	order.Data.OrderType = trade.MKT
+	order.Data.Valdity = trade.DAY
+	var reply trade.RupeeseedNormalOrderResponse
+	if code := post(trade.OrderApi, order, &reply); code != http.StatusOK || reply.Status != trade.Success {
+		t.Fatalf("OrderApi want order accepted, got [%d] [%+v]", code, reply)
+	}
+	if orders := sim.Orders("C1"); len(orders) != 1 || orders[0].Status != trade.Traded || orders[0].TradedPrice != 500 {
+		t.Errorf("Orders() want market order traded at 500, got [%+v]", orders)
+	}
+	if orders := sim.Orders("C2"); len(orders) != 0 {
+		t.Errorf("Orders() want books kept per client, got [%+v]", orders)
+	}
+
+	// invalid quantity is rejected in the reply as rupeeseed does
+	order.Data.Qty = "ten"
+	reply = trade.RupeeseedNormalOrderResponse{}
+	if post(trade.OrderApi, order, &reply); reply.Status != "error" || reply.Message != "INVALID QUANTITY" {
+		t.Errorf("OrderApi want INVALID QUANTITY, got [%+v]", reply)
+	}
+
+	// injected rejections are replied with the error code
+	order.Data.Qty = "10"
+	sim.InjectFault(trade.OrderApi, Fault{ErrorCode: "RS-0023", Message: "SCRIP IS BLOCKED"})
+	reply = trade.RupeeseedNormalOrderResponse{}
+	if post(trade.OrderApi, order, &reply); reply.Status != "error" || reply.ErrCode != "RS-0023" {
+		t.Errorf("OrderApi want injected RS-0023, got [%+v]", reply)
+	}
+
+	// injected http failures are served the times injected
+	var request trade.RupeeseedOrderBookRequest
+	request.Data.ClientId = "C1"
+	sim.InjectFault(trade.OrderBookApi, Fault{HTTPStatus: http.StatusBadGateway, Times: 2})
+	for i := 0; i < 2; i++ {
+		if code := post(trade.OrderBookApi, request, nil); code != http.StatusBadGateway {
+			t.Errorf("OrderBookApi want injected bad gateway, got [%d]", code)
+		}
+	}
+	var book trade.RupeeseedOrderBookResponse
+	if code := post(trade.OrderBookApi, request, &book); code != http.StatusOK || len(book.Data) != 1 {
+		t.Errorf("OrderBookApi want order of the client, got [%d] [%+v]", code, book)
+	}
+}
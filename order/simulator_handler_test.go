@@ -0,0 +1,85 @@
+package trade_test
+
+import (
+	"bytes"
+	trade "e/order"
+	"e/order/simulator"
+	"encoding/json"
+	config "equity-trading/pkg/config"
+	dbmock "equity-trading/pkg/db/mock"
+	"equity-trading/pkg/db/scrip"
+	mock "equity-trading/pkg/utils/mock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+)
+
+// handlers run end to end against the rupeeseed simulator, rest calls of the service are sent to it over http
+func TestHandlersWithSimulator(t *testing.T) {
+	sim := simulator.New()
+	sim.SetPrice("NSE", "3045", 500)
+	server := httptest.NewServer(sim)
+	defer server.Close()
+
+	ctrl := gomock.NewController(t)
+	invoker := mock.NewMockUtils(ctrl)
+	forward := func(method, uri string, body interface{}, headers map[string]string, timeout int) ([]byte, int, error) {
+		payload, _ := json.Marshal(body)
+		api := strings.TrimPrefix(uri, config.GetConfig().GetString("rupeeseed.endpoint"))
+		resp, err := http.Post(server.URL+api, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return nil, 0, err
+		}
+		defer resp.Body.Close()
+		reply, err := io.ReadAll(resp.Body)
+		return reply, resp.StatusCode, err
+	}
+	invoker.EXPECT().InvokeHttp(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(forward).AnyTimes()
+	invoker.EXPECT().InvokeResty(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(forward).AnyTimes()
+	invoker.EXPECT().Get(gomock.Any()).Return("", nil).AnyTimes()
+	repo := dbmock.NewMockDBLayer(ctrl)
+	repo.EXPECT().GetMasterSymbols(gomock.Any()).Return([]scrip.MasterSymbol{{LotSize: 1, TickSize: 0.05}}, 1, nil).AnyTimes()
+	repo.EXPECT().GetSymbolTickData("3045", "NSE").Return(scrip.SymbolTickData{LastTradedPrice: 500}, nil).AnyTimes()
+	servObj := trade.NewTradeGroup(repo, invoker, invoker)
+
+	router := gin.New()
+	router.POST("/orders", servObj.PlaceOrder)
+	router.POST("/positions", servObj.PositionBook)
+	call := func(path string, input interface{}) *httptest.ResponseRecorder {
+		byteData, _ := json.Marshal(input)
+		request := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(byteData))
+		request.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	order := trade.PlaceOrderRequest{TxnType: "B", Exchange: "NSE", Segment: "E", Product: "I", ExchangeToken: 3045, Quantity: 10, Validity: "DAY", OrderType: "MKT"}
+	if recorder := call("/orders", order); recorder.Code != http.StatusOK {
+		t.Fatalf("PlaceOrder() want order placed with the simulator, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+	if orders := sim.Orders("TEST2"); len(orders) != 1 || orders[0].Status != trade.Traded {
+		t.Errorf("PlaceOrder() want market order traded by the simulator, got [%+v]", orders)
+	}
+
+	recorder := call("/positions", nil)
+	var positions trade.PositionBookResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &positions)
+	if recorder.Code != http.StatusOK || len(positions.Data.OrderPosition) != 1 || positions.Data.OrderPosition[0].NetQty != 10 {
+		t.Errorf("PositionBook() want net quantity of the traded order, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+
+	// rejection of rupeeseed is not replied as success
+	sim.InjectFault(trade.OrderApi, simulator.Fault{ErrorCode: "RS-0023", Message: "SCRIP IS BLOCKED", Times: 1})
+	if recorder := call("/orders", order); recorder.Code == http.StatusOK {
+		t.Errorf("PlaceOrder() want rejection of the simulator replied as failure, got [%d] [%s]", recorder.Code, recorder.Body.String())
+	}
+	if orders := sim.Orders("TEST2"); len(orders) != 1 {
+		t.Errorf("PlaceOrder() want rejected order kept out of the book, got [%+v]", orders)
+	}
+}
@@ -0,0 +1,174 @@
+package trade
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var ErrScripNotFound = errors.New("instrument not found in scrip master")
+
+/*
+freeze quantity & lot size of the instrument from scrip master,
+freeze quantity is 0 when exchange has no freeze limit for it
+*/
+func (s *trade) freezeLimits(request PlaceOrderRequest) (int, int, error) {
+	instrument, err := s.Instrument(request.Exchange, request.Segment, request.ExchangeToken)
+	if err != nil {
+		return 0, 0, err
+	}
+	lotSize := instrument.LotSize
+	if lotSize <= 0 {
+		lotSize = 1
+	}
+	return instrument.FreezeQty, lotSize, nil
+}
+
+/*
+splits quantity into child quantities at or below freeze quantity,
+every child except the last one is a multiple of lot size
+*/
+func sliceQuantity(quantity, freezeQty, lotSize int) []int {
+	maxChild := (freezeQty / lotSize) * lotSize
+	if maxChild <= 0 {
+		maxChild = lotSize
+	}
+	slices := make([]int, 0, quantity/maxChild+1)
+	for quantity > 0 {
+		qty := maxChild
+		if quantity < maxChild {
+			qty = quantity
+		}
+		slices = append(slices, qty)
+		quantity -= qty
+	}
+	return slices
+}
+
+/*
+places child orders of an order above freeze quantity one after another
+under a single parent id, placement stops at the first failed child as
+remaining quantity would otherwise be partially placed out of order,
+the parent id is stored with the placed children so they can be looked
+up through SlicedOrderChildren
+*/
+func (s *trade) placeSlicedOrder(c *gin.Context, request PlaceOrderRequest, freezeQty, lotSize int) (SlicedOrder, *BrokerError) {
+	sliced := SlicedOrder{ParentId: newParentId(), Quantity: request.Quantity, FreezeQty: freezeQty, PlacedAt: time.Now()}
+	quantities := sliceQuantity(request.Quantity, freezeQty, lotSize)
+	logger.Log.Info("slicing order above freeze quantity", zap.String("parentId", sliced.ParentId),
+		zap.Int("quantity", request.Quantity), zap.Int("freezeQty", freezeQty), zap.Int("children", len(quantities)))
+
+	var failure *BrokerError
+	for _, qty := range quantities {
+		child := request
+		child.Quantity = qty
+		if child.DisclosedQty > qty {
+			child.DisclosedQty = qty
+		}
+
+		result := ChildOrderResult{Quantity: qty}
+		if failure != nil {
+			result.Message = "not placed, previous child order failed"
+			sliced.ChildOrders = append(sliced.ChildOrders, result)
+			continue
+		}
+		ack, er := s.broker(c).PlaceOrder(c, child)
+		result.Message = ack.Message
+		if er != nil {
+			failure = er
+		} else {
+			result.Status = true
+			if len(ack.OrderNos) > 0 {
+				result.OrderNo = ack.OrderNos[0]
+			}
+		}
+		sliced.ChildOrders = append(sliced.ChildOrders, result)
+	}
+	if failure != nil {
+		logger.Log.Error("sliced order failed", zap.String("parentId", sliced.ParentId), zap.Error(failure))
+	}
+	if sliced.placed() > 0 {
+		s.saveSlicedOrder(c.GetString("userId"), sliced)
+	}
+	return sliced, failure
+}
+
+// count of child orders accepted by the broker
+func (o SlicedOrder) placed() int {
+	placed := 0
+	for _, child := range o.ChildOrders {
+		if child.Status {
+			placed++
+		}
+	}
+	return placed
+}
+
+// a failure to store the link is only logged as the children are already at the broker
+func (s *trade) saveSlicedOrder(userId string, sliced SlicedOrder) {
+	byteData, err := json.Marshal(sliced)
+	if err != nil {
+		logger.Log.Error("Failed to marshal sliced order", zap.Error(err), zap.String("parentId", sliced.ParentId))
+		return
+	}
+	if err := s.redisCaller.Set(SlicedOrderKeyPrefix+userId+":"+sliced.ParentId, string(byteData), SlicedOrderTTL); err != nil {
+		logger.Log.Error("Failed to store sliced order", zap.Error(err), zap.String("parentId", sliced.ParentId))
+	}
+}
+
+// returns child orders placed under the parent id of a sliced order of the user
+func (s *trade) SlicedOrderChildren(c *gin.Context) {
+	var (
+		response SlicedOrderResponse
+	)
+
+	parentId := strings.TrimSpace(c.Query(ParentIdParam))
+	if len(parentId) == 0 {
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(":parentId is required"))
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+	value, err := s.redisCaller.Get(SlicedOrderKeyPrefix + c.GetString("userId") + ":" + parentId)
+	if err != nil {
+		logger.Log.Error("Failed to read sliced order", zap.Error(err), zap.String("parentId", parentId))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+	if len(value) == 0 {
+		response.Errors = append(response.Errors, e.ErrorInfo["NoDataFound"].GetErrorDetails("sliced order not found."))
+		c.JSON(http.StatusNotFound, response)
+		c.Abort()
+		return
+	}
+	if err := json.Unmarshal([]byte(value), &response.Data); err != nil {
+		logger.Log.Error("Failed to unmarshal sliced order", zap.Error(err), zap.String("parentId", parentId))
+		response.Errors = append(response.Errors, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}
+
+func newParentId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("P%d", time.Now().UnixNano())
+	}
+	return "P" + hex.EncodeToString(b)
+}
@@ -0,0 +1,262 @@
+package trade
+
+import (
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+/*
+squares off open positions of the user with opposing market orders
+
+steps within api
+  - fetch positions from PositionBook of the broker
+  - skip closed positions & positions not matching segment, product or symbol filter
+  - cancel open orders of the instrument & product so they cannot fill after square off
+  - place market order on the opposite side for absolute net quantity,
+    derivative positions above freeze quantity are placed as sliced orders
+  - reply with result of every position, 207 when only some of them
+    were squared off & status of the broker failure when none were
+*/
+func (s *trade) SquareOffPositions(c *gin.Context) {
+	var (
+		request  SquareOffRequest
+		response SquareOffResponse
+	)
+	if err := bindOrderJSON(c, &request); err != nil {
+		logger.Log.Error("Invalid arguement received for square off", zap.Error(err))
+		response.Errors = append(response.Errors, e.ErrorInfo["BadRequest"].GetErrorDetails(bindingErrorMessage(err)))
+		response.FieldErrors = fieldErrorsOf(err)
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+
+	results, err := s.squareOffPositions(c, request)
+	if err != nil {
+		response.Errors = append(response.Errors, err.Err)
+		setRetryAfter(c, err)
+		c.JSON(brokerErrorStatus(err), response)
+		c.Abort()
+		return
+	}
+	if len(results) == 0 {
+		logger.Log.Info("No open positions available to square off")
+		response.Errors = append(response.Errors, e.ErrorInfo["NoDataFound"].GetErrorDetails("no matching open positions available to square off"))
+		c.JSON(http.StatusBadRequest, response)
+		c.Abort()
+		return
+	}
+
+	status := squareOffStatus(results)
+	response.Data = results
+	response.Status = status == http.StatusOK
+	if status >= http.StatusBadRequest {
+		for i := range results {
+			if results[i].failure != nil {
+				setRetryAfter(c, results[i].failure)
+			}
+		}
+	}
+	c.JSON(status, response)
+	if status >= http.StatusBadRequest {
+		c.Abort()
+	}
+}
+
+// status of square off by outcome of its positions, same as basketStatus for legs
+func squareOffStatus(results []SquareOffResult) int {
+	failures := make([]*BrokerError, 0, len(results))
+	for i := range results {
+		if !results[i].Status {
+			failures = append(failures, results[i].failure)
+		}
+	}
+	return partialStatus(len(results), failures)
+}
+
+/*
+places opposing market orders for open positions matching the filter,
+failure of one position does not stop square off of the others
+
+	input:
+		context
+		SquareOffRequest filter
+	output:
+		result of every matching open position
+		*Error if positions or orders could not be fetched
+*/
+func (s *trade) squareOffPositions(c *gin.Context, filter SquareOffRequest) ([]SquareOffResult, *BrokerError) {
+	return s.squareOffMatchingPositions(c, s.broker(c), func(position OrderPositionBook) bool {
+		return filterPosition(filter, position)
+	})
+}
+
+// squares off open positions at the broker for which match is true
+func (s *trade) squareOffMatchingPositions(c *gin.Context, broker Broker, match func(OrderPositionBook) bool) ([]SquareOffResult, *BrokerError) {
+	positions, err := broker.PositionBook(c)
+	if err != nil {
+		return nil, err
+	}
+	open := make([]OrderPositionBook, 0, len(positions))
+	for _, position := range positions {
+		if position.NetQty != 0 && match(position) {
+			open = append(open, position)
+		}
+	}
+	if len(open) == 0 {
+		return []SquareOffResult{}, nil
+	}
+	orders, err := broker.OrderBook(c)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SquareOffResult, 0, len(open))
+	for _, position := range open {
+		results = append(results, s.squareOffPosition(c, broker, position, orders))
+	}
+	return results, nil
+}
+
+func (s *trade) squareOffPosition(c *gin.Context, broker Broker, position OrderPositionBook, orders []BrokerOrder) SquareOffResult {
+	result := SquareOffResult{
+		Symbol:     position.Symbol,
+		Exchange:   position.Exchange,
+		Segment:    position.Segment,
+		Product:    position.Product,
+		SecurityID: position.SecurityID,
+		NetQty:     position.NetQty,
+	}
+
+	// a pending order filling after the square off would open the position again
+	for _, order := range orders {
+		orderStatus, _ := ParseOrderStatus(order.Status)
+		if orderStatus.Section() != Open || order.SecurityID != position.SecurityID ||
+			!strings.EqualFold(order.Exchange, position.Exchange) || !strings.EqualFold(order.Product, position.Product) {
+			continue
+		}
+		_, err := broker.CancelOrder(c, CancelOrderRequest{
+			OrderNo:  order.OrderNo,
+			SerialNo: order.SerialNo,
+			GroupId:  order.GroupId,
+			TxnType:  order.TxnType,
+			Exchange: order.Exchange,
+			Segment:  order.Segment,
+			Product:  order.Product,
+		})
+		if err != nil {
+			logger.Log.Error("square off: failed to cancel open order", zap.String("orderNo", order.OrderNo), zap.Error(err))
+			result.Errors = append(result.Errors, err.Err)
+			result.failure = err
+			return result
+		}
+		result.CancelledOrderNos = append(result.CancelledOrderNos, order.OrderNo)
+	}
+
+	// long positions are sold & short positions are bought back
+	result.TxnType, result.Quantity = SELL, position.NetQty
+	if position.NetQty < 0 {
+		result.TxnType, result.Quantity = BUY, -position.NetQty
+	}
+
+	token, er := strconv.Atoi(position.SecurityID)
+	if er != nil {
+		logger.Log.Error("square off: invalid security id in PositionBook", zap.String("securityId", position.SecurityID), zap.Error(er))
+		result.failure = brokerError(http.StatusInternalServerError, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		result.Errors = append(result.Errors, result.failure.Err)
+		return result
+	}
+	request := PlaceOrderRequest{
+		TxnType:       result.TxnType,
+		Exchange:      position.Exchange,
+		Segment:       position.Segment,
+		Product:       position.Product,
+		ExchangeToken: token,
+		Quantity:      result.Quantity,
+		Validity:      DayValidity,
+		OrderType:     MarketOrder,
+	}
+
+	orderNos, message, err := s.placeSquareOffOrder(c, broker, request)
+	result.OrderNos, result.Message = orderNos, message
+	if err != nil {
+		logger.Log.Error("square off order failed", zap.String("symbol", position.Symbol), zap.Error(err))
+		result.Errors = append(result.Errors, err.Err)
+		result.failure = err
+		return result
+	}
+	result.Status = true
+	return result
+}
+
+/*
+places the market order closing a position within trading session,
+derivative orders above freeze quantity are placed as sliced orders &
+placement stops at the first failed slice
+
+	input:
+		context
+		broker the order is placed with
+		PlaceOrderRequest of the closing market order
+	output:
+		order numbers of the placed slices
+		message of the last broker reply
+		*Error of the failed slice
+*/
+func (s *trade) placeSquareOffOrder(c *gin.Context, broker Broker, request PlaceOrderRequest) ([]string, string, *BrokerError) {
+	// closing orders outside trading session are rejected or converted to AMO
+	if er := applyTradingSession(&request); er != nil {
+		logger.Log.Error("square off: order outside trading session", zap.Int("exchangeToken", request.ExchangeToken), zap.Error(er))
+		return nil, "", brokerError(http.StatusBadRequest, e.ErrorInfo["BadRequest"].GetErrorDetails(er.Error()))
+	}
+	quantities := []int{request.Quantity}
+	if request.Segment != EquitySegment {
+		freezeQty, lotSize, er := s.freezeLimits(request)
+		if er != nil {
+			logger.Log.Error("square off: failed to fetch freeze quantity from scrip master", zap.Int("exchangeToken", request.ExchangeToken), zap.Error(er))
+			return nil, "", brokerError(http.StatusInternalServerError, e.ErrorInfo["InternalServerError"].GetErrorDetails(""))
+		}
+		if freezeQty > 0 && request.Quantity > freezeQty {
+			quantities = sliceQuantity(request.Quantity, freezeQty, lotSize)
+		}
+	}
+
+	var (
+		orderNos []string
+		message  string
+	)
+	for _, qty := range quantities {
+		request.Quantity = qty
+		ack, err := broker.PlaceOrder(c, request)
+		message = ack.Message
+		if err != nil {
+			logger.Log.Error("square off: slice failed", zap.Int("exchangeToken", request.ExchangeToken), zap.Int("quantity", qty), zap.Error(err))
+			return orderNos, message, err
+		}
+		orderNos = append(orderNos, ack.OrderNos...)
+	}
+	return orderNos, message, nil
+}
+
+func filterPosition(filter SquareOffRequest, position OrderPositionBook) bool {
+	segment := strings.TrimSpace(filter.Segment)
+	product := strings.TrimSpace(filter.Product)
+	symbol := strings.TrimSpace(filter.Symbol)
+
+	if len(segment) != 0 && !strings.EqualFold(position.Segment, segment) {
+		return false
+	}
+	if len(product) != 0 && !strings.EqualFold(position.Product, product) {
+		return false
+	}
+	if len(symbol) != 0 && !strings.EqualFold(position.Symbol, symbol) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package trade
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OrderStatus is the order status as reported by rupeeseed
+type OrderStatus string
+
+const UnknownStatus = "Unknown"
+
+var ErrUnknownOrderStatus = errors.New("unknown rupeeseed order status")
+
+type orderState struct {
+	riseStatus string
+	section    string
+}
+
+/*
+maps rupeeseed status to rise status & section of OrderBook
+
+	Rupeeseed status | Rise Status        | Section
+	================================================
+	Transit          | Pending            | Open
+	Pending          | Pending            | Open
+	Modified         | Pending            | Open
+	Part-traded      | Partially Executed | Open
+	Traded           | Executed           | Executed
+	Rejected         | Rejected           | Executed
+	Cancelled        | Cancelled          | Executed
+*/
+var orderStates = map[OrderStatus]orderState{
+	Transit:    {riseStatus: Pending, section: Open},
+	Pending:    {riseStatus: Pending, section: Open},
+	Modified:   {riseStatus: Pending, section: Open},
+	PartTraded: {riseStatus: PartExecuted, section: Open},
+	Traded:     {riseStatus: Executed, section: Executed},
+	Rejected:   {riseStatus: Rejected, section: Executed},
+	Cancelled:  {riseStatus: Cancelled, section: Executed},
+}
+
+/*
+allowed transitions of an order at rupeeseed,
+Traded, Rejected & Cancelled are terminal states
+*/
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	Transit:    {Pending, PartTraded, Traded, Rejected, Cancelled},
+	Pending:    {Modified, PartTraded, Traded, Rejected, Cancelled},
+	Modified:   {Pending, Modified, PartTraded, Traded, Rejected, Cancelled},
+	PartTraded: {Modified, PartTraded, Traded, Cancelled},
+	Traded:     {},
+	Rejected:   {},
+	Cancelled:  {},
+}
+
+/*
+ParseOrderStatus matches rupeeseed status case insensitively,
+unknown statuses are returned as is along with ErrUnknownOrderStatus
+*/
+func ParseOrderStatus(status string) (OrderStatus, error) {
+	status = strings.TrimSpace(status)
+	for known := range orderStates {
+		if strings.EqualFold(string(known), status) {
+			return known, nil
+		}
+	}
+	return OrderStatus(status), fmt.Errorf("%w: %q", ErrUnknownOrderStatus, status)
+}
+
+// RiseStatus returns our status for the rupeeseed status
+func (o OrderStatus) RiseStatus() string {
+	if state, ok := orderStates[o]; ok {
+		return state.riseStatus
+	}
+	return UnknownStatus
+}
+
+// Section returns Open or Executed section of the order
+func (o OrderStatus) Section() string {
+	if state, ok := orderStates[o]; ok {
+		return state.section
+	}
+	return UnknownStatus
+}
+
+// IsTerminal reports if no further transition is possible
+func (o OrderStatus) IsTerminal() bool {
+	next, ok := orderTransitions[o]
+	return ok && len(next) == 0
+}
+
+// CanTransitionTo reports if rupeeseed can move the order from o to next
+func (o OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[o] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package trade
+
+import (
+	e "equity-trading/pkg/errors"
+	"equity-trading/pkg/logger"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+/*
+rupeeseedErrorCode classifies a failure reported by rupeeseed, Error is
+the key of ErrorInfo returned to the client with Status as http status,
+Message is shown to the user and the message of rupeeseed is shown when
+it is empty
+*/
+type rupeeseedErrorCode struct {
+	Error     string
+	Status    int
+	Retryable bool
+	Message   string
+}
+
+/*
+catalogue of rupeeseed error codes, every code of RupeeseedErrors of
+("equity-trading/pkg/errors") is listed here with how it is replied to
+the client, a code added there without an entry here fails
+TestRupeeseedErrorCatalogue, codes unknown to the catalogue are logged
+& counted as anomalies
+*/
+var rupeeseedErrorCodes = map[string]rupeeseedErrorCode{
+	// order or conversion not processed by the OMS of rupeeseed, not retried as the order may have reached the exchange
+	"RS-0022": {
+		Error:     "VendorOMSError",
+		Status:    http.StatusBadGateway,
+		Retryable: false,
+		Message:   "order could not be confirmed by the broker, check order book before placing again",
+	},
+	// request rejected by rupeeseed i.e SCRIP IS BLOCKED, NO DATA, the reason varies by api & is shown as sent
+	"RS-0023": {
+		Error:     "BadRequest",
+		Status:    http.StatusBadRequest,
+		Retryable: false,
+		Message:   "",
+	},
+}
+
+// classifies a failure of rupeeseed with error code, retryable when sending the request again may succeed
+func rupeeseedFailure(api, errCode, message string) (*BrokerError, bool) {
+	code, ok := rupeeseedErrorCodes[errCode]
+	if !ok {
+		logger.Log.Error("rupeeseed error code not in catalogue", zap.String("api", api), zap.String("errorCode", errCode), zap.String("msg", message))
+		countVendorAnomaly(VendorUnknownErrorCode, api, errCode)
+		code = rupeeseedErrorCode{Error: "VendorApiFailure", Status: http.StatusBadGateway}
+	}
+	if len(code.Message) != 0 {
+		message = code.Message
+	}
+	if len(message) == 0 {
+		return brokerError(code.Status, e.ErrorInfo[code.Error].GetErrorDetails("")), code.Retryable
+	}
+	return brokerError(code.Status, e.ErrorInfo[code.Error].GetErrorDetails(":"+message)), code.Retryable
+}
+
+const (
+	// reply of the vendor with an error code missing from the catalogue
+	VendorUnknownErrorCode = "unknown_error_code"
+	// reply of the vendor that cannot be decoded or has no status
+	VendorUnexpectedBody = "unexpected_body"
+)
+
+/*
+count of vendor replies the service could not classify since start of
+the instance, keyed by kind:api:code
+*/
+var vendorAnomalies = struct {
+	sync.Mutex
+	counts map[string]int64
+}{counts: make(map[string]int64)}
+
+func countVendorAnomaly(kind, api, code string) {
+	vendorAnomalies.Lock()
+	defer vendorAnomalies.Unlock()
+	vendorAnomalies.counts[kind+":"+api+":"+code]++
+}
+
+/*
+returns counts of vendor replies the service could not classify on
+this instance, route is expected to be behind admin authorization
+*/
+func (s *trade) VendorAnomalies(c *gin.Context) {
+	var response VendorAnomaliesResponse
+	vendorAnomalies.Lock()
+	response.Data = make([]VendorAnomaly, 0, len(vendorAnomalies.counts))
+	for key, count := range vendorAnomalies.counts {
+		response.Data = append(response.Data, VendorAnomaly{Key: key, Count: count})
+	}
+	vendorAnomalies.Unlock()
+	sort.Slice(response.Data, func(i, j int) bool { return response.Data[i].Key < response.Data[j].Key })
+
+	response.Status = true
+	c.JSON(http.StatusOK, response)
+}